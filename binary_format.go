@@ -0,0 +1,342 @@
+package osm2ch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/LdDl/ch"
+	"github.com/paulmach/osm"
+	"github.com/pkg/errors"
+)
+
+const (
+	binaryMagic   = "OSM2CHB"
+	binaryVersion = 1
+	// Coordinates are stored as integers with 7 decimal places (~1cm)
+	binaryCoordinatesScale = 1e7
+	// Upper bound of preallocated capacity while reading, so corrupted counts do not lead to huge allocations
+	binaryMaxPrealloc = 1 << 20
+)
+
+// WriteBinaryFile Writes expanded graph to file of binary format (see WriteBinary)
+func WriteBinaryFile(fname string, expandedEdges []ExpandedEdge, vertices []Vertex, shortcuts []Shortcut) error {
+	file, err := os.Create(fname)
+	if err != nil {
+		return errors.Wrap(err, "Can't create binary file")
+	}
+	defer file.Close()
+	err = WriteBinary(file, expandedEdges, vertices, shortcuts)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// WriteBinary Writes expanded graph (edges, vertices and shortcuts) in compact binary format
+/*
+	Layout of format (version 1):
+		header - magic string "OSM2CHB" followed by single byte of version
+		vertices - uvarint count, then for every vertex: ID, order position, importance, point
+		edges - uvarint count, then for every expanded edge: ID, source and target vertices, weight, flags (1 if edge was one way),
+			source and target OSM ways, OSM nodes of both components, geometry (uvarint number of points followed by points)
+		shortcuts - uvarint count, then for every shortcut: source, target and via vertices, weight
+	All integers are zigzag varints which are delta-encoded against previous value of the same kind (or against neighboring field of the same record),
+	weights are little-endian float32, points are pairs of (lon, lat) with 7 decimal places, delta-encoded against previous written point.
+	Order of records is preserved, so sorted input gives small deltas
+*/
+func WriteBinary(w io.Writer, expandedEdges []ExpandedEdge, vertices []Vertex, shortcuts []Shortcut) error {
+	bw := binaryWriter{w: bufio.NewWriter(w)}
+	bw.writeBytes([]byte(binaryMagic))
+	bw.writeBytes([]byte{binaryVersion})
+
+	bw.writeUvarint(uint64(len(vertices)))
+	prevVertexID := int64(0)
+	for _, vertex := range vertices {
+		bw.writeVarint(vertex.ID - prevVertexID)
+		bw.writeVarint(vertex.OrderPos)
+		bw.writeVarint(int64(vertex.Importance))
+		bw.writePoint(vertex.Geom)
+		prevVertexID = vertex.ID
+	}
+
+	bw.writeUvarint(uint64(len(expandedEdges)))
+	prevEdge := ExpandedEdge{}
+	for _, edge := range expandedEdges {
+		bw.writeVarint(edge.ID - prevEdge.ID)
+		bw.writeVarint(int64(edge.Source - prevEdge.Source))
+		bw.writeVarint(int64(edge.Target - edge.Source))
+		bw.writeFloat32(edge.CostMeters)
+		flags := byte(0)
+		if edge.WasOneway {
+			flags = 1
+		}
+		bw.writeBytes([]byte{flags})
+		bw.writeVarint(int64(edge.SourceOSMWayID - prevEdge.SourceOSMWayID))
+		bw.writeVarint(int64(edge.TargetOSMWayID - edge.SourceOSMWayID))
+		bw.writeVarint(int64(edge.SourceComponent.SourceNodeID - prevEdge.SourceComponent.SourceNodeID))
+		bw.writeVarint(int64(edge.SourceComponent.TargetNodeID - edge.SourceComponent.SourceNodeID))
+		bw.writeVarint(int64(edge.TargeComponent.SourceNodeID - edge.SourceComponent.TargetNodeID))
+		bw.writeVarint(int64(edge.TargeComponent.TargetNodeID - edge.TargeComponent.SourceNodeID))
+		bw.writeUvarint(uint64(len(edge.Geom)))
+		for _, pt := range edge.Geom {
+			bw.writePoint(pt)
+		}
+		prevEdge = edge
+	}
+
+	bw.writeUvarint(uint64(len(shortcuts)))
+	prevShortcutFrom := int64(0)
+	for _, shortcut := range shortcuts {
+		bw.writeVarint(shortcut.From - prevShortcutFrom)
+		bw.writeVarint(shortcut.To - shortcut.From)
+		bw.writeVarint(shortcut.Via - shortcut.From)
+		bw.writeFloat32(shortcut.Weight)
+		prevShortcutFrom = shortcut.From
+	}
+
+	if bw.err != nil {
+		return errors.Wrap(bw.err, "Can't write binary graph")
+	}
+	err := bw.w.Flush()
+	if err != nil {
+		return errors.Wrap(err, "Can't flush binary graph")
+	}
+	return nil
+}
+
+// ReadBinaryFile Reads expanded graph from file of binary format (see WriteBinary)
+func ReadBinaryFile(fname string) ([]ExpandedEdge, []Vertex, []Shortcut, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "Can't open binary file")
+	}
+	defer file.Close()
+	return ReadBinary(file)
+}
+
+// ReadBinary Reads expanded graph (edges, vertices and shortcuts) written by WriteBinary
+/*
+	Weights are restored with float32 precision and coordinates with 7 decimal places
+*/
+func ReadBinary(r io.Reader) ([]ExpandedEdge, []Vertex, []Shortcut, error) {
+	br := binaryReader{r: bufio.NewReader(r)}
+	header := br.readBytes(len(binaryMagic) + 1)
+	if br.err != nil {
+		return nil, nil, nil, errors.Wrap(br.err, "Can't read header of binary graph")
+	}
+	if string(header[:len(binaryMagic)]) != binaryMagic {
+		return nil, nil, nil, fmt.Errorf("Not a binary graph: bad magic string")
+	}
+	if version := header[len(binaryMagic)]; version != binaryVersion {
+		return nil, nil, nil, fmt.Errorf("Unsupported version of binary graph: %d", version)
+	}
+
+	verticesNum := br.readUvarint()
+	vertices := make([]Vertex, 0, preallocSize(verticesNum))
+	prevVertexID := int64(0)
+	for i := uint64(0); i < verticesNum && br.err == nil; i++ {
+		vertex := Vertex{}
+		vertex.ID = prevVertexID + br.readVarint()
+		vertex.OrderPos = br.readVarint()
+		vertex.Importance = int(br.readVarint())
+		vertex.Geom = br.readPoint()
+		vertices = append(vertices, vertex)
+		prevVertexID = vertex.ID
+	}
+
+	edgesNum := br.readUvarint()
+	expandedEdges := make([]ExpandedEdge, 0, preallocSize(edgesNum))
+	prevEdge := ExpandedEdge{}
+	for i := uint64(0); i < edgesNum && br.err == nil; i++ {
+		edge := ExpandedEdge{}
+		edge.ID = prevEdge.ID + br.readVarint()
+		edge.Source = prevEdge.Source + EdgeID(br.readVarint())
+		edge.Target = edge.Source + EdgeID(br.readVarint())
+		edge.CostMeters = br.readFloat32()
+		edge.WasOneway = br.readBytes(1)[0]&1 == 1
+		edge.SourceOSMWayID = prevEdge.SourceOSMWayID + osm.WayID(br.readVarint())
+		edge.TargetOSMWayID = edge.SourceOSMWayID + osm.WayID(br.readVarint())
+		edge.SourceComponent.SourceNodeID = prevEdge.SourceComponent.SourceNodeID + osm.NodeID(br.readVarint())
+		edge.SourceComponent.TargetNodeID = edge.SourceComponent.SourceNodeID + osm.NodeID(br.readVarint())
+		edge.TargeComponent.SourceNodeID = edge.SourceComponent.TargetNodeID + osm.NodeID(br.readVarint())
+		edge.TargeComponent.TargetNodeID = edge.TargeComponent.SourceNodeID + osm.NodeID(br.readVarint())
+		pointsNum := br.readUvarint()
+		edge.Geom = make([]GeoPoint, 0, preallocSize(pointsNum))
+		for j := uint64(0); j < pointsNum && br.err == nil; j++ {
+			edge.Geom = append(edge.Geom, br.readPoint())
+		}
+		expandedEdges = append(expandedEdges, edge)
+		prevEdge = edge
+	}
+
+	shortcutsNum := br.readUvarint()
+	shortcuts := make([]Shortcut, 0, preallocSize(shortcutsNum))
+	prevShortcutFrom := int64(0)
+	for i := uint64(0); i < shortcutsNum && br.err == nil; i++ {
+		shortcut := Shortcut{}
+		shortcut.From = prevShortcutFrom + br.readVarint()
+		shortcut.To = shortcut.From + br.readVarint()
+		shortcut.Via = shortcut.From + br.readVarint()
+		shortcut.Weight = br.readFloat32()
+		shortcuts = append(shortcuts, shortcut)
+		prevShortcutFrom = shortcut.From
+	}
+
+	if br.err != nil {
+		return nil, nil, nil, errors.Wrap(br.err, "Can't read binary graph")
+	}
+	return expandedEdges, vertices, shortcuts, nil
+}
+
+// ImportFromBinaryFile Loads contracted graph from file written by WriteBinaryFile
+/*
+	Vertices are created in order of file, order positions and importance of vertices and shortcuts are restored,
+	so graph is ready for queries the same way as graph imported via ch.ImportFromFile
+*/
+func ImportFromBinaryFile(fname string) (*ch.Graph, error) {
+	expandedEdges, vertices, shortcuts, err := ReadBinaryFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	return prepareContractedGraph(expandedEdges, vertices, shortcuts)
+}
+
+// prepareContractedGraph Prepares graph from already contracted data (mirrors ch.ImportFromFile)
+func prepareContractedGraph(expandedEdges []ExpandedEdge, vertices []Vertex, shortcuts []Shortcut) (*ch.Graph, error) {
+	graph := ch.Graph{}
+	for _, vertex := range vertices {
+		err := graph.CreateVertex(vertex.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Can't add vertex with external_ID = '%d'", vertex.ID))
+		}
+	}
+	for _, edge := range expandedEdges {
+		for _, vertexID := range []int64{int64(edge.Source), int64(edge.Target)} {
+			if _, ok := graph.FindVertex(vertexID); !ok {
+				return nil, fmt.Errorf("Vertex with Label = %d is not found in graph", vertexID)
+			}
+		}
+		err := graph.AddEdge(int64(edge.Source), int64(edge.Target), edge.CostMeters)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Can't add edge with source_internal_ID = '%d' and target_internal_ID = '%d'", edge.Source, edge.Target))
+		}
+	}
+	for _, vertex := range vertices {
+		vertexInternal, _ := graph.FindVertex(vertex.ID)
+		graph.Vertices[vertexInternal].SetOrderPos(vertex.OrderPos)
+		graph.Vertices[vertexInternal].SetImportance(vertex.Importance)
+	}
+	for _, shortcut := range shortcuts {
+		for _, vertexID := range []int64{shortcut.From, shortcut.To, shortcut.Via} {
+			if _, ok := graph.FindVertex(vertexID); !ok {
+				return nil, fmt.Errorf("Vertex with Label = %d is not found in graph", vertexID)
+			}
+		}
+		err := graph.AddEdge(shortcut.From, shortcut.To, shortcut.Weight)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Can't add shortcut with source_internal_ID = '%d' and target_internal_ID = '%d'", shortcut.From, shortcut.To))
+		}
+		err = graph.AddShortcut(shortcut.From, shortcut.To, shortcut.Via, shortcut.Weight)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Can't add shortcut with source_internal_ID = '%d' and target_internal_ID = '%d' to internal map", shortcut.From, shortcut.To))
+		}
+	}
+	return &graph, nil
+}
+
+// binaryWriter Writes primitives of binary format. First error is kept and further writes are ignored
+type binaryWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+	// Last written point
+	lon, lat int64
+}
+
+func (bw *binaryWriter) writeBytes(b []byte) {
+	if bw.err != nil {
+		return
+	}
+	_, bw.err = bw.w.Write(b)
+}
+
+func (bw *binaryWriter) writeUvarint(v uint64) {
+	n := binary.PutUvarint(bw.buf[:], v)
+	bw.writeBytes(bw.buf[:n])
+}
+
+func (bw *binaryWriter) writeVarint(v int64) {
+	n := binary.PutVarint(bw.buf[:], v)
+	bw.writeBytes(bw.buf[:n])
+}
+
+func (bw *binaryWriter) writeFloat32(v float64) {
+	binary.LittleEndian.PutUint32(bw.buf[:4], math.Float32bits(float32(v)))
+	bw.writeBytes(bw.buf[:4])
+}
+
+func (bw *binaryWriter) writePoint(pt GeoPoint) {
+	lon := int64(math.Round(pt.Lon * binaryCoordinatesScale))
+	lat := int64(math.Round(pt.Lat * binaryCoordinatesScale))
+	bw.writeVarint(lon - bw.lon)
+	bw.writeVarint(lat - bw.lat)
+	bw.lon, bw.lat = lon, lat
+}
+
+// binaryReader Reads primitives of binary format. First error is kept and further reads return zero values
+type binaryReader struct {
+	r   *bufio.Reader
+	err error
+	// Last read point
+	lon, lat int64
+}
+
+func (br *binaryReader) readBytes(n int) []byte {
+	b := make([]byte, n)
+	if br.err != nil {
+		return b
+	}
+	_, br.err = io.ReadFull(br.r, b)
+	return b
+}
+
+func (br *binaryReader) readUvarint() uint64 {
+	if br.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(br.r)
+	br.err = err
+	return v
+}
+
+func (br *binaryReader) readVarint() int64 {
+	if br.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(br.r)
+	br.err = err
+	return v
+}
+
+func (br *binaryReader) readFloat32() float64 {
+	b := br.readBytes(4)
+	return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+}
+
+func (br *binaryReader) readPoint() GeoPoint {
+	br.lon += br.readVarint()
+	br.lat += br.readVarint()
+	return GeoPoint{Lon: float64(br.lon) / binaryCoordinatesScale, Lat: float64(br.lat) / binaryCoordinatesScale}
+}
+
+// preallocSize Returns capacity for slice of given length read from binary file
+func preallocSize(n uint64) int {
+	if n > binaryMaxPrealloc {
+		return binaryMaxPrealloc
+	}
+	return int(n)
+}
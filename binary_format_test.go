@@ -0,0 +1,108 @@
+package osm2ch
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestBinaryFormatRoundTrip(t *testing.T) {
+	expandedEdges, _ := expandEdges(prepareGridEdges(5), expansionOptions{workers: 1})
+	for i := range expandedEdges {
+		expandedEdges[i].SourceComponent.TargetNodeID = 10000 + expandedEdges[i].SourceComponent.TargetNodeID
+		expandedEdges[i].WasOneway = i%3 == 0
+	}
+	graph, err := PrepareGraph(expandedEdges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.PrepareContractionHierarchies()
+	shortcuts, err := PrepareShortcuts(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vertices := PrepareVertices(graph, expandedEdges)
+
+	buf := bytes.Buffer{}
+	err = WriteBinary(&buf, expandedEdges, vertices, shortcuts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readEdges, readVertices, readShortcuts, err := ReadBinary(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samePoint := func(p, q GeoPoint) bool {
+		return math.Abs(p.Lon-q.Lon) < 1e-7 && math.Abs(p.Lat-q.Lat) < 1e-7
+	}
+	sameWeight := func(a, b float64) bool {
+		return math.Abs(a-b) <= 1e-6*math.Abs(a)
+	}
+	if len(readEdges) != len(expandedEdges) {
+		t.Fatalf("Number of edges should be %d, but got %d", len(expandedEdges), len(readEdges))
+	}
+	for i, edge := range expandedEdges {
+		read := readEdges[i]
+		if read.ID != edge.ID || read.Source != edge.Source || read.Target != edge.Target || read.WasOneway != edge.WasOneway ||
+			read.SourceOSMWayID != edge.SourceOSMWayID || read.TargetOSMWayID != edge.TargetOSMWayID ||
+			read.SourceComponent != edge.SourceComponent || read.TargeComponent != edge.TargeComponent {
+			t.Errorf("Edge #%d should be %+v, but got %+v", i, edge, read)
+		}
+		if !sameWeight(edge.CostMeters, read.CostMeters) {
+			t.Errorf("Weight of edge #%d should be %f, but got %f", i, edge.CostMeters, read.CostMeters)
+		}
+		if len(read.Geom) != len(edge.Geom) {
+			t.Fatalf("Geometry of edge #%d should have %d points, but got %d", i, len(edge.Geom), len(read.Geom))
+		}
+		for j := range edge.Geom {
+			if !samePoint(edge.Geom[j], read.Geom[j]) {
+				t.Errorf("Point #%d of edge #%d should be %v, but got %v", j, i, edge.Geom[j], read.Geom[j])
+			}
+		}
+	}
+	if len(readVertices) != len(vertices) {
+		t.Fatalf("Number of vertices should be %d, but got %d", len(vertices), len(readVertices))
+	}
+	for i, vertex := range vertices {
+		read := readVertices[i]
+		if read.ID != vertex.ID || read.OrderPos != vertex.OrderPos || read.Importance != vertex.Importance || !samePoint(read.Geom, vertex.Geom) {
+			t.Errorf("Vertex #%d should be %+v, but got %+v", i, vertex, read)
+		}
+	}
+	if len(readShortcuts) != len(shortcuts) {
+		t.Fatalf("Number of shortcuts should be %d, but got %d", len(shortcuts), len(readShortcuts))
+	}
+	for i, shortcut := range shortcuts {
+		read := readShortcuts[i]
+		if read.From != shortcut.From || read.To != shortcut.To || read.Via != shortcut.Via || !sameWeight(read.Weight, shortcut.Weight) {
+			t.Errorf("Shortcut #%d should be %+v, but got %+v", i, shortcut, read)
+		}
+	}
+
+	// Loaded graph should give the same answers as original one
+	loaded, err := prepareContractedGraph(readEdges, readVertices, readShortcuts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(vertices); i += 7 {
+		for j := 3; j < len(vertices); j += 11 {
+			source, target := vertices[i].ID, vertices[j].ID
+			expectedCost, expectedPath := graph.ShortestPath(source, target)
+			cost, path := loaded.ShortestPath(source, target)
+			if !sameWeight(expectedCost, cost) || len(expectedPath) != len(path) {
+				t.Errorf("Path %d -> %d should have cost %f and %d vertices, but got %f and %d", source, target, expectedCost, len(expectedPath), cost, len(path))
+			}
+		}
+	}
+
+	// Broken input
+	_, _, _, err = ReadBinary(bytes.NewReader(buf.Bytes()[:buf.Len()/2]))
+	if err == nil {
+		t.Errorf("Truncated input should cause an error")
+	}
+	_, _, _, err = ReadBinary(bytes.NewReader([]byte("OSM2CHB\x02")))
+	if err == nil {
+		t.Errorf("Unknown version should cause an error")
+	}
+}
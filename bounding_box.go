@@ -0,0 +1,133 @@
+package osm2ch
+
+import (
+	"math"
+	"sort"
+)
+
+// boundingBox represents bounding box of geometry (in degrees)
+type boundingBox struct {
+	minLon float64
+	minLat float64
+	maxLon float64
+	maxLat float64
+}
+
+// emptyBoundingBox Returns box which contains nothing, so it could be extended by any point
+func emptyBoundingBox() boundingBox {
+	return boundingBox{minLon: math.Inf(1), minLat: math.Inf(1), maxLon: math.Inf(-1), maxLat: math.Inf(-1)}
+}
+
+// lineBoundingBox Returns bounding box of given line
+func lineBoundingBox(pts []GeoPoint) boundingBox {
+	bbox := emptyBoundingBox()
+	for _, pt := range pts {
+		bbox.extendPoint(pt)
+	}
+	return bbox
+}
+
+// isEmpty Returns true if box has not been extended by any point
+func (bbox boundingBox) isEmpty() bool {
+	return bbox.minLon > bbox.maxLon
+}
+
+// extendPoint Extends box so it contains given point
+func (bbox *boundingBox) extendPoint(pt GeoPoint) {
+	bbox.minLon = math.Min(bbox.minLon, pt.Lon)
+	bbox.minLat = math.Min(bbox.minLat, pt.Lat)
+	bbox.maxLon = math.Max(bbox.maxLon, pt.Lon)
+	bbox.maxLat = math.Max(bbox.maxLat, pt.Lat)
+}
+
+// extend Extends box so it contains another box
+func (bbox *boundingBox) extend(other boundingBox) {
+	bbox.minLon = math.Min(bbox.minLon, other.minLon)
+	bbox.minLat = math.Min(bbox.minLat, other.minLat)
+	bbox.maxLon = math.Max(bbox.maxLon, other.maxLon)
+	bbox.maxLat = math.Max(bbox.maxLat, other.maxLat)
+}
+
+// hilbertOrder Returns indices of boxes sorted by position of their centers on Hilbert curve
+/*
+	Curve covers extent of all boxes with 2^16 x 2^16 cells. Boxes in the same cell keep their relative order.
+	Such order keeps spatially close boxes close in sequence, which is used to pack R-trees
+*/
+func hilbertOrder(boxes []boundingBox) []int {
+	extent := emptyBoundingBox()
+	for _, bbox := range boxes {
+		extent.extend(bbox)
+	}
+	width := extent.maxLon - extent.minLon
+	height := extent.maxLat - extent.minLat
+	const hilbertMax = (1 << 16) - 1
+	values := make([]uint32, len(boxes))
+	for i, bbox := range boxes {
+		x, y := uint32(0), uint32(0)
+		if width > 0 {
+			x = uint32(math.Floor(hilbertMax * ((bbox.minLon+bbox.maxLon)/2 - extent.minLon) / width))
+		}
+		if height > 0 {
+			y = uint32(math.Floor(hilbertMax * ((bbox.minLat+bbox.maxLat)/2 - extent.minLat) / height))
+		}
+		values[i] = hilbertValue(x, y)
+	}
+	order := make([]int, len(boxes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return values[order[i]] < values[order[j]]
+	})
+	return order
+}
+
+// hilbertValue Returns position of cell (x, y) on Hilbert curve of order 16
+/*
+	See ref. "Fast Hilbert curve generation, sorting, and range queries" (http://threadlocalmutex.com/?p=126)
+*/
+func hilbertValue(x, y uint32) uint32 {
+	a := x ^ y
+	b := 0xFFFF ^ a
+	c := 0xFFFF ^ (x | y)
+	d := x & (y ^ 0xFFFF)
+
+	A := a | (b >> 1)
+	B := (a >> 1) ^ a
+	C := ((c >> 1) ^ (b & (d >> 1))) ^ c
+	D := ((a & (c >> 1)) ^ (d >> 1)) ^ d
+
+	a, b, c, d = A, B, C, D
+	A = (a & (a >> 2)) ^ (b & (b >> 2))
+	B = (a & (b >> 2)) ^ (b & ((a ^ b) >> 2))
+	C ^= (a & (c >> 2)) ^ (b & (d >> 2))
+	D ^= (b & (c >> 2)) ^ ((a ^ b) & (d >> 2))
+
+	a, b, c, d = A, B, C, D
+	A = (a & (a >> 4)) ^ (b & (b >> 4))
+	B = (a & (b >> 4)) ^ (b & ((a ^ b) >> 4))
+	C ^= (a & (c >> 4)) ^ (b & (d >> 4))
+	D ^= (b & (c >> 4)) ^ ((a ^ b) & (d >> 4))
+
+	a, b, c, d = A, B, C, D
+	C ^= (a & (c >> 8)) ^ (b & (d >> 8))
+	D ^= (b & (c >> 8)) ^ ((a ^ b) & (d >> 8))
+
+	a = C ^ (C >> 1)
+	b = D ^ (D >> 1)
+
+	i0 := x ^ y
+	i1 := b | (0xFFFF ^ (i0 | a))
+
+	i0 = (i0 | (i0 << 8)) & 0x00FF00FF
+	i0 = (i0 | (i0 << 4)) & 0x0F0F0F0F
+	i0 = (i0 | (i0 << 2)) & 0x33333333
+	i0 = (i0 | (i0 << 1)) & 0x55555555
+
+	i1 = (i1 | (i1 << 8)) & 0x00FF00FF
+	i1 = (i1 | (i1 << 4)) & 0x0F0F0F0F
+	i1 = (i1 | (i1 << 2)) & 0x33333333
+	i1 = (i1 | (i1 << 1)) & 0x55555555
+
+	return (i1 << 1) | i0
+}
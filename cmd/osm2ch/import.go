@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LdDl/osm2ch"
+)
+
+// importOptions Options of import of OSM data shared by import itself (no subcommand), 'serve' and 'stats' subcommands
+type importOptions struct {
+	tags          []string
+	workers       int
+	deadEndUTurns bool
+	// Penalty for u-turn at dead end (in units of output weights)
+	uTurnPenalty float64
+	// Output weights are in meters instead of kilometers
+	meters bool
+	// Filename of persistent mapping between OSM data and IDs of vertices (optional)
+	idMapFileName string
+	// Minimum size of strongly connected components (negative value disables filtering) and filename of report of removed edges (optional)
+	sccMinSize int
+	sccReport  string
+}
+
+// configuration Returns configuration of import. Mapping of IDs is loaded if its filename is provided
+func (opts importOptions) configuration() (osm2ch.OsmConfiguration, error) {
+	cfg := osm2ch.OsmConfiguration{
+		EntityName:    "highway", // Currently we do not support others
+		Tags:          opts.tags,
+		Workers:       opts.workers,
+		DeadEndUTurns: opts.deadEndUTurns,
+		UTurnPenalty:  opts.uTurnPenaltyKm(),
+	}
+	if opts.idMapFileName != "" {
+		var err error
+		cfg.EdgeIDs, err = osm2ch.LoadEdgeIDMapping(opts.idMapFileName)
+		if err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// uTurnPenaltyKm Returns penalty for u-turn in kilometers (costs are evaluated in kilometers)
+func (opts importOptions) uTurnPenaltyKm() float64 {
+	if opts.meters {
+		return opts.uTurnPenalty / 1000.0
+	}
+	return opts.uTurnPenalty
+}
+
+// checkStateConfiguration Returns error if import flags which have been set explicitly differ from configuration of saved state
+/*
+	Configuration is taken from state when OsmChange diff is applied, so such flags would be ignored silently otherwise
+*/
+func checkStateConfiguration(cfg osm2ch.OsmConfiguration, opts importOptions, explicitFlags map[string]bool) error {
+	if explicitFlags["tags"] && !sameTags(cfg.Tags, opts.tags) {
+		return fmt.Errorf("Flag 'tags' differs from tags of saved state: '%s'. Full import is needed to change tags", strings.Join(cfg.Tags, ","))
+	}
+	if explicitFlags["uturns"] && cfg.DeadEndUTurns != opts.deadEndUTurns {
+		return fmt.Errorf("Flag 'uturns' differs from option of saved state: %t. Full import is needed to change it", cfg.DeadEndUTurns)
+	}
+	if explicitFlags["uturnpenalty"] && cfg.UTurnPenalty != opts.uTurnPenaltyKm() {
+		return fmt.Errorf("Flag 'uturnpenalty' differs from penalty of saved state: %v km. Full import is needed to change it", cfg.UTurnPenalty)
+	}
+	return nil
+}
+
+// sameTags Checks if two lists contain the same tags (order does not matter)
+func sameTags(a, b []string) bool {
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+// importState Imports state from *.osm.pbf files (see configuration). Mapping of IDs is updated if its filename is provided
+func importState(fnames []string, opts importOptions) (*osm2ch.GraphState, error) {
+	cfg, err := opts.configuration()
+	if err != nil {
+		return nil, err
+	}
+	state, err := osm2ch.ImportStateFromOSMFiles(fnames, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = saveEdgeIDs(state, opts)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// saveEdgeIDs Saves mapping of IDs of state if its filename is provided
+func saveEdgeIDs(state *osm2ch.GraphState, opts importOptions) error {
+	if opts.idMapFileName == "" {
+		return nil
+	}
+	return state.EdgeIDs().SaveToFile(opts.idMapFileName)
+}
+
+// prepareExpandedEdges Returns expanded edges of state filtered by strongly connected components (if enabled) with weights in output units
+func prepareExpandedEdges(state *osm2ch.GraphState, opts importOptions) ([]osm2ch.ExpandedEdge, error) {
+	expandedEdges := state.ExpandedEdges()
+	if opts.sccMinSize >= 0 {
+		fmt.Printf("Filtering strongly connected components...")
+		st := time.Now()
+		components := osm2ch.StronglyConnectedComponents(expandedEdges)
+		kept, removed := osm2ch.FilterComponents(expandedEdges, components, opts.sccMinSize)
+		fmt.Printf("Done in %v\n\tComponents: %d\n\tRemoved expanded edges: %d\n", time.Since(st), len(components), len(removed))
+		if opts.sccReport != "" {
+			report, err := osm2ch.PrepareComponentsGeoJSON(removed, components)
+			if err != nil {
+				return nil, err
+			}
+			err = ioutil.WriteFile(opts.sccReport, report, 0644)
+			if err != nil {
+				return nil, err
+			}
+		}
+		expandedEdges = kept
+	}
+	if opts.meters {
+		// Costs are evaluated in kilometers. Copy is needed since expanded edges are owned by state
+		expandedEdges = append([]osm2ch.ExpandedEdge{}, expandedEdges...)
+		for i := range expandedEdges {
+			expandedEdges[i].CostMeters *= 1000.0
+		}
+	}
+	return expandedEdges, nil
+}
+
+// prepareGraph Returns vertices of graph of expanded edges and its shortcuts if contraction is needed (empty otherwise)
+func prepareGraph(expandedEdges []osm2ch.ExpandedEdge, contract bool) ([]osm2ch.Vertex, []osm2ch.Shortcut, error) {
+	graph, err := osm2ch.PrepareGraph(expandedEdges)
+	if err != nil {
+		return nil, nil, err
+	}
+	shortcuts := []osm2ch.Shortcut{}
+	if contract {
+		fmt.Println("Starting contraction process....")
+		st := time.Now()
+		graph.PrepareContractionHierarchies()
+		fmt.Printf("Done contraction process in %v\n", time.Since(st))
+		shortcuts, err = osm2ch.PrepareShortcuts(graph)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return osm2ch.PrepareVertices(graph, expandedEdges), shortcuts, nil
+}
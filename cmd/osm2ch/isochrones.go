@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/LdDl/osm2ch"
+)
+
+// runIsochrones Builds isochrones for point over graph from CSV files and prints them as GeoJSON FeatureCollection
+/*
+	Point is snapped to the nearest vertex of expanded graph
+*/
+func runIsochrones(args []string) {
+	flags := flag.NewFlagSet("isochrones", flag.ExitOnError)
+	in := flags.String("in", "my_graph.csv", "Filename of edges CSV-file written by osm2ch (see 'in' flag of 'query' subcommand)")
+	pointStr := flags.String("point", "", "Source point as 'lon,lat'")
+	limitsStr := flags.String("limits", "", "Limits of cost separated by commas (in units of weights of graph), e.g. '500,1000,2000'. Every limit gives MultiPolygon feature. At most 16 limits are allowed")
+	cellSize := flags.Float64("cell", 100, "Size of cell (meters) of grid which reachable geometry is rasterized into. Smaller cells give more detailed polygons. Minimum is 10 meters, grid covering reachable geometry should not exceed 10 million cells")
+	flags.Parse(args)
+
+	pt, err := osm2ch.ParseGeoPoint(*pointStr)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	limits := []float64{}
+	for _, str := range strings.Split(*limitsStr, ",") {
+		limit, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+		if err != nil || limit < 0 {
+			fmt.Printf("Limits should be non-negative numbers separated by commas, but got '%s'\n", *limitsStr)
+			return
+		}
+		limits = append(limits, limit)
+	}
+	if len(limits) > osm2ch.IsochroneMaxLimits {
+		fmt.Printf("Number of limits should not exceed %d, but got %d\n", osm2ch.IsochroneMaxLimits, len(limits))
+		return
+	}
+	if !(*cellSize >= osm2ch.IsochroneMinCellSize) {
+		fmt.Printf("Flag 'cell' should be at least %g\n", osm2ch.IsochroneMinCellSize)
+		return
+	}
+	router, err := loadRouter(*in)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	vertex, _, ok := router.NearestVertex(pt)
+	if !ok {
+		fmt.Println("There are no vertices with geometry in graph")
+		return
+	}
+	isochrones, err := router.Isochrones(vertex.ID, limits, *cellSize)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	err = encoder.Encode(osm2ch.PrepareIsochronesGeoJSON(isochrones))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+}
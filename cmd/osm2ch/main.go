@@ -1,201 +1,156 @@
 package main
 
 import (
-	"encoding/csv"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/LdDl/ch"
 	"github.com/LdDl/osm2ch"
-	"github.com/pkg/errors"
 )
 
 var (
-	tagStr        = flag.String("tags", "motorway,primary,primary_link,road,secondary,secondary_link,residential,tertiary,tertiary_link,unclassified,trunk,trunk_link,motorway_link", "Set of needed tags (separated by commas)")
-	osmFileName   = flag.String("file", "my_graph.osm.pbf", "Filename of *.osm.pbf file (it has to be compressed)")
-	out           = flag.String("out", "my_graph.csv", "Filename of 'Comma-Separated Values' (CSV) formatted file. E.g.: if file name is 'map.csv' then 3 files will be produced: 'map.csv' (edges), 'map_vertices.csv', 'map_shortcuts.csv'")
-	geomFormat    = flag.String("geomf", "wkt", "Format of output geometry. Expected values: wkt / geojson")
+	tagStr        = flag.String("tags", "motorway,primary,primary_link,road,secondary,secondary_link,residential,tertiary,tertiary_link,unclassified,trunk,trunk_link,motorway_link", "Set of needed tags (separated by commas). With 'osc' flag tags are taken from state (explicit value should be the same)")
+	osmFileName   = flag.String("file", "my_graph.osm.pbf", "Filename of *.osm.pbf file (it has to be compressed). Several files (e.g. neighboring regional extracts) could be provided separated by commas: they will be merged into single graph")
+	out           = flag.String("out", "my_graph.csv", "Filename of 'Comma-Separated Values' (CSV) formatted file. E.g.: if file name is 'map.csv' then 3 files will be produced: 'map.csv' (edges), 'map_vertices.csv', 'map_shortcuts.csv'. For other output formats extension is replaced by format-specific one. If file name ends with '.gz' or '.zst' (e.g. 'map.csv.gz') then CSV files are compressed by gzip or Zstandard")
+	outputFormat  = flag.String("format", "csv", "Format of output. Expected values: csv / binary (single file with extension '.bin', could be loaded via osm2ch.ImportFromBinaryFile) / gpkg (GeoPackage with tables 'edges', 'vertices' and 'shortcuts') / postgis (SQL script for PostgreSQL with PostGIS, tables are named after 'out' file) / geojson (FeatureCollections of edges and vertices, shortcuts are not written) / geojsonl (the same as geojson, but newline-delimited) / fgb (FlatGeobuf files of edges and vertices with spatial index, shortcuts are not written) / graphml (GraphML file with geometry and weights as attributes, shortcuts are not written) / dimacs (9th DIMACS challenge '.gr' and '.co' files with weights in centimeters and '_dimacs_mapping.csv' with IDs of vertices)")
+	geomFormat    = flag.String("geomf", "wkt", "Format of output geometry. Expected values: wkt / geojson / wkb (hex-encoded) / polyline (Google encoded polyline with precision 5, points are in lat/lon order) / polyline6 (the same with precision 6)")
+	columns       = flag.String("columns", "", "Extra columns of edges CSV-file: tags of OSM ways separated by commas, e.g. 'name,highway,maxspeed,surface'. Every tag gives two columns: 'osm_way_from_<tag>' and 'osm_way_to_<tag>' (tags of source and target ways of expanded edge). Special value '@tags' gives all tags as JSON object")
+	tileZoom      = flag.Int("tilezoom", -1, "Zoom of slippy map tiles for splitting of output into per-tile edges and vertices files (csv format only). Every vertex is assigned to the tile which contains it, every edge - to the tile of its source vertex; manifest of tiles and cross-tile edges is written into '<out>_tiles.json'. Negative value disables tiling")
+	precision     = flag.Int("precision", osm2ch.DefaultGeometryPrecision, "Number of decimal digits of coordinates in output geometry (wkt / geojson / wkb) and in GeoJSON output (-format geojson / geojsonl), trailing zeros are omitted. Negative value means the shortest representation which keeps coordinates exactly. Polylines have fixed precision")
 	units         = flag.String("units", "km", "Units of output weights. Expected values: km for kilometers / m for meters")
 	doContraction = flag.Bool("contract", true, "Prepare contraction hierarchies?")
+	idMapFileName = flag.String("idmap", "", "Filename of persistent mapping between OSM data and IDs of vertices (CSV). If provided then IDs of already known vertices are kept and new vertices get new IDs; mapping file is updated after import. If file does not exist it will be created")
+	stateFileName = flag.String("state", "", "Filename of import state (gzipped gob). If provided then state is saved after import, so OsmChange diffs could be applied later via 'osc' flag. When 'osc' is provided too then state is loaded from this file and updated after applying diff")
+	oscFileName   = flag.String("osc", "", "Filename of OsmChange diff (*.osc or *.osc.gz). Requires 'state' flag. Graph is updated incrementally instead of reading 'file'")
+	deadEndUTurns = flag.Bool("uturns", true, "Allow u-turns at dead ends and boundaries of extract (u-turns at regular intersections are forbidden always). With 'osc' flag option is taken from state (explicit value should be the same)")
+	uTurnPenalty  = flag.Float64("uturnpenalty", 0, "Penalty for u-turn at dead end (in units of output weights, see 'units'). With 'osc' flag penalty is taken from state (explicit value should be the same)")
+	sccMinSize    = flag.Int("scc", -1, "Filtering of strongly connected components of expanded graph. Negative value disables filtering, 0 keeps the largest component only, N > 0 keeps all components having at least N vertices")
+	sccReport     = flag.String("sccreport", "", "Filename of GeoJSON file for expanded edges removed by 'scc' filtering (optional)")
+	workers       = flag.Int("workers", 0, "Number of workers for edge expanding technique. If it is less or equal to zero then number of logical CPUs is used")
 )
 
-func main() {
+// subcommands Subcommands of osm2ch (the first argument). Import of OSM data is done if no subcommand is given
+var subcommands = map[string]func(args []string){
+	"query":      runQuery,
+	"serve":      runServe,
+	"matrix":     runMatrix,
+	"isochrones": runIsochrones,
+	"validate":   runValidate,
+	"stats":      runStats,
+}
 
-	flag.Parse()
+func main() {
 
-	tags := strings.Split(*tagStr, ",")
-	cfg := osm2ch.OsmConfiguration{
-		EntityName: "highway", // Currrently we do not support others
-		Tags:       tags,
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			subcommand(os.Args[2:])
+			return
+		}
 	}
 
-	edgeExpandedGraph, err := osm2ch.ImportFromOSMFile(*osmFileName, &cfg)
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
+	flag.Parse()
 
-	fnamePart := strings.Split(*out, ".csv") // to guarantee proper filename and its extension
-	fnameEdges := fmt.Sprintf(fnamePart[0] + ".csv")
-	fnameVertices := fmt.Sprintf(fnamePart[0] + "_vertices.csv")
-	fnameShortcuts := fmt.Sprintf(fnamePart[0] + "_shortcuts.csv")
-	/* Edges file */
-	fileEdges, err := os.Create(fnameEdges)
-	if err != nil {
-		fmt.Println(err)
+	if _, ok := outputWriters[strings.ToLower(*outputFormat)]; !ok {
+		fmt.Printf("Unknown output format: '%s'\n", *outputFormat)
 		return
 	}
-	defer fileEdges.Close()
-	writerEdges := csv.NewWriter(fileEdges)
-	defer writerEdges.Flush()
-	writerEdges.Comma = ';'
-	// 		from_vertex_id - int64, ID of generated source vertex
-	// 		to_vertex_id - int64, ID of generated target vertex
-	// 		weight - float64, Weight of an edge (meters/kilometers)
-	//      geom - geometry (WKT or GeoJSON representation)
-	//      was_one_way - if edge was one way
-	//      edge_id - int64, ID of generated edge
-	// 		osm_way_from - int64, ID of source OSM Way
-	// 		osm_way_to - int64, ID of target OSM Way
-	// 		osm_way_from_source_node - int64, ID of first OSM Node in source OSM Way
-	// 		osm_way_from_target_node - int64, ID of last OSM Node in source OSM Way
-	// 		osm_way_to_source_node - int64, ID of first OSM Node in target OSM Way
-	// 		osm_way_to_target_node - int64, ID of last OSM Node in target OSM Way
-	err = writerEdges.Write([]string{"from_vertex_id", "to_vertex_id", "weight", "geom", "was_one_way", "edge_id", "osm_way_from", "osm_way_to", "osm_way_from_source_node", "osm_way_from_target_node", "osm_way_to_source_node", "osm_way_to_target_node"})
-	if err != nil {
-		fmt.Println(err)
+	if *tileZoom > osm2ch.MaxTileZoom {
+		fmt.Printf("Zoom of tiles should not be greater than %d\n", osm2ch.MaxTileZoom)
 		return
 	}
-
-	/* Vertices file */
-	fileVertices, err := os.Create(fnameVertices)
-	if err != nil {
-		fmt.Println(err)
+	if *tileZoom >= 0 && strings.ToLower(*outputFormat) != "csv" {
+		fmt.Println("Flag 'tilezoom' is supported for 'csv' output format only")
 		return
 	}
-	defer fileVertices.Close()
-	writerVertices := csv.NewWriter(fileVertices)
-	defer writerVertices.Flush()
-	writerVertices.Comma = ';'
-	// 		vertex_id - int64, ID of vertex
-	// 		order_pos - int, Position of vertex in hierarchies (evaluted by library)
-	// 		importance - int, Importance of vertex in graph (evaluted by library)
-	//      geom - geometry (WKT or GeoJSON representation)
-	err = writerVertices.Write([]string{"vertex_id", "order_pos", "importance", "geom"})
-	if err != nil {
-		fmt.Println(err)
+	if *columns != "" && strings.ToLower(*outputFormat) != "csv" {
+		fmt.Println("Flag 'columns' is supported for 'csv' output format only")
 		return
 	}
 
-	verticesGeoms := make(map[int64]osm2ch.GeoPoint)
-	graph := ch.Graph{}
+	opts := importOptions{
+		tags:          strings.Split(*tagStr, ","),
+		workers:       *workers,
+		deadEndUTurns: *deadEndUTurns,
+		uTurnPenalty:  *uTurnPenalty,
+		meters:        strings.ToLower(*units) == "m",
+		idMapFileName: *idMapFileName,
+		sccMinSize:    *sccMinSize,
+		sccReport:     *sccReport,
+	}
 
-	// Prepare graph and write edges
-	for _, edge := range edgeExpandedGraph {
-		source := int64(edge.Source)
-		target := int64(edge.Target)
-		err := graph.CreateVertex(source)
-		if err != nil {
-			err = errors.Wrap(err, "Can not create source vertex")
+	var state *osm2ch.GraphState
+	var err error
+	if *oscFileName != "" {
+		// Incremental update: previous state is needed. Mapping of IDs is the part of the state
+		if *stateFileName == "" {
+			fmt.Println("Flag 'state' is required when 'osc' is provided")
 			return
 		}
-		err = graph.CreateVertex(target)
+		state, err = osm2ch.LoadGraphState(*stateFileName, *workers)
 		if err != nil {
-			err = errors.Wrap(err, "Can not create source vertex")
+			fmt.Println(err)
 			return
 		}
-		cost := edge.CostMeters
-		if strings.ToLower(*units) == "m" {
-			cost *= 1000.0
-		}
-		err = graph.AddEdge(source, target, cost)
+		stateCfg := state.Configuration()
+		fmt.Printf("Configuration of state:\n\tTags: %s\n\tU-turns at dead ends: %t\n\tU-turn penalty: %v km\n", strings.Join(stateCfg.Tags, ","), stateCfg.DeadEndUTurns, stateCfg.UTurnPenalty)
+		explicitFlags := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) {
+			explicitFlags[f.Name] = true
+		})
+		err = checkStateConfiguration(stateCfg, opts, explicitFlags)
 		if err != nil {
-			err = errors.Wrap(err, "Can not wrap Source and Targed vertices as Edge")
+			fmt.Println(err)
 			return
 		}
-		if len(edge.Geom) < 2 {
-			fmt.Println("!!")
-			// Skip bad expanded edges
-			continue
-		}
-
-		geomStr := ""
-		if strings.ToLower(*geomFormat) == "geojson" {
-			geomStr = osm2ch.PrepareGeoJSONLinestring(edge.Geom)
-		} else {
-			geomStr = osm2ch.PrepareWKTLinestring(edge.Geom)
-		}
-
-		if _, ok := verticesGeoms[source]; !ok {
-			verticesGeoms[source] = osm2ch.GeoPoint{Lon: edge.Geom[0].Lon, Lat: edge.Geom[0].Lat}
-		}
-		if _, ok := verticesGeoms[target]; !ok {
-			verticesGeoms[target] = osm2ch.GeoPoint{Lon: edge.Geom[len(edge.Geom)-1].Lon, Lat: edge.Geom[len(edge.Geom)-1].Lat}
+		change, err := osm2ch.ReadChangeFile(*oscFileName)
+		if err != nil {
+			fmt.Println(err)
+			return
 		}
-
-		err = writerEdges.Write([]string{
-			fmt.Sprintf("%d", source),
-			fmt.Sprintf("%d", target),
-			fmt.Sprintf("%f", cost),
-			geomStr,
-			fmt.Sprintf("%t", edge.WasOneway),
-			fmt.Sprintf("%d", edge.ID),
-			fmt.Sprintf("%d", edge.SourceOSMWayID),
-			fmt.Sprintf("%d", edge.TargetOSMWayID),
-			fmt.Sprintf("%d", edge.SourceComponent.SourceNodeID), fmt.Sprintf("%d", edge.SourceComponent.TargetNodeID),
-			fmt.Sprintf("%d", edge.TargeComponent.SourceNodeID), fmt.Sprintf("%d", edge.TargeComponent.TargetNodeID),
-		})
-
+		err = state.ApplyChange(change)
 		if err != nil {
 			fmt.Println(err)
 			return
 		}
-	}
-
-	if *doContraction {
-		fmt.Println("Starting contraction process....")
-		st := time.Now()
-		graph.PrepareContractionHierarchies()
-		fmt.Printf("Done contraction process in %v\n", time.Since(st))
-	}
-
-	/* Write vertices */
-	vertices := graph.Vertices
-	for i := 0; i < len(vertices); i++ {
-		currentVertexExternal := vertices[i].Label
-		vertexGeom := verticesGeoms[currentVertexExternal]
-		geomStr := ""
-		if strings.ToLower(*geomFormat) == "geojson" {
-			geomStr = osm2ch.PrepareGeoJSONPoint(vertexGeom)
-		} else {
-			geomStr = osm2ch.PrepareWKTPoint(vertexGeom)
+		err = saveEdgeIDs(state, opts)
+		if err != nil {
+			fmt.Println(err)
+			return
 		}
-		// Write reference information about vertex
-		err = writerVertices.Write([]string{
-			fmt.Sprintf("%d", currentVertexExternal),
-			fmt.Sprintf("%d", graph.Vertices[i].OrderPos()),
-			fmt.Sprintf("%d", graph.Vertices[i].Importance()),
-			fmt.Sprintf("%s", geomStr),
-		})
+	} else {
+		state, err = importState(strings.Split(*osmFileName, ","), opts)
 		if err != nil {
 			fmt.Println(err)
 			return
 		}
 	}
 
-	if *doContraction {
-		/* Write shortcuts */
-		// 	from_vertex_id - int64, ID of source vertex
-		// 	to_vertex_id - int64, ID of arget vertex
-		// 	weight - float64, Weight of an edge
-		// 	via_vertex_id - int64, ID of vertex through which the shortcut exists
-		err = graph.ExportShortcutsToFile(fnameShortcuts)
+	if *stateFileName != "" {
+		err = state.SaveToFile(*stateFileName)
 		if err != nil {
 			fmt.Println(err)
 			return
 		}
 	}
+	edgeExpandedGraph, err := prepareExpandedEdges(state, opts)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	vertices, shortcuts, err := prepareGraph(edgeExpandedGraph, *doContraction)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("Writing output...")
+	st := time.Now()
+	err = outputWriters[strings.ToLower(*outputFormat)](edgeExpandedGraph, vertices, shortcuts, state)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Done in %v\n", time.Since(st))
 }
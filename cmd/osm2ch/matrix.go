@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LdDl/osm2ch"
+)
+
+// matrixPoint Origin or destination of distance matrix
+type matrixPoint struct {
+	ID    string
+	Point osm2ch.GeoPoint
+}
+
+// runMatrix Computes costs of shortest paths between every origin and every destination from CSV files
+/*
+	Points are snapped to the nearest vertices of expanded graph. Origins are processed in parallel
+*/
+func runMatrix(args []string) {
+	flags := flag.NewFlagSet("matrix", flag.ExitOnError)
+	in := flags.String("in", "my_graph.csv", "Filename of edges CSV-file written by osm2ch (see 'in' flag of 'query' subcommand)")
+	originsFname := flags.String("origins", "origins.csv", "Filename of CSV-file of origins with header and columns 'id', 'lon', 'lat' (delimiter is ',' or ';')")
+	destinationsFname := flags.String("destinations", "", "Filename of CSV-file of destinations (the same columns as for origins). If empty then origins are used")
+	outFname := flags.String("out", "matrix.csv", "Filename of output")
+	format := flags.String("format", "csv", "Format of output. Expected values: csv (columns 'origin_id', 'destination_id', 'cost'; cost is empty if there is no path) / json (object with 'origins', 'destinations' and 'costs' (rows are origins, null if there is no path))")
+	workers := flags.Int("workers", 0, "Number of workers for origins. If it is less or equal to zero then number of logical CPUs is used")
+	flags.Parse(args)
+
+	writeMatrix, ok := matrixWriters[strings.ToLower(*format)]
+	if !ok {
+		fmt.Printf("Unknown output format: '%s'\n", *format)
+		return
+	}
+	origins, err := readMatrixPoints(*originsFname)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	destinations := origins
+	if *destinationsFname != "" {
+		destinations, err = readMatrixPoints(*destinationsFname)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	fmt.Printf("Loading graph...")
+	st := time.Now()
+	router, err := loadRouter(*in)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Done in %v\n", time.Since(st))
+
+	snap := func(points []matrixPoint) ([]int64, error) {
+		vertices := make([]int64, len(points))
+		for i, pt := range points {
+			vertex, _, ok := router.NearestVertex(pt.Point)
+			if !ok {
+				return nil, fmt.Errorf("There are no vertices with geometry in graph")
+			}
+			vertices[i] = vertex.ID
+		}
+		return vertices, nil
+	}
+	sources, err := snap(origins)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	targets, err := snap(destinations)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("Computing %dx%d matrix...", len(sources), len(targets))
+	st = time.Now()
+	matrix := router.DistanceMatrix(sources, targets, *workers)
+	fmt.Printf("Done in %v\n", time.Since(st))
+
+	fmt.Printf("Writing output...")
+	st = time.Now()
+	file, err := os.Create(*outFname)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer file.Close()
+	bw := bufio.NewWriter(file)
+	err = writeMatrix(bw, origins, destinations, matrix)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	err = bw.Flush()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	err = file.Close()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Done in %v\n", time.Since(st))
+}
+
+// matrixWriters Writers for every supported value of 'format' flag of 'matrix' subcommand
+var matrixWriters = map[string]func(w io.Writer, origins, destinations []matrixPoint, matrix [][]float64) error{
+	"csv": func(w io.Writer, origins, destinations []matrixPoint, matrix [][]float64) error {
+		writer := csv.NewWriter(w)
+		writer.Comma = ';'
+		err := writer.Write([]string{"origin_id", "destination_id", "cost"})
+		if err != nil {
+			return err
+		}
+		for i := range origins {
+			for j := range destinations {
+				cost := ""
+				if matrix[i][j] >= 0 {
+					cost = strconv.FormatFloat(matrix[i][j], 'f', -1, 64)
+				}
+				err = writer.Write([]string{origins[i].ID, destinations[j].ID, cost})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	},
+	"json": func(w io.Writer, origins, destinations []matrixPoint, matrix [][]float64) error {
+		ids := func(points []matrixPoint) []string {
+			ans := make([]string, len(points))
+			for i := range points {
+				ans[i] = points[i].ID
+			}
+			return ans
+		}
+		costs := make([][]*float64, len(matrix))
+		for i := range matrix {
+			costs[i] = make([]*float64, len(matrix[i]))
+			for j := range matrix[i] {
+				if matrix[i][j] >= 0 {
+					costs[i][j] = &matrix[i][j]
+				}
+			}
+		}
+		return json.NewEncoder(w).Encode(map[string]interface{}{
+			"origins":      ids(origins),
+			"destinations": ids(destinations),
+			"costs":        costs,
+		})
+	},
+}
+
+// readMatrixPoints Reads points from CSV-file with columns 'id', 'lon' and 'lat'
+func readMatrixPoints(fname string) ([]matrixPoint, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	br := bufio.NewReader(file)
+	header, err := br.Peek(1024)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	reader := csv.NewReader(br)
+	firstLine := strings.SplitN(string(header), "\n", 2)[0]
+	if strings.Contains(firstLine, ";") {
+		reader.Comma = ';'
+	}
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Can't read '%s': %v", fname, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("File '%s' is empty", fname)
+	}
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"id", "lon", "lat"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("Column '%s' is not found in '%s'", name, fname)
+		}
+	}
+	points := make([]matrixPoint, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		pt, err := osm2ch.ParseGeoPoint(row[columns["lon"]] + "," + row[columns["lat"]])
+		if err != nil {
+			return nil, fmt.Errorf("Bad point on line %d of '%s': %v", i+2, fname, err)
+		}
+		points = append(points, matrixPoint{ID: row[columns["id"]], Point: pt})
+	}
+	return points, nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/LdDl/osm2ch"
+)
+
+// outputWriter Writes expanded graph, its vertices and shortcuts (empty if contraction is disabled)
+type outputWriter func(expandedEdges []osm2ch.ExpandedEdge, vertices []osm2ch.Vertex, shortcuts []osm2ch.Shortcut, state *osm2ch.GraphState) error
+
+// outputWriters Writers for every supported value of 'format' flag
+var outputWriters = map[string]outputWriter{
+	"csv": writeCSV,
+	"binary": func(expandedEdges []osm2ch.ExpandedEdge, vertices []osm2ch.Vertex, shortcuts []osm2ch.Shortcut, state *osm2ch.GraphState) error {
+		return osm2ch.WriteBinaryFile(outputFileName(".bin"), expandedEdges, vertices, shortcuts)
+	},
+	"gpkg": func(expandedEdges []osm2ch.ExpandedEdge, vertices []osm2ch.Vertex, shortcuts []osm2ch.Shortcut, state *osm2ch.GraphState) error {
+		return osm2ch.WriteGeoPackageFile(outputFileName(".gpkg"), expandedEdges, vertices, shortcuts)
+	},
+	"geojson": func(expandedEdges []osm2ch.ExpandedEdge, vertices []osm2ch.Vertex, shortcuts []osm2ch.Shortcut, state *osm2ch.GraphState) error {
+		return osm2ch.WriteGeoJSONFiles(outputFileName(".geojson"), outputFileName("_vertices.geojson"), expandedEdges, vertices, false, *precision)
+	},
+	"geojsonl": func(expandedEdges []osm2ch.ExpandedEdge, vertices []osm2ch.Vertex, shortcuts []osm2ch.Shortcut, state *osm2ch.GraphState) error {
+		return osm2ch.WriteGeoJSONFiles(outputFileName(".geojsonl"), outputFileName("_vertices.geojsonl"), expandedEdges, vertices, true, *precision)
+	},
+	"fgb": func(expandedEdges []osm2ch.ExpandedEdge, vertices []osm2ch.Vertex, shortcuts []osm2ch.Shortcut, state *osm2ch.GraphState) error {
+		return osm2ch.WriteFlatGeobufFiles(outputFileName(".fgb"), outputFileName("_vertices.fgb"), expandedEdges, vertices)
+	},
+	"graphml": func(expandedEdges []osm2ch.ExpandedEdge, vertices []osm2ch.Vertex, shortcuts []osm2ch.Shortcut, state *osm2ch.GraphState) error {
+		return osm2ch.WriteGraphMLFile(outputFileName(".graphml"), expandedEdges, vertices)
+	},
+	"dimacs": func(expandedEdges []osm2ch.ExpandedEdge, vertices []osm2ch.Vertex, shortcuts []osm2ch.Shortcut, state *osm2ch.GraphState) error {
+		// DIMACS weights are integers: write them in centimeters
+		weightFactor := 100.0
+		if strings.ToLower(*units) != "m" {
+			weightFactor *= 1000.0
+		}
+		return osm2ch.WriteDIMACSFiles(outputFileName(".gr"), outputFileName(".co"), outputFileName("_dimacs_mapping.csv"), expandedEdges, vertices, weightFactor)
+	},
+	"postgis": func(expandedEdges []osm2ch.ExpandedEdge, vertices []osm2ch.Vertex, shortcuts []osm2ch.Shortcut, state *osm2ch.GraphState) error {
+		// Tables are named the same way as CSV files
+		tableName := filepath.Base(outputFileName(""))
+		return osm2ch.WritePostGISDumpFile(outputFileName(".sql"), tableName, expandedEdges, vertices, shortcuts)
+	},
+}
+
+// outputFileName Returns filename based on 'out' flag with extension (and compression suffix) replaced by given one
+func outputFileName(ext string) string {
+	fname := osm2ch.TrimCompression(*out)
+	return strings.TrimSuffix(fname, filepath.Ext(fname)) + ext
+}
+
+// prepareLinestringGeom Returns representation of LineString in format defined by 'geomf' and 'precision' flags (WKT by default)
+func prepareLinestringGeom(pts []osm2ch.GeoPoint) string {
+	switch strings.ToLower(*geomFormat) {
+	case "geojson":
+		return osm2ch.PrepareGeoJSONLinestringWithPrecision(pts, *precision)
+	case "wkb":
+		return osm2ch.PrepareHexWKBLinestringWithPrecision(pts, *precision)
+	case "polyline":
+		return osm2ch.PreparePolylineLinestring(pts, 5)
+	case "polyline6":
+		return osm2ch.PreparePolylineLinestring(pts, 6)
+	default:
+		return osm2ch.PrepareWKTLinestringWithPrecision(pts, *precision)
+	}
+}
+
+// preparePointGeom Returns representation of Point in format defined by 'geomf' and 'precision' flags (WKT by default)
+func preparePointGeom(pt osm2ch.GeoPoint) string {
+	switch strings.ToLower(*geomFormat) {
+	case "geojson":
+		return osm2ch.PrepareGeoJSONPointWithPrecision(pt, *precision)
+	case "wkb":
+		return osm2ch.PrepareHexWKBPointWithPrecision(pt, *precision)
+	case "polyline":
+		return osm2ch.PreparePolylinePoint(pt, 5)
+	case "polyline6":
+		return osm2ch.PreparePolylinePoint(pt, 6)
+	default:
+		return osm2ch.PrepareWKTPointWithPrecision(pt, *precision)
+	}
+}
+
+// writeCSV Writes edges, vertices and shortcuts into three CSV files (or into CSV files per tile if tiling is enabled)
+func writeCSV(expandedEdges []osm2ch.ExpandedEdge, vertices []osm2ch.Vertex, shortcuts []osm2ch.Shortcut, state *osm2ch.GraphState) error {
+	if *tileZoom >= 0 {
+		return writeTiledCSV(expandedEdges, vertices, shortcuts, state)
+	}
+	fnameBase, compression := csvFileNameBase(*out)
+	_, err := writeEdgesCSV(fnameBase+".csv"+compression, expandedEdges, state)
+	if err != nil {
+		return err
+	}
+	err = writeVerticesCSV(fnameBase+"_vertices.csv"+compression, vertices)
+	if err != nil {
+		return err
+	}
+	if !*doContraction {
+		return nil
+	}
+	return writeShortcutsCSV(fnameBase+"_shortcuts.csv"+compression, shortcuts)
+}
+
+// csvFileNameBase Returns filename of edges CSV-file (e.g. 'out' flag) without '.csv' extension and compression suffix ('.gz', '.zst' or empty string)
+func csvFileNameBase(fname string) (string, string) {
+	// Files are compressed if filename ends with '.gz' or '.zst'
+	compression := string(osm2ch.DetectCompression(fname))
+	fnamePart := strings.Split(osm2ch.TrimCompression(fname), ".csv") // to guarantee proper filename and its extension
+	return fnamePart[0], compression
+}
+
+// writeEdgesCSV Writes expanded edges into CSV file and returns number of written rows (edges without proper geometry are skipped)
+func writeEdgesCSV(fnameEdges string, expandedEdges []osm2ch.ExpandedEdge, state *osm2ch.GraphState) (int, error) {
+	fileEdges, err := osm2ch.CreateCompressedFile(fnameEdges)
+	if err != nil {
+		return 0, err
+	}
+	defer fileEdges.Close()
+	writerEdges := csv.NewWriter(fileEdges)
+	writerEdges.Comma = ';'
+	// 		from_vertex_id - int64, ID of generated source vertex
+	// 		to_vertex_id - int64, ID of generated target vertex
+	// 		weight - float64, Weight of an edge (meters/kilometers)
+	//      geom - geometry (WKT or GeoJSON representation)
+	//      was_one_way - if edge was one way
+	//      edge_id - int64, ID of generated edge
+	// 		osm_way_from - int64, ID of source OSM Way
+	// 		osm_way_to - int64, ID of target OSM Way
+	// 		osm_way_from_source_node - int64, ID of first OSM Node in source OSM Way
+	// 		osm_way_from_target_node - int64, ID of last OSM Node in source OSM Way
+	// 		osm_way_to_source_node - int64, ID of first OSM Node in target OSM Way
+	// 		osm_way_to_target_node - int64, ID of last OSM Node in target OSM Way
+	// 		osm_way_from_<tag>, osm_way_to_<tag> - string, Value of tag of source / target OSM Way for every tag in 'columns' flag
+	// 		osm_way_from_@tags, osm_way_to_@tags - string, All tags of source / target OSM Way as JSON object (if '@tags' is in 'columns' flag)
+	tagColumns := osm2ch.ParseTagColumns(*columns)
+	header := []string{"from_vertex_id", "to_vertex_id", "weight", "geom", "was_one_way", "edge_id", "osm_way_from", "osm_way_to", "osm_way_from_source_node", "osm_way_from_target_node", "osm_way_to_source_node", "osm_way_to_target_node"}
+	header = append(header, osm2ch.PrepareTagColumnsHeader(tagColumns)...)
+	err = writerEdges.Write(header)
+	if err != nil {
+		return 0, err
+	}
+	rowsNum := 0
+	for _, edge := range expandedEdges {
+		if len(edge.Geom) < 2 {
+			fmt.Println("!!")
+			// Skip bad expanded edges
+			continue
+		}
+		geomStr := prepareLinestringGeom(edge.Geom)
+		row := []string{
+			fmt.Sprintf("%d", edge.Source),
+			fmt.Sprintf("%d", edge.Target),
+			fmt.Sprintf("%f", edge.CostMeters),
+			geomStr,
+			fmt.Sprintf("%t", edge.WasOneway),
+			fmt.Sprintf("%d", edge.ID),
+			fmt.Sprintf("%d", edge.SourceOSMWayID),
+			fmt.Sprintf("%d", edge.TargetOSMWayID),
+			fmt.Sprintf("%d", edge.SourceComponent.SourceNodeID), fmt.Sprintf("%d", edge.SourceComponent.TargetNodeID),
+			fmt.Sprintf("%d", edge.TargeComponent.SourceNodeID), fmt.Sprintf("%d", edge.TargeComponent.TargetNodeID),
+		}
+		if len(tagColumns) > 0 {
+			row = append(row, osm2ch.PrepareTagColumns(state.WayTags(edge.SourceOSMWayID), state.WayTags(edge.TargetOSMWayID), tagColumns)...)
+		}
+		err = writerEdges.Write(row)
+		if err != nil {
+			return 0, err
+		}
+		rowsNum++
+	}
+	writerEdges.Flush()
+	if err = writerEdges.Error(); err != nil {
+		return 0, err
+	}
+	return rowsNum, fileEdges.Close()
+}
+
+// writeVerticesCSV Writes vertices into CSV file
+func writeVerticesCSV(fnameVertices string, vertices []osm2ch.Vertex) error {
+	fileVertices, err := osm2ch.CreateCompressedFile(fnameVertices)
+	if err != nil {
+		return err
+	}
+	defer fileVertices.Close()
+	writerVertices := csv.NewWriter(fileVertices)
+	writerVertices.Comma = ';'
+	// 		vertex_id - int64, ID of vertex
+	// 		order_pos - int, Position of vertex in hierarchies (evaluted by library)
+	// 		importance - int, Importance of vertex in graph (evaluted by library)
+	//      geom - geometry (WKT or GeoJSON representation)
+	err = writerVertices.Write([]string{"vertex_id", "order_pos", "importance", "geom"})
+	if err != nil {
+		return err
+	}
+	for _, vertex := range vertices {
+		geomStr := preparePointGeom(vertex.Geom)
+		// Write reference information about vertex
+		err = writerVertices.Write([]string{
+			fmt.Sprintf("%d", vertex.ID),
+			fmt.Sprintf("%d", vertex.OrderPos),
+			fmt.Sprintf("%d", vertex.Importance),
+			geomStr,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writerVertices.Flush()
+	if err = writerVertices.Error(); err != nil {
+		return err
+	}
+	return fileVertices.Close()
+}
+
+// writeShortcutsCSV Writes shortcuts into CSV file
+func writeShortcutsCSV(fnameShortcuts string, shortcuts []osm2ch.Shortcut) error {
+	// 	from_vertex_id - int64, ID of source vertex
+	// 	to_vertex_id - int64, ID of arget vertex
+	// 	weight - float64, Weight of an edge
+	// 	via_vertex_id - int64, ID of vertex through which the shortcut exists
+	fileShortcuts, err := osm2ch.CreateCompressedFile(fnameShortcuts)
+	if err != nil {
+		return err
+	}
+	defer fileShortcuts.Close()
+	writerShortcuts := csv.NewWriter(fileShortcuts)
+	writerShortcuts.Comma = ';'
+	err = writerShortcuts.Write([]string{"from_vertex_id", "to_vertex_id", "weight", "via_vertex_id"})
+	if err != nil {
+		return err
+	}
+	for _, shortcut := range shortcuts {
+		err = writerShortcuts.Write([]string{
+			fmt.Sprintf("%d", shortcut.From),
+			fmt.Sprintf("%d", shortcut.To),
+			strconv.FormatFloat(shortcut.Weight, 'f', -1, 64),
+			fmt.Sprintf("%d", shortcut.Via),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writerShortcuts.Flush()
+	if err = writerShortcuts.Error(); err != nil {
+		return err
+	}
+	return fileShortcuts.Close()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/LdDl/osm2ch"
+)
+
+// runQuery Finds shortest path between two points over graph from CSV files and prints it as GeoJSON feature
+/*
+	Points are snapped to the nearest vertices of expanded graph
+*/
+func runQuery(args []string) {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	in := flags.String("in", "my_graph.csv", "Filename of edges CSV-file written by osm2ch. Vertices and shortcuts are read from files named the same way as for 'out' flag of import ('map.csv' -> 'map_vertices.csv', 'map_shortcuts.csv'); shortcuts file is optional")
+	fromStr := flags.String("from", "", "Source point as 'lon,lat'")
+	toStr := flags.String("to", "", "Target point as 'lon,lat'")
+	flags.Parse(args)
+
+	from, err := osm2ch.ParseGeoPoint(*fromStr)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	to, err := osm2ch.ParseGeoPoint(*toStr)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	router, err := loadRouter(*in)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	route, err := router.Route(from, to)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(route.GeoJSONFeature())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+}
+
+// loadRouter Loads graph from CSV files for given filename of edges CSV-file (see csvFileNameBase)
+func loadRouter(fnameEdges string) (*osm2ch.Router, error) {
+	fnameBase, compression := csvFileNameBase(fnameEdges)
+	fnameShortcuts := fnameBase + "_shortcuts.csv" + compression
+	if _, err := os.Stat(fnameShortcuts); os.IsNotExist(err) {
+		// Graph has not been contracted
+		fnameShortcuts = ""
+	}
+	return osm2ch.NewRouterFromCSVFiles(fnameBase+".csv"+compression, fnameBase+"_vertices.csv"+compression, fnameShortcuts)
+}
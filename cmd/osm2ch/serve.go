@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/LdDl/osm2ch"
+)
+
+// runServe Serves shortest path queries over HTTP (see osm2ch.NewHTTPHandler)
+/*
+	Graph is loaded from CSV files or imported from *.osm.pbf files in-process. Server is stopped gracefully on SIGINT / SIGTERM
+*/
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	in := flags.String("in", "my_graph.csv", "Filename of edges CSV-file written by osm2ch (see 'in' flag of 'query' subcommand). Ignored if 'file' is provided")
+	osmFiles := flags.String("file", "", "Filename of *.osm.pbf file (several files could be provided separated by commas). If provided then graph is imported in-process instead of reading CSV files")
+	tagStr := flags.String("tags", "motorway,primary,primary_link,road,secondary,secondary_link,residential,tertiary,tertiary_link,unclassified,trunk,trunk_link,motorway_link", "Set of needed tags (separated by commas) for in-process import")
+	units := flags.String("units", "km", "Units of weights for in-process import. Expected values: km for kilometers / m for meters")
+	workers := flags.Int("workers", 0, "Number of workers for edge expanding technique (in-process import). If it is less or equal to zero then number of logical CPUs is used")
+	deadEndUTurns := flags.Bool("uturns", true, "Allow u-turns at dead ends and boundaries of extract for in-process import (u-turns at regular intersections are forbidden always)")
+	uTurnPenalty := flags.Float64("uturnpenalty", 0, "Penalty for u-turn at dead end for in-process import (in units of weights, see 'units')")
+	idMapFileName := flags.String("idmap", "", "Filename of persistent mapping between OSM data and IDs of vertices for in-process import (see 'idmap' flag of import)")
+	sccMinSize := flags.Int("scc", -1, "Filtering of strongly connected components for in-process import. Negative value disables filtering, 0 keeps the largest component only, N > 0 keeps all components having at least N vertices")
+	addr := flags.String("addr", ":8080", "Address to listen on")
+	shutdownTimeout := flags.Duration("shutdown", 10*time.Second, "Timeout for completion of active requests on shutdown")
+	flags.Parse(args)
+
+	var router *osm2ch.Router
+	var err error
+	if *osmFiles != "" {
+		router, err = importRouter(strings.Split(*osmFiles, ","), importOptions{
+			tags:          strings.Split(*tagStr, ","),
+			workers:       *workers,
+			deadEndUTurns: *deadEndUTurns,
+			uTurnPenalty:  *uTurnPenalty,
+			meters:        strings.ToLower(*units) == "m",
+			idMapFileName: *idMapFileName,
+			sccMinSize:    *sccMinSize,
+		})
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	} else {
+		fmt.Printf("Loading graph...")
+		st := time.Now()
+		router, err = loadRouter(*in)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Done in %v\n", time.Since(st))
+	}
+
+	server := &http.Server{
+		Addr:    *addr,
+		Handler: osm2ch.NewHTTPHandler(router),
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	fmt.Printf("Listening on %s\n", *addr)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err = <-serverErr:
+		fmt.Println(err)
+		return
+	case <-stop:
+	}
+	fmt.Printf("Shutting down...")
+	st := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Done in %v\n", time.Since(st))
+}
+
+// importRouter Imports graph from *.osm.pbf files (see importState) and contracts it
+func importRouter(fnames []string, opts importOptions) (*osm2ch.Router, error) {
+	state, err := importState(fnames, opts)
+	if err != nil {
+		return nil, err
+	}
+	expandedEdges, err := prepareExpandedEdges(state, opts)
+	if err != nil {
+		return nil, err
+	}
+	vertices, shortcuts, err := prepareGraph(expandedEdges, true)
+	if err != nil {
+		return nil, err
+	}
+	return osm2ch.NewRouter(expandedEdges, vertices, shortcuts)
+}
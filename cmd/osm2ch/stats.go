@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/LdDl/osm2ch"
+)
+
+// runStats Imports graph from *.osm.pbf files (or loads saved import state) and writes statistics and data-quality report
+/*
+	References to nodes missing in OSM data do not stop import: they are dropped and reported
+*/
+func runStats(args []string) {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	osmFiles := flags.String("file", "my_graph.osm.pbf", "Filename of *.osm.pbf file (several files could be provided separated by commas). Ignored if 'state' is provided")
+	stateFname := flags.String("state", "", "Filename of import state (see 'state' flag of import). If provided then statistics are evaluated for saved state (missing nodes are not known then)")
+	tagStr := flags.String("tags", "motorway,primary,primary_link,road,secondary,secondary_link,residential,tertiary,tertiary_link,unclassified,trunk,trunk_link,motorway_link", "Set of needed tags (separated by commas)")
+	deadEndUTurns := flags.Bool("uturns", true, "Allow u-turns at dead ends and boundaries of extract (affects transitions of expanded graph)")
+	workers := flags.Int("workers", 0, "Number of workers for edge expanding technique. If it is less or equal to zero then number of logical CPUs is used")
+	outFname := flags.String("out", "my_graph_stats.json", "Filename of report")
+	format := flags.String("format", "json", "Format of report. Expected values: json / md (Markdown with tables)")
+	flags.Parse(args)
+
+	writeStats, ok := statsWriters[strings.ToLower(*format)]
+	if !ok {
+		fmt.Printf("Unknown report format: '%s'\n", *format)
+		return
+	}
+
+	var stats osm2ch.GraphStats
+	if *stateFname != "" {
+		fmt.Printf("Loading state...")
+		st := time.Now()
+		state, err := osm2ch.LoadGraphState(*stateFname, *workers)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Done in %v\n", time.Since(st))
+		stats = state.Stats()
+	} else {
+		opts := importOptions{
+			tags:          strings.Split(*tagStr, ","),
+			workers:       *workers,
+			deadEndUTurns: *deadEndUTurns,
+		}
+		cfg, err := opts.configuration()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		stats, err = osm2ch.ImportStatsFromOSMFiles(strings.Split(*osmFiles, ","), &cfg)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	fmt.Printf("Writing report...")
+	st := time.Now()
+	file, err := os.Create(*outFname)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer file.Close()
+	bw := bufio.NewWriter(file)
+	err = writeStats(stats, bw)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	err = bw.Flush()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	err = file.Close()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Done in %v\n", time.Since(st))
+}
+
+// statsWriters Writers for every supported value of 'format' flag of 'stats' subcommand
+var statsWriters = map[string]func(stats osm2ch.GraphStats, w io.Writer) error{
+	"json": osm2ch.GraphStats.WriteJSON,
+	"md":   osm2ch.GraphStats.WriteMarkdown,
+}
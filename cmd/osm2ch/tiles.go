@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/LdDl/osm2ch"
+)
+
+// tilesManifest Description of tiled output: list of tiles and expanded edges connecting them
+type tilesManifest struct {
+	Zoom           int                     `json:"zoom"`
+	ShortcutsFile  string                  `json:"shortcuts_file,omitempty"`
+	Tiles          []tileManifest          `json:"tiles"`
+	CrossTileEdges []crossTileEdgeManifest `json:"cross_tile_edges"`
+}
+
+type tileManifest struct {
+	Tile    string `json:"tile"`
+	Quadkey string `json:"quadkey"`
+	Z       int    `json:"z"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	// West, south, east, north
+	BBox              [4]float64 `json:"bbox"`
+	EdgesFile         string     `json:"edges_file"`
+	VerticesFile      string     `json:"vertices_file"`
+	EdgesNum          int        `json:"edges"`
+	VerticesNum       int        `json:"vertices"`
+	CrossTileEdgesNum int        `json:"cross_tile_edges"`
+}
+
+type crossTileEdgeManifest struct {
+	EdgeID       int64   `json:"edge_id"`
+	FromVertexID int64   `json:"from_vertex_id"`
+	ToVertexID   int64   `json:"to_vertex_id"`
+	Weight       float64 `json:"weight"`
+	FromTile     string  `json:"from_tile"`
+	ToTile       string  `json:"to_tile"`
+}
+
+// writeTiledCSV Writes edges and vertices into CSV files per tile, shortcuts into single CSV file and manifest of tiles (JSON)
+/*
+	E.g.: if file name is 'map.csv' and zoom is 10 then files 'map_10_<x>_<y>.csv' (edges which start in tile),
+	'map_10_<x>_<y>_vertices.csv', 'map_shortcuts.csv' (shortcuts for whole graph) and 'map_tiles.json' will be produced
+*/
+func writeTiledCSV(expandedEdges []osm2ch.ExpandedEdge, vertices []osm2ch.Vertex, shortcuts []osm2ch.Shortcut, state *osm2ch.GraphState) error {
+	tiles, crossTileEdges, err := osm2ch.SplitIntoTiles(expandedEdges, vertices, *tileZoom)
+	if err != nil {
+		return err
+	}
+	fnameBase, compression := csvFileNameBase(*out)
+	manifest := tilesManifest{
+		Zoom:           *tileZoom,
+		Tiles:          make([]tileManifest, 0, len(tiles)),
+		CrossTileEdges: make([]crossTileEdgeManifest, 0, len(crossTileEdges)),
+	}
+	crossTileEdgesNum := make(map[osm2ch.Tile]int)
+	for _, edge := range crossTileEdges {
+		crossTileEdgesNum[edge.SourceTile]++
+		manifest.CrossTileEdges = append(manifest.CrossTileEdges, crossTileEdgeManifest{
+			EdgeID:       edge.ID,
+			FromVertexID: int64(edge.Source),
+			ToVertexID:   int64(edge.Target),
+			Weight:       edge.CostMeters,
+			FromTile:     edge.SourceTile.String(),
+			ToTile:       edge.TargetTile.String(),
+		})
+	}
+	for _, tile := range tiles {
+		tileBase := fmt.Sprintf("%s_%d_%d_%d", fnameBase, tile.Tile.Z, tile.Tile.X, tile.Tile.Y)
+		fnameEdges := tileBase + ".csv" + compression
+		fnameVertices := tileBase + "_vertices.csv" + compression
+		edgesNum, err := writeEdgesCSV(fnameEdges, tile.ExpandedEdges, state)
+		if err != nil {
+			return err
+		}
+		err = writeVerticesCSV(fnameVertices, tile.Vertices)
+		if err != nil {
+			return err
+		}
+		sw, ne := tile.Tile.Bounds()
+		manifest.Tiles = append(manifest.Tiles, tileManifest{
+			Tile:              tile.Tile.String(),
+			Quadkey:           tile.Tile.Quadkey(),
+			Z:                 tile.Tile.Z,
+			X:                 tile.Tile.X,
+			Y:                 tile.Tile.Y,
+			BBox:              [4]float64{sw.Lon, sw.Lat, ne.Lon, ne.Lat},
+			EdgesFile:         filepath.Base(fnameEdges),
+			VerticesFile:      filepath.Base(fnameVertices),
+			EdgesNum:          edgesNum,
+			VerticesNum:       len(tile.Vertices),
+			CrossTileEdgesNum: crossTileEdgesNum[tile.Tile],
+		})
+	}
+	if *doContraction {
+		fnameShortcuts := fnameBase + "_shortcuts.csv" + compression
+		err = writeShortcutsCSV(fnameShortcuts, shortcuts)
+		if err != nil {
+			return err
+		}
+		manifest.ShortcutsFile = filepath.Base(fnameShortcuts)
+	}
+
+	fileManifest, err := os.Create(fnameBase + "_tiles.json")
+	if err != nil {
+		return err
+	}
+	defer fileManifest.Close()
+	encoder := json.NewEncoder(fileManifest)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(manifest)
+	if err != nil {
+		return err
+	}
+	return fileManifest.Close()
+}
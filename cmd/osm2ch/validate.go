@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/LdDl/osm2ch"
+)
+
+// runValidate Compares shortest paths found by contraction hierarchies with ones found by plain Dijkstra's algorithm for random pairs
+// of vertices of graph from CSV files
+/*
+	Mismatches are printed with their routes. Process exits with code 1 if there is any mismatch
+*/
+func runValidate(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	in := flags.String("in", "my_graph.csv", "Filename of edges CSV-file written by osm2ch (see 'in' flag of 'query' subcommand). Graph should be contracted")
+	pairs := flags.Int("pairs", 1000, "Number of random pairs of vertices")
+	seed := flags.Int64("seed", 1, "Seed of random generator of pairs")
+	tolerance := flags.Float64("tolerance", 1e-6, "Relative tolerance of costs comparison (weights are rounded when CSV files are written, so costs of shortcuts could slightly differ from sums of costs of edges)")
+	workers := flags.Int("workers", 0, "Number of workers. If it is less or equal to zero then number of logical CPUs is used")
+	reportFname := flags.String("report", "", "Filename of GeoJSON FeatureCollection with routes of mismatched pairs (see 'kind' property). If empty then report is not written")
+	flags.Parse(args)
+
+	if *pairs <= 0 {
+		fmt.Println("Flag 'pairs' should be positive")
+		return
+	}
+
+	fmt.Printf("Loading graph...")
+	st := time.Now()
+	router, err := loadRouter(*in)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Done in %v\n", time.Since(st))
+
+	fmt.Printf("Validating %d pairs...", *pairs)
+	st = time.Now()
+	report, err := router.ValidateContraction(*pairs, *seed, *tolerance, *workers)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Done in %v\n", time.Since(st))
+
+	fmt.Printf("Pairs: %d, reachable: %d, mismatches: %d\n", report.Pairs, report.Reachable, len(report.Mismatches))
+	for _, mismatch := range report.Mismatches {
+		fmt.Printf("\t%d -> %d: %s\n", mismatch.Source, mismatch.Target, mismatch.Reason)
+		fmt.Printf("\t\tch (cost %v): %v\n", mismatch.CHRoute.Cost, mismatch.CHRoute.Vertices)
+		fmt.Printf("\t\tdijkstra (cost %v): %v\n", mismatch.DijkstraRoute.Cost, mismatch.DijkstraRoute.Vertices)
+	}
+
+	if *reportFname != "" {
+		fmt.Printf("Writing report...")
+		st = time.Now()
+		file, err := os.Create(*reportFname)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer file.Close()
+		err = json.NewEncoder(file).Encode(osm2ch.PrepareValidationGeoJSON(report))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		err = file.Close()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Done in %v\n", time.Since(st))
+	}
+
+	if len(report.Mismatches) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,186 @@
+package osm2ch
+
+import (
+	"sort"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// StronglyConnectedComponents Returns strongly connected components of expanded graph (vertices are IDs of edges)
+/*
+	Tarjan's algorithm is used (iterative version, so deep graphs do not overflow stack).
+	Vertices of each component are sorted in ascending order. Components are sorted by size (largest first) and then by the smallest vertex
+*/
+func StronglyConnectedComponents(expandedEdges []ExpandedEdge) [][]EdgeID {
+	// Compact indices of vertices in order of appearance
+	indices := make(map[EdgeID]int)
+	vertices := []EdgeID{}
+	vertexIndex := func(id EdgeID) int {
+		idx, ok := indices[id]
+		if !ok {
+			idx = len(vertices)
+			indices[id] = idx
+			vertices = append(vertices, id)
+		}
+		return idx
+	}
+	for _, edge := range expandedEdges {
+		vertexIndex(edge.Source)
+		vertexIndex(edge.Target)
+	}
+	// Adjacency in compressed form: neighbors of vertex i are adjacency[offsets[i]:offsets[i+1]]
+	offsets := make([]int, len(vertices)+1)
+	for _, edge := range expandedEdges {
+		offsets[indices[edge.Source]+1]++
+	}
+	for i := 1; i < len(offsets); i++ {
+		offsets[i] += offsets[i-1]
+	}
+	adjacency := make([]int, len(expandedEdges))
+	fill := make([]int, len(vertices))
+	copy(fill, offsets[:len(vertices)])
+	for _, edge := range expandedEdges {
+		source := indices[edge.Source]
+		adjacency[fill[source]] = indices[edge.Target]
+		fill[source]++
+	}
+
+	const unvisited = -1
+	order := make([]int, len(vertices))
+	lowLink := make([]int, len(vertices))
+	onStack := make([]bool, len(vertices))
+	for i := range order {
+		order[i] = unvisited
+	}
+	stack := []int{}
+	components := [][]EdgeID{}
+	counter := 0
+	// Call stack of depth-first search: vertex and position of next neighbor to visit
+	type frame struct {
+		vertex   int
+		neighbor int
+	}
+	for root := range vertices {
+		if order[root] != unvisited {
+			continue
+		}
+		callStack := []frame{{vertex: root, neighbor: offsets[root]}}
+		order[root], lowLink[root] = counter, counter
+		counter++
+		stack = append(stack, root)
+		onStack[root] = true
+		for len(callStack) > 0 {
+			top := &callStack[len(callStack)-1]
+			v := top.vertex
+			if top.neighbor < offsets[v+1] {
+				w := adjacency[top.neighbor]
+				top.neighbor++
+				if order[w] == unvisited {
+					order[w], lowLink[w] = counter, counter
+					counter++
+					stack = append(stack, w)
+					onStack[w] = true
+					callStack = append(callStack, frame{vertex: w, neighbor: offsets[w]})
+				} else if onStack[w] && order[w] < lowLink[v] {
+					lowLink[v] = order[w]
+				}
+				continue
+			}
+			// All neighbors have been visited
+			if lowLink[v] == order[v] {
+				component := []EdgeID{}
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w] = false
+					component = append(component, vertices[w])
+					if w == v {
+						break
+					}
+				}
+				sort.Slice(component, func(i, j int) bool {
+					return component[i] < component[j]
+				})
+				components = append(components, component)
+			}
+			callStack = callStack[:len(callStack)-1]
+			if len(callStack) > 0 {
+				parent := callStack[len(callStack)-1].vertex
+				if lowLink[v] < lowLink[parent] {
+					lowLink[parent] = lowLink[v]
+				}
+			}
+		}
+	}
+	sort.Slice(components, func(i, j int) bool {
+		if len(components[i]) == len(components[j]) {
+			return components[i][0] < components[j][0]
+		}
+		return len(components[i]) > len(components[j])
+	})
+	return components
+}
+
+// FilterComponents Removes expanded edges which do not belong to big enough strongly connected components
+/*
+	components - output of StronglyConnectedComponents
+	If minSize <= 0 then only the largest component is kept, otherwise all components having at least minSize vertices are kept.
+	Expanded edge is kept if both of its vertices belong to kept components.
+	Returns kept and removed expanded edges (order is preserved)
+*/
+func FilterComponents(expandedEdges []ExpandedEdge, components [][]EdgeID, minSize int) ([]ExpandedEdge, []ExpandedEdge) {
+	keptVertices := make(map[EdgeID]struct{})
+	for i, component := range components {
+		if (minSize <= 0 && i > 0) || (minSize > 0 && len(component) < minSize) {
+			break
+		}
+		for _, vertex := range component {
+			keptVertices[vertex] = struct{}{}
+		}
+	}
+	kept := make([]ExpandedEdge, 0, len(expandedEdges))
+	removed := []ExpandedEdge{}
+	for _, edge := range expandedEdges {
+		_, sourceKept := keptVertices[edge.Source]
+		_, targetKept := keptVertices[edge.Target]
+		if sourceKept && targetKept {
+			kept = append(kept, edge)
+		} else {
+			removed = append(removed, edge)
+		}
+	}
+	return kept, removed
+}
+
+// PrepareComponentsGeoJSON Returns GeoJSON FeatureCollection of given expanded edges (usually removed ones) with information about components of their vertices
+/*
+	Properties of every feature: edge_id, from_vertex_id, to_vertex_id, from_component, to_component, from_component_size, to_component_size
+	(component is index in given components, so 0 is the largest one)
+*/
+func PrepareComponentsGeoJSON(expandedEdges []ExpandedEdge, components [][]EdgeID) ([]byte, error) {
+	componentOf := make(map[EdgeID]int)
+	for i, component := range components {
+		for _, vertex := range component {
+			componentOf[vertex] = i
+		}
+	}
+	fc := geojson.NewFeatureCollection()
+	for _, edge := range expandedEdges {
+		pts2d := make([][]float64, len(edge.Geom))
+		for i := range edge.Geom {
+			pts2d[i] = []float64{edge.Geom[i].Lon, edge.Geom[i].Lat}
+		}
+		feature := geojson.NewLineStringFeature(pts2d)
+		fromComponent := componentOf[edge.Source]
+		toComponent := componentOf[edge.Target]
+		feature.SetProperty("edge_id", edge.ID)
+		feature.SetProperty("from_vertex_id", edge.Source)
+		feature.SetProperty("to_vertex_id", edge.Target)
+		feature.SetProperty("from_component", fromComponent)
+		feature.SetProperty("to_component", toComponent)
+		feature.SetProperty("from_component_size", len(components[fromComponent]))
+		feature.SetProperty("to_component_size", len(components[toComponent]))
+		fc.AddFeature(feature)
+	}
+	return fc.MarshalJSON()
+}
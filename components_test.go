@@ -0,0 +1,37 @@
+package osm2ch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	arcs := [][2]EdgeID{
+		// Component {1, 2, 3}
+		{1, 2}, {2, 3}, {3, 1},
+		// Dead end: 4 could be reached, but can't be left
+		{3, 4},
+		// Island {10, 11}
+		{10, 11}, {11, 10},
+		// Component {5, 6, 7, 8} connected to the first one in one direction only
+		{5, 6}, {6, 7}, {7, 8}, {8, 5}, {5, 1},
+	}
+	expandedEdges := make([]ExpandedEdge, len(arcs))
+	for i, arc := range arcs {
+		expandedEdges[i] = ExpandedEdge{ID: int64(i + 1), Source: arc[0], Target: arc[1]}
+	}
+	components := StronglyConnectedComponents(expandedEdges)
+	correctComponents := [][]EdgeID{{5, 6, 7, 8}, {1, 2, 3}, {10, 11}, {4}}
+	if !reflect.DeepEqual(components, correctComponents) {
+		t.Errorf("Components should be %v, but got %v", correctComponents, components)
+	}
+
+	kept, removed := FilterComponents(expandedEdges, components, 0)
+	if len(kept) != 4 || len(removed) != len(expandedEdges)-4 {
+		t.Errorf("Only edges of the largest component should be kept, but got %d kept and %d removed", len(kept), len(removed))
+	}
+	kept, removed = FilterComponents(expandedEdges, components, 2)
+	if len(kept) != len(expandedEdges)-1 || len(removed) != 1 || removed[0].Target != 4 {
+		t.Errorf("Only edge to dead end should be removed, but got %d kept and %d removed", len(kept), len(removed))
+	}
+}
@@ -0,0 +1,147 @@
+package osm2ch
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// Compression Compression of file, which is defined by suffix of filename
+type Compression string
+
+const (
+	CompressionNone = Compression("")
+	CompressionGzip = Compression(".gz")
+	CompressionZstd = Compression(".zst")
+)
+
+// DetectCompression Returns compression for given filename: '.gz' for gzip, '.zst' for Zstandard, no compression otherwise (case-insensitive)
+func DetectCompression(fname string) Compression {
+	lower := strings.ToLower(fname)
+	switch {
+	case strings.HasSuffix(lower, string(CompressionGzip)):
+		return CompressionGzip
+	case strings.HasSuffix(lower, string(CompressionZstd)):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// TrimCompression Returns filename without compression suffix (case of the rest of filename is kept)
+func TrimCompression(fname string) string {
+	return fname[:len(fname)-len(DetectCompression(fname))]
+}
+
+// CreateCompressedFile Creates file which is compressed transparently according to suffix of filename (see DetectCompression)
+/*
+	Close should be called to flush compressed stream. Closing of returned writer closes file also
+*/
+func CreateCompressedFile(fname string) (io.WriteCloser, error) {
+	file, err := os.Create(fname)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't create file")
+	}
+	bw := bufio.NewWriterSize(file, 1<<16)
+	cw := &compressedWriter{file: file, buffered: bw}
+	switch DetectCompression(fname) {
+	case CompressionGzip:
+		cw.compressor = gzip.NewWriter(bw)
+	case CompressionZstd:
+		cw.compressor, err = zstd.NewWriter(bw)
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrap(err, "Can't prepare zstd compressor")
+		}
+	}
+	return cw, nil
+}
+
+// OpenCompressedFile Opens file which is decompressed transparently according to suffix of filename (see DetectCompression)
+/*
+	Closing of returned reader closes file also
+*/
+func OpenCompressedFile(fname string) (io.ReadCloser, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't open file")
+	}
+	cr := &compressedReader{file: file, r: bufio.NewReaderSize(file, 1<<16)}
+	switch DetectCompression(fname) {
+	case CompressionGzip:
+		gr, err := gzip.NewReader(cr.r)
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrap(err, "Can't read gzip header")
+		}
+		cr.r, cr.decompressor = gr, gr
+	case CompressionZstd:
+		zr, err := zstd.NewReader(cr.r)
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrap(err, "Can't prepare zstd decompressor")
+		}
+		cr.r, cr.decompressor = zr, zr.IOReadCloser()
+	}
+	return cr, nil
+}
+
+// compressedWriter Writes into file via buffer and optional compressor
+type compressedWriter struct {
+	file       *os.File
+	buffered   *bufio.Writer
+	compressor io.WriteCloser
+	closed     bool
+}
+
+func (cw *compressedWriter) Write(p []byte) (int, error) {
+	if cw.compressor != nil {
+		return cw.compressor.Write(p)
+	}
+	return cw.buffered.Write(p)
+}
+
+// Close Finishes compressed stream, flushes buffer and closes file. Repeated calls do nothing
+func (cw *compressedWriter) Close() error {
+	if cw.closed {
+		return nil
+	}
+	cw.closed = true
+	if cw.compressor != nil {
+		err := cw.compressor.Close()
+		if err != nil {
+			cw.file.Close()
+			return errors.Wrap(err, "Can't finish compressed stream")
+		}
+	}
+	err := cw.buffered.Flush()
+	if err != nil {
+		cw.file.Close()
+		return errors.Wrap(err, "Can't flush file")
+	}
+	return cw.file.Close()
+}
+
+// compressedReader Reads from file via buffer and optional decompressor
+type compressedReader struct {
+	file         *os.File
+	r            io.Reader
+	decompressor io.Closer
+}
+
+func (cr *compressedReader) Read(p []byte) (int, error) {
+	return cr.r.Read(p)
+}
+
+// Close Releases decompressor and closes file
+func (cr *compressedReader) Close() error {
+	if cr.decompressor != nil {
+		cr.decompressor.Close()
+	}
+	return cr.file.Close()
+}
@@ -0,0 +1,28 @@
+package osm2ch
+
+import (
+	"testing"
+)
+
+func TestDetectCompression(t *testing.T) {
+	cases := []struct {
+		fname       string
+		compression Compression
+		trimmed     string
+	}{
+		{"map.csv", CompressionNone, "map.csv"},
+		{"map.csv.gz", CompressionGzip, "map.csv"},
+		{"map.CSV.GZ", CompressionGzip, "map.CSV"},
+		{"map.csv.zst", CompressionZstd, "map.csv"},
+		{"Map.Csv.ZST", CompressionZstd, "Map.Csv"},
+		{"gz", CompressionNone, "gz"},
+	}
+	for _, c := range cases {
+		if compression := DetectCompression(c.fname); compression != c.compression {
+			t.Errorf("Compression of '%s' should be '%s', but got '%s'", c.fname, c.compression, compression)
+		}
+		if trimmed := TrimCompression(c.fname); trimmed != c.trimmed {
+			t.Errorf("Trimmed filename of '%s' should be '%s', but got '%s'", c.fname, c.trimmed, trimmed)
+		}
+	}
+}
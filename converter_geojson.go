@@ -4,13 +4,22 @@ import (
 	"fmt"
 
 	geojson "github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
 )
 
-// PrepareGeoJSONLinestring returns GeoJSON representation of LineString
+// PrepareGeoJSONLinestring returns GeoJSON representation of LineString (with DefaultGeometryPrecision)
 func PrepareGeoJSONLinestring(pts []GeoPoint) string {
+	return PrepareGeoJSONLinestringWithPrecision(pts, DefaultGeometryPrecision)
+}
+
+// PrepareGeoJSONLinestringWithPrecision returns GeoJSON representation of LineString
+/*
+	precision - number of decimal digits of coordinates. Negative precision keeps coordinates as is
+*/
+func PrepareGeoJSONLinestringWithPrecision(pts []GeoPoint, precision int) string {
 	pts2d := make([][]float64, len(pts))
 	for i := range pts {
-		pts2d[i] = []float64{pts[i].Lon, pts[i].Lat}
+		pts2d[i] = []float64{roundCoordinate(pts[i].Lon, precision), roundCoordinate(pts[i].Lat, precision)}
 	}
 	b, err := geojson.NewLineStringGeometry(pts2d).MarshalJSON()
 	if err != nil {
@@ -20,12 +29,40 @@ func PrepareGeoJSONLinestring(pts []GeoPoint) string {
 	return string(b)
 }
 
-// PrepareGeoJSONPoint returns GeoJSON representation of Point
+// PrepareGeoJSONPoint returns GeoJSON representation of Point (with DefaultGeometryPrecision)
 func PrepareGeoJSONPoint(pt GeoPoint) string {
-	b, err := geojson.NewPointGeometry([]float64{pt.Lon, pt.Lat}).MarshalJSON()
+	return PrepareGeoJSONPointWithPrecision(pt, DefaultGeometryPrecision)
+}
+
+// PrepareGeoJSONPointWithPrecision returns GeoJSON representation of Point (see PrepareGeoJSONLinestringWithPrecision)
+func PrepareGeoJSONPointWithPrecision(pt GeoPoint, precision int) string {
+	b, err := geojson.NewPointGeometry([]float64{roundCoordinate(pt.Lon, precision), roundCoordinate(pt.Lat, precision)}).MarshalJSON()
 	if err != nil {
 		fmt.Printf("Warning. Can not convert geometry to geojson format: %s", err.Error())
 		return ""
 	}
 	return string(b)
 }
+
+// parseGeoJSONGeometry Parses Point or LineString in GeoJSON format
+func parseGeoJSONGeometry(str string) ([]GeoPoint, error) {
+	geometry, err := geojson.UnmarshalGeometry([]byte(str))
+	if err != nil {
+		return nil, errors.Wrap(err, "Bad GeoJSON")
+	}
+	switch {
+	case geometry.IsPoint() && len(geometry.Point) >= 2:
+		return []GeoPoint{{Lon: geometry.Point[0], Lat: geometry.Point[1]}}, nil
+	case geometry.IsLineString():
+		pts := make([]GeoPoint, len(geometry.LineString))
+		for i, pt := range geometry.LineString {
+			if len(pt) < 2 {
+				return nil, fmt.Errorf("Bad GeoJSON coordinates")
+			}
+			pts[i] = GeoPoint{Lon: pt[0], Lat: pt[1]}
+		}
+		return pts, nil
+	default:
+		return nil, fmt.Errorf("Unsupported type of GeoJSON geometry: %s", geometry.Type)
+	}
+}
@@ -0,0 +1,43 @@
+package osm2ch
+
+import (
+	"math"
+)
+
+// PreparePolylineLinestring returns Google encoded polyline representation of LineString
+/*
+	precision - number of decimal digits of coordinates (5 for Google Maps, 6 for OSRM / Valhalla with "polyline6").
+	Note: points are encoded in (latitude, longitude) order.
+	See ref. https://developers.google.com/maps/documentation/utilities/polylinealgorithm
+*/
+func PreparePolylineLinestring(pts []GeoPoint, precision int) string {
+	factor := math.Pow(10, float64(precision))
+	buf := make([]byte, 0, 8*len(pts))
+	prevLat, prevLon := int64(0), int64(0)
+	for _, pt := range pts {
+		lat := int64(math.Round(pt.Lat * factor))
+		lon := int64(math.Round(pt.Lon * factor))
+		buf = appendPolylineValue(buf, lat-prevLat)
+		buf = appendPolylineValue(buf, lon-prevLon)
+		prevLat, prevLon = lat, lon
+	}
+	return string(buf)
+}
+
+// PreparePolylinePoint returns Google encoded polyline representation of Point (polyline of single point)
+func PreparePolylinePoint(pt GeoPoint, precision int) string {
+	return PreparePolylineLinestring([]GeoPoint{pt}, precision)
+}
+
+// appendPolylineValue Appends signed value in polyline encoding: zig-zag, then 5-bit chunks offset by 63
+func appendPolylineValue(buf []byte, v int64) []byte {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		buf = append(buf, byte(0x20|(u&0x1f))+63)
+		u >>= 5
+	}
+	return append(buf, byte(u)+63)
+}
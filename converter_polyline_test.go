@@ -0,0 +1,22 @@
+package osm2ch
+
+import (
+	"testing"
+)
+
+func TestPreparePolyline(t *testing.T) {
+	// Example from polyline algorithm description
+	pts := []GeoPoint{{Lat: 38.5, Lon: -120.2}, {Lat: 40.7, Lon: -120.95}, {Lat: 43.252, Lon: -126.453}}
+	if got := PreparePolylineLinestring(pts, 5); got != "_p~iF~ps|U_ulLnnqC_mqNvxq`@" {
+		t.Errorf("Bad polyline with precision 5: %s", got)
+	}
+	if got := PreparePolylineLinestring(pts, 6); got != "_izlhA~rlgdF_{geC~ywl@_kwzCn`{nI" {
+		t.Errorf("Bad polyline with precision 6: %s", got)
+	}
+	if got := PreparePolylinePoint(pts[0], 5); got != "_p~iF~ps|U" {
+		t.Errorf("Bad polyline of point: %s", got)
+	}
+	if got := PrepareHexWKBPoint(GeoPoint{Lon: 1, Lat: 2}); got != "0101000000000000000000F03F0000000000000040" {
+		t.Errorf("Bad hex-encoded WKB of point: %s", got)
+	}
+}
@@ -0,0 +1,55 @@
+package osm2ch
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultGeometryPrecision Default number of decimal digits of coordinates (about 0.1 meter)
+const DefaultGeometryPrecision = 6
+
+// formatCoordinate Returns coordinate with given number of decimal digits without trailing zeros
+/*
+	Negative precision means the shortest representation which could be parsed back to the same float64
+*/
+func formatCoordinate(v float64, precision int) string {
+	if precision < 0 {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	s := strconv.FormatFloat(v, 'f', precision, 64)
+	if strings.IndexByte(s, '.') >= 0 {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// roundCoordinate Returns coordinate rounded to given number of decimal digits (negative precision keeps coordinate as is)
+/*
+	Negative zero (e.g. small negative value rounded to zero) becomes zero, so it is not written as "-0"
+*/
+func roundCoordinate(v float64, precision int) float64 {
+	if precision >= 0 {
+		factor := math.Pow(10, float64(precision))
+		v = math.Round(v*factor) / factor
+	}
+	if v == 0 {
+		return 0
+	}
+	return v
+}
+
+// roundGeoPoints Returns copy of points with coordinates rounded to given number of decimal digits
+func roundGeoPoints(pts []GeoPoint, precision int) []GeoPoint {
+	if precision < 0 {
+		return pts
+	}
+	rounded := make([]GeoPoint, len(pts))
+	for i, pt := range pts {
+		rounded[i] = GeoPoint{Lat: roundCoordinate(pt.Lat, precision), Lon: roundCoordinate(pt.Lon, precision)}
+	}
+	return rounded
+}
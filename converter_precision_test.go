@@ -0,0 +1,93 @@
+package osm2ch
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+func TestGeometryPrecisionRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	pts := []GeoPoint{{Lon: 37.6, Lat: 55.7}, {Lon: -0.0000001, Lat: 0}, {Lon: 180, Lat: -90}}
+	for i := 0; i < 200; i++ {
+		pts = append(pts, GeoPoint{Lon: rnd.Float64()*360 - 180, Lat: rnd.Float64()*180 - 90})
+	}
+	for _, precision := range []int{-1, 0, 1, 3, 5, 6, 7, 9} {
+		// Negative precision should keep coordinates exactly
+		tolerance := 0.0
+		if precision >= 0 {
+			tolerance = 0.5*math.Pow(10, -float64(precision)) + 1e-12
+		}
+		checkText := func(encoder string, s string) {
+			if strings.HasSuffix(s, ".") || (strings.Contains(s, ".") && !strings.ContainsAny(s, "eE") && strings.HasSuffix(s, "0")) || s == "-0" {
+				t.Errorf("%s (precision %d): coordinate %s should not contain trailing zeros or negative zero", encoder, precision, s)
+			}
+		}
+		check := func(encoder string, parsed []float64) {
+			if len(parsed) != 2*len(pts) {
+				t.Fatalf("%s (precision %d) should contain %d coordinates, but got %d", encoder, precision, 2*len(pts), len(parsed))
+			}
+			for i, pt := range pts {
+				if math.Abs(parsed[2*i]-pt.Lon) > tolerance || math.Abs(parsed[2*i+1]-pt.Lat) > tolerance {
+					t.Errorf("%s (precision %d): point %v is parsed back as (%v %v)", encoder, precision, pt, parsed[2*i], parsed[2*i+1])
+				}
+			}
+		}
+
+		wkt := PrepareWKTLinestringWithPrecision(pts, precision)
+		parsedWKT := []float64{}
+		for _, pair := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(wkt, "LINESTRING("), ")"), ",") {
+			for _, s := range strings.Split(pair, " ") {
+				checkText("WKT", s)
+				v, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				parsedWKT = append(parsedWKT, v)
+			}
+		}
+		check("WKT", parsedWKT)
+
+		geojsonText := PrepareGeoJSONLinestringWithPrecision(pts, precision)
+		for _, s := range geojsonNumberRegexp.FindAllString(geojsonText, -1) {
+			checkText("GeoJSON", s)
+		}
+		g, err := geojson.UnmarshalGeometry([]byte(geojsonText))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsedGeoJSON := []float64{}
+		for _, pt := range g.LineString {
+			parsedGeoJSON = append(parsedGeoJSON, pt...)
+		}
+		check("GeoJSON", parsedGeoJSON)
+
+		wkb, err := hex.DecodeString(PrepareHexWKBLinestringWithPrecision(pts, precision))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsedWKB := []float64{}
+		for pos := 9; pos < len(wkb); pos += 8 {
+			parsedWKB = append(parsedWKB, math.Float64frombits(binary.LittleEndian.Uint64(wkb[pos:])))
+		}
+		check("WKB", parsedWKB)
+	}
+	if got := PrepareWKTPoint(GeoPoint{Lon: 37.6, Lat: 55.7}); got != "POINT(37.6 55.7)" {
+		t.Errorf("Default precision should not produce trailing zeros, but got %s", got)
+	}
+	if got := PrepareWKTPointWithPrecision(GeoPoint{Lon: 37.61234567, Lat: -0.0000001}, 6); got != "POINT(37.612346 0)" {
+		t.Errorf("Coordinates should be rounded to 6 digits, but got %s", got)
+	}
+	if got := PrepareGeoJSONPointWithPrecision(GeoPoint{Lon: -0.0000001, Lat: 1}, 6); got != `{"type":"Point","coordinates":[0,1]}` {
+		t.Errorf("Negative zero should be written as zero, but got %s", got)
+	}
+}
+
+var geojsonNumberRegexp = regexp.MustCompile(`-?[0-9][0-9.eE+-]*`)
@@ -0,0 +1,112 @@
+package osm2ch
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	wkbPoint      = 1
+	wkbLineString = 2
+)
+
+// PrepareHexWKBLinestring returns hex-encoded WKB (little-endian) representation of LineString (with DefaultGeometryPrecision)
+func PrepareHexWKBLinestring(pts []GeoPoint) string {
+	return PrepareHexWKBLinestringWithPrecision(pts, DefaultGeometryPrecision)
+}
+
+// PrepareHexWKBLinestringWithPrecision returns hex-encoded WKB (little-endian) representation of LineString
+/*
+	precision - number of decimal digits which coordinates are rounded to. Negative precision keeps coordinates as is
+*/
+func PrepareHexWKBLinestringWithPrecision(pts []GeoPoint, precision int) string {
+	return strings.ToUpper(hex.EncodeToString(prepareWKBLinestring(roundGeoPoints(pts, precision))))
+}
+
+// PrepareHexWKBPoint returns hex-encoded WKB (little-endian) representation of Point (with DefaultGeometryPrecision)
+func PrepareHexWKBPoint(pt GeoPoint) string {
+	return PrepareHexWKBPointWithPrecision(pt, DefaultGeometryPrecision)
+}
+
+// PrepareHexWKBPointWithPrecision returns hex-encoded WKB (little-endian) representation of Point (see PrepareHexWKBLinestringWithPrecision)
+func PrepareHexWKBPointWithPrecision(pt GeoPoint, precision int) string {
+	return strings.ToUpper(hex.EncodeToString(prepareWKBPoint(roundGeoPoints([]GeoPoint{pt}, precision)[0])))
+}
+
+// prepareWKBLinestring returns WKB (little-endian) representation of LineString
+func prepareWKBLinestring(pts []GeoPoint) []byte {
+	wkb := make([]byte, 9+16*len(pts))
+	wkb[0] = 1
+	binary.LittleEndian.PutUint32(wkb[1:], wkbLineString)
+	binary.LittleEndian.PutUint32(wkb[5:], uint32(len(pts)))
+	for i, pt := range pts {
+		binary.LittleEndian.PutUint64(wkb[9+16*i:], math.Float64bits(pt.Lon))
+		binary.LittleEndian.PutUint64(wkb[17+16*i:], math.Float64bits(pt.Lat))
+	}
+	return wkb
+}
+
+// prepareWKBPoint returns WKB (little-endian) representation of Point
+func prepareWKBPoint(pt GeoPoint) []byte {
+	wkb := make([]byte, 21)
+	wkb[0] = 1
+	binary.LittleEndian.PutUint32(wkb[1:], wkbPoint)
+	binary.LittleEndian.PutUint64(wkb[5:], math.Float64bits(pt.Lon))
+	binary.LittleEndian.PutUint64(wkb[13:], math.Float64bits(pt.Lat))
+	return wkb
+}
+
+// prepareEWKB returns EWKB (PostGIS extended WKB) for given little-endian WKB and SRID
+func prepareEWKB(wkb []byte, srid uint32) []byte {
+	const ewkbSRIDFlag = 0x20000000
+	ewkb := make([]byte, len(wkb)+4)
+	ewkb[0] = wkb[0]
+	binary.LittleEndian.PutUint32(ewkb[1:], binary.LittleEndian.Uint32(wkb[1:])|ewkbSRIDFlag)
+	binary.LittleEndian.PutUint32(ewkb[5:], srid)
+	copy(ewkb[9:], wkb[5:])
+	return ewkb
+}
+
+// parseHexWKB Parses Point or LineString in hex-encoded WKB format (both byte orders)
+func parseHexWKB(str string) ([]GeoPoint, error) {
+	wkb, err := hex.DecodeString(str)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unknown format of geometry")
+	}
+	if len(wkb) < 5 {
+		return nil, fmt.Errorf("WKB is too short")
+	}
+	var byteOrder binary.ByteOrder = binary.LittleEndian
+	if wkb[0] == 0 {
+		byteOrder = binary.BigEndian
+	}
+	var count int
+	var coords []byte
+	switch byteOrder.Uint32(wkb[1:]) {
+	case wkbPoint:
+		count, coords = 1, wkb[5:]
+	case wkbLineString:
+		if len(wkb) < 9 {
+			return nil, fmt.Errorf("WKB is too short")
+		}
+		count, coords = int(byteOrder.Uint32(wkb[5:])), wkb[9:]
+	default:
+		return nil, fmt.Errorf("Unsupported type of WKB geometry: %d", byteOrder.Uint32(wkb[1:]))
+	}
+	if len(coords) != 16*count {
+		return nil, fmt.Errorf("Bad size of WKB")
+	}
+	pts := make([]GeoPoint, count)
+	for i := range pts {
+		pts[i] = GeoPoint{
+			Lon: math.Float64frombits(byteOrder.Uint64(coords[16*i:])),
+			Lat: math.Float64frombits(byteOrder.Uint64(coords[16*i+8:])),
+		}
+	}
+	return pts, nil
+}
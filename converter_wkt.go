@@ -2,19 +2,74 @@ package osm2ch
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
-// PrepareWKTLinestring returns WKT representation of LineString
+// PrepareWKTLinestring returns WKT representation of LineString (with DefaultGeometryPrecision)
 func PrepareWKTLinestring(pts []GeoPoint) string {
-	ptsStr := make([]string, len(pts))
+	return PrepareWKTLinestringWithPrecision(pts, DefaultGeometryPrecision)
+}
+
+// PrepareWKTLinestringWithPrecision returns WKT representation of LineString
+/*
+	precision - number of decimal digits of coordinates, trailing zeros are omitted.
+	Negative precision means the shortest representation which is parsed back to exactly the same coordinates
+*/
+func PrepareWKTLinestringWithPrecision(pts []GeoPoint, precision int) string {
+	var sb strings.Builder
+	sb.Grow(12 + 24*len(pts))
+	sb.WriteString("LINESTRING(")
 	for i := range pts {
-		ptsStr[i] = fmt.Sprintf("%f %f", pts[i].Lon, pts[i].Lat)
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(formatCoordinate(pts[i].Lon, precision))
+		sb.WriteByte(' ')
+		sb.WriteString(formatCoordinate(pts[i].Lat, precision))
 	}
-	return fmt.Sprintf("LINESTRING(%s)", strings.Join(ptsStr, ","))
+	sb.WriteByte(')')
+	return sb.String()
 }
 
-// PrepareWKTPoint returns WKT representation of Point
+// PrepareWKTPoint returns WKT representation of Point (with DefaultGeometryPrecision)
 func PrepareWKTPoint(pt GeoPoint) string {
-	return fmt.Sprintf("POINT(%f %f)", pt.Lon, pt.Lat)
+	return PrepareWKTPointWithPrecision(pt, DefaultGeometryPrecision)
+}
+
+// PrepareWKTPointWithPrecision returns WKT representation of Point (see PrepareWKTLinestringWithPrecision)
+func PrepareWKTPointWithPrecision(pt GeoPoint, precision int) string {
+	return "POINT(" + formatCoordinate(pt.Lon, precision) + " " + formatCoordinate(pt.Lat, precision) + ")"
+}
+
+// parseWKT Parses Point or LineString in WKT format
+func parseWKT(str string) ([]GeoPoint, error) {
+	start, end := strings.IndexByte(str, '('), strings.LastIndexByte(str, ')')
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("Bad WKT: '%s'", str)
+	}
+	body := strings.TrimSpace(str[start+1 : end])
+	if body == "" {
+		return []GeoPoint{}, nil
+	}
+	pairs := strings.Split(body, ",")
+	pts := make([]GeoPoint, len(pairs))
+	for i, pair := range pairs {
+		coords := strings.Fields(pair)
+		if len(coords) < 2 {
+			return nil, fmt.Errorf("Bad WKT coordinates: '%s'", pair)
+		}
+		lon, err := strconv.ParseFloat(coords[0], 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "Bad WKT longitude")
+		}
+		lat, err := strconv.ParseFloat(coords[1], 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "Bad WKT latitude")
+		}
+		pts[i] = GeoPoint{Lon: lon, Lat: lat}
+	}
+	return pts, nil
 }
@@ -0,0 +1,210 @@
+package osm2ch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/LdDl/ch"
+	"github.com/paulmach/osm"
+	"github.com/pkg/errors"
+)
+
+// ImportFromCSVFiles Loads contracted graph from CSV files written by osm2ch (the same as ch.ImportFromFile does)
+/*
+	Files could be compressed: see ReadCSVFiles
+*/
+func ImportFromCSVFiles(edgesFname, verticesFname, shortcutsFname string) (*ch.Graph, error) {
+	expandedEdges, vertices, shortcuts, err := ReadCSVFiles(edgesFname, verticesFname, shortcutsFname)
+	if err != nil {
+		return nil, err
+	}
+	return prepareContractedGraph(expandedEdges, vertices, shortcuts)
+}
+
+// ReadCSVFiles Reads expanded edges, vertices and shortcuts from CSV files written by osm2ch
+/*
+	Files are decompressed transparently if their names end with '.gz' or '.zst' (see OpenCompressedFile).
+	shortcutsFname could be empty (if graph has not been contracted).
+	Columns are found by names from header, so extra columns are ignored. Geometry could be in WKT, GeoJSON or
+	hex-encoded WKB format (encoded polylines are not supported since precision of polyline is unknown)
+*/
+func ReadCSVFiles(edgesFname, verticesFname, shortcutsFname string) ([]ExpandedEdge, []Vertex, []Shortcut, error) {
+	expandedEdges := []ExpandedEdge{}
+	err := readCSVFile(edgesFname, []string{"from_vertex_id", "to_vertex_id", "weight"}, func(row csvRow) error {
+		edge := ExpandedEdge{
+			Source:         EdgeID(row.int64("from_vertex_id")),
+			Target:         EdgeID(row.int64("to_vertex_id")),
+			CostMeters:     row.float64("weight"),
+			WasOneway:      row.bool("was_one_way"),
+			ID:             row.int64("edge_id"),
+			SourceOSMWayID: osm.WayID(row.int64("osm_way_from")),
+			TargetOSMWayID: osm.WayID(row.int64("osm_way_to")),
+			SourceComponent: expandedEdgeComponent{
+				SourceNodeID: osm.NodeID(row.int64("osm_way_from_source_node")),
+				TargetNodeID: osm.NodeID(row.int64("osm_way_from_target_node")),
+			},
+			TargeComponent: expandedEdgeComponent{
+				SourceNodeID: osm.NodeID(row.int64("osm_way_to_source_node")),
+				TargetNodeID: osm.NodeID(row.int64("osm_way_to_target_node")),
+			},
+			Geom: row.geometry("geom"),
+		}
+		expandedEdges = append(expandedEdges, edge)
+		return row.err
+	})
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "Can't read edges")
+	}
+
+	vertices := []Vertex{}
+	err = readCSVFile(verticesFname, []string{"vertex_id", "order_pos", "importance"}, func(row csvRow) error {
+		vertex := Vertex{
+			ID:         row.int64("vertex_id"),
+			OrderPos:   row.int64("order_pos"),
+			Importance: int(row.int64("importance")),
+		}
+		if geom := row.geometry("geom"); len(geom) > 0 {
+			vertex.Geom = geom[0]
+		}
+		vertices = append(vertices, vertex)
+		return row.err
+	})
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "Can't read vertices")
+	}
+
+	shortcuts := []Shortcut{}
+	if shortcutsFname != "" {
+		file, err := OpenCompressedFile(shortcutsFname)
+		if err != nil {
+			return nil, nil, nil, errors.Wrap(err, "Can't read shortcuts")
+		}
+		defer file.Close()
+		shortcuts, err = readShortcuts(file)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return expandedEdges, vertices, shortcuts, nil
+}
+
+// readCSVFile Calls handler for every row of CSV file (separated by ';') with header
+/*
+	required - columns which should be present in header
+*/
+func readCSVFile(fname string, required []string, handle func(row csvRow) error) error {
+	file, err := OpenCompressedFile(fname)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.Comma = ';'
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return errors.Wrap(err, "Can't read header")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("Column '%s' is not found", name)
+		}
+	}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = handle(csvRow{columns: columns, record: record})
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("Line %d", line))
+		}
+	}
+}
+
+// csvRow Row of CSV file with access to values by names of columns. Missing columns give zero values, first parsing error is kept
+type csvRow struct {
+	columns map[string]int
+	record  []string
+	err     error
+}
+
+func (row *csvRow) value(name string) string {
+	idx, ok := row.columns[name]
+	if !ok || idx >= len(row.record) {
+		return ""
+	}
+	return row.record[idx]
+}
+
+func (row *csvRow) int64(name string) int64 {
+	str := row.value(name)
+	if str == "" || row.err != nil {
+		return 0
+	}
+	v, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		row.err = errors.Wrap(err, fmt.Sprintf("Can't parse column '%s'", name))
+	}
+	return v
+}
+
+func (row *csvRow) float64(name string) float64 {
+	str := row.value(name)
+	if str == "" || row.err != nil {
+		return 0
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		row.err = errors.Wrap(err, fmt.Sprintf("Can't parse column '%s'", name))
+	}
+	return v
+}
+
+func (row *csvRow) bool(name string) bool {
+	str := row.value(name)
+	if str == "" || row.err != nil {
+		return false
+	}
+	v, err := strconv.ParseBool(str)
+	if err != nil {
+		row.err = errors.Wrap(err, fmt.Sprintf("Can't parse column '%s'", name))
+	}
+	return v
+}
+
+func (row *csvRow) geometry(name string) []GeoPoint {
+	str := row.value(name)
+	if str == "" || row.err != nil {
+		return nil
+	}
+	pts, err := parseGeometry(str)
+	if err != nil {
+		row.err = errors.Wrap(err, fmt.Sprintf("Can't parse column '%s'", name))
+	}
+	return pts
+}
+
+// parseGeometry Parses Point or LineString in WKT, GeoJSON or hex-encoded WKB format (as written by osm2ch)
+func parseGeometry(str string) ([]GeoPoint, error) {
+	str = strings.TrimSpace(str)
+	upper := strings.ToUpper(str)
+	switch {
+	case strings.HasPrefix(upper, "LINESTRING") || strings.HasPrefix(upper, "POINT"):
+		return parseWKT(str)
+	case strings.HasPrefix(str, "{"):
+		return parseGeoJSONGeometry(str)
+	default:
+		return parseHexWKB(str)
+	}
+}
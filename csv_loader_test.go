@@ -0,0 +1,63 @@
+package osm2ch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadCompressedCSVFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "osm2ch_csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	geom := []GeoPoint{{Lon: 37.6, Lat: 55.7}, {Lon: 37.61, Lat: 55.71}}
+	edges := "from_vertex_id;to_vertex_id;weight;geom;was_one_way;edge_id;osm_way_from;osm_way_to;osm_way_from_source_node;osm_way_from_target_node;osm_way_to_source_node;osm_way_to_target_node;osm_way_from_name;osm_way_to_name\n" +
+		fmt.Sprintf("1;2;0.5;%s;true;1;10;11;100;101;101;102;A;B\n", PrepareWKTLinestring(geom)) +
+		fmt.Sprintf("2;1;0.25;%s;false;2;11;10;102;101;101;100;B;A\n", PrepareHexWKBLinestring(geom))
+	vertices := "vertex_id;order_pos;importance;geom\n" +
+		fmt.Sprintf("1;1;3;\"%s\"\n", "{\"\"type\"\":\"\"Point\"\",\"\"coordinates\"\":[37.6,55.7]}") +
+		fmt.Sprintf("2;0;2;%s\n", PrepareWKTPoint(geom[1]))
+	shortcuts := "from_vertex_id;to_vertex_id;weight;via_vertex_id\n1;1;0.75;2\n"
+
+	for _, compression := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		fnames := []string{}
+		for i, content := range []string{edges, vertices, shortcuts} {
+			fname := filepath.Join(dir, fmt.Sprintf("graph_%d.csv%s", i, compression))
+			file, err := CreateCompressedFile(fname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = file.Write([]byte(content)); err != nil {
+				t.Fatal(err)
+			}
+			if err = file.Close(); err != nil {
+				t.Fatal(err)
+			}
+			fnames = append(fnames, fname)
+		}
+		expandedEdges, readVertices, readShortcuts, err := ReadCSVFiles(fnames[0], fnames[1], fnames[2])
+		if err != nil {
+			t.Fatalf("Compression '%s': %s", compression, err)
+		}
+		expectedEdge := ExpandedEdge{
+			ID: 1, Source: 1, Target: 2, CostMeters: 0.5, WasOneway: true, SourceOSMWayID: 10, TargetOSMWayID: 11,
+			SourceComponent: expandedEdgeComponent{100, 101}, TargeComponent: expandedEdgeComponent{101, 102}, Geom: geom,
+		}
+		if len(expandedEdges) != 2 || !reflect.DeepEqual(expandedEdges[0], expectedEdge) || !reflect.DeepEqual(expandedEdges[1].Geom, geom) {
+			t.Errorf("Compression '%s': bad expanded edges %+v", compression, expandedEdges)
+		}
+		expectedVertices := []Vertex{{ID: 1, OrderPos: 1, Importance: 3, Geom: geom[0]}, {ID: 2, OrderPos: 0, Importance: 2, Geom: geom[1]}}
+		if !reflect.DeepEqual(readVertices, expectedVertices) {
+			t.Errorf("Compression '%s': bad vertices %+v", compression, readVertices)
+		}
+		if !reflect.DeepEqual(readShortcuts, []Shortcut{{From: 1, To: 1, Via: 2, Weight: 0.75}}) {
+			t.Errorf("Compression '%s': bad shortcuts %+v", compression, readShortcuts)
+		}
+	}
+}
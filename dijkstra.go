@@ -0,0 +1,105 @@
+package osm2ch
+
+import (
+	"container/heap"
+	"math"
+)
+
+// ShortestPathTree Returns costs of shortest paths from source vertex to every vertex reachable within maxCost (inclusive)
+/*
+	Plain Dijkstra's algorithm over expanded edges (shortcuts are not used), so it is independent from contraction hierarchies.
+	Use math.Inf(1) as maxCost for the whole graph. Nil is returned if there is no such source vertex
+*/
+func (router *Router) ShortestPathTree(source int64, maxCost float64) map[int64]float64 {
+	sourceIdx, ok := router.verticesIdx[source]
+	if !ok {
+		return nil
+	}
+	dist, _ := router.dijkstra(sourceIdx, -1, maxCost)
+	costs := make(map[int64]float64)
+	for i, cost := range dist {
+		if !math.IsInf(cost, 1) {
+			costs[router.vertices[i].ID] = cost
+		}
+	}
+	return costs
+}
+
+// DijkstraShortestPath Returns shortest path between two vertices found by plain Dijkstra's algorithm over expanded edges
+/*
+	It is independent from contraction hierarchies (see ShortestPath). False is returned if there is no path
+*/
+func (router *Router) DijkstraShortestPath(source, target int64) (Route, bool) {
+	sourceIdx, okSource := router.verticesIdx[source]
+	targetIdx, okTarget := router.verticesIdx[target]
+	if !okSource || !okTarget {
+		return Route{}, false
+	}
+	dist, prev := router.dijkstra(sourceIdx, targetIdx, math.Inf(1))
+	if math.IsInf(dist[targetIdx], 1) {
+		return Route{}, false
+	}
+	path := []int64{}
+	for v := targetIdx; v >= 0; v = prev[v] {
+		path = append(path, router.vertices[v].ID)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return router.prepareRoute(dist[targetIdx], path), true
+}
+
+// dijkstra Returns costs of shortest paths from source (index of vertex) and previous vertices of paths (-1 for source and
+// unreachable vertices). Search stops when target (if it is not negative) is reached or costs exceed maxCost
+func (router *Router) dijkstra(sourceIdx, targetIdx int, maxCost float64) ([]float64, []int) {
+	dist := make([]float64, len(router.vertices))
+	prev := make([]int, len(router.vertices))
+	for i := range dist {
+		dist[i] = math.Inf(1)
+		prev[i] = -1
+	}
+	dist[sourceIdx] = 0
+	queue := &dijkstraQueue{{vertex: sourceIdx, cost: 0}}
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(dijkstraQueueItem)
+		if item.cost > dist[item.vertex] {
+			// Outdated item
+			continue
+		}
+		if item.vertex == targetIdx {
+			break
+		}
+		for _, edgeIdx := range router.outEdges[item.vertex] {
+			edge := router.edges[edgeIdx]
+			target := router.verticesIdx[int64(edge.Target)]
+			cost := item.cost + edge.CostMeters
+			if cost <= maxCost && cost < dist[target] {
+				dist[target] = cost
+				prev[target] = item.vertex
+				heap.Push(queue, dijkstraQueueItem{vertex: target, cost: cost})
+			}
+		}
+	}
+	return dist, prev
+}
+
+// dijkstraQueueItem Vertex (index in slice of vertices) with cost of path to it
+type dijkstraQueueItem struct {
+	vertex int
+	cost   float64
+}
+
+// dijkstraQueue Min-heap of vertices by cost
+type dijkstraQueue []dijkstraQueueItem
+
+func (h dijkstraQueue) Len() int            { return len(h) }
+func (h dijkstraQueue) Less(i, j int) bool  { return h[i].cost < h[j].cost }
+func (h dijkstraQueue) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *dijkstraQueue) Push(x interface{}) { *h = append(*h, x.(dijkstraQueueItem)) }
+func (h *dijkstraQueue) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,117 @@
+package osm2ch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DIMACS coordinates are integers: degrees multiplied by 10^6 (the same as in graphs of 9th DIMACS challenge)
+	dimacsCoordinatesScale = 1e6
+)
+
+// WriteDIMACSFiles Writes expanded graph into files of 9th DIMACS challenge format (see WriteDIMACS)
+/*
+	grFname - graph (arcs with weights), coFname - coordinates of vertices, mappingFname - mapping between DIMACS IDs and IDs of vertices (CSV)
+*/
+func WriteDIMACSFiles(grFname, coFname, mappingFname string, expandedEdges []ExpandedEdge, vertices []Vertex, weightFactor float64) error {
+	fnames := []string{grFname, coFname, mappingFname}
+	files := make([]*os.File, 0, len(fnames))
+	writers := make([]io.Writer, 0, len(fnames))
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for _, fname := range fnames {
+		file, err := os.Create(fname)
+		if err != nil {
+			return errors.Wrap(err, "Can't create DIMACS file")
+		}
+		files = append(files, file)
+		writers = append(writers, file)
+	}
+	err := WriteDIMACS(writers[0], writers[1], writers[2], expandedEdges, vertices, weightFactor)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		err = file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDIMACS Writes expanded graph in format of 9th DIMACS Implementation Challenge (shortest paths)
+/*
+	gr - graph: "p sp <n> <m>" and arcs "a <u> <v> <w>"
+	co - coordinates: "p aux sp co <n>" and vertices "v <id> <lon * 10^6> <lat * 10^6>"
+	mapping - CSV with header "dimacs_id;vertex_id"
+	DIMACS IDs are numbers from 1 in order of vertices. Weights should be integers, so weight of arc is weight of expanded edge multiplied
+	by weightFactor and rounded (but at least 1, since zero weights are not accepted by some solvers).
+	Expanded edges which vertices are not provided are skipped. See ref. http://www.diag.uniroma1.it/challenge9/format.shtml
+*/
+func WriteDIMACS(gr, co, mapping io.Writer, expandedEdges []ExpandedEdge, vertices []Vertex, weightFactor float64) error {
+	dimacsIDs := make(map[int64]int, len(vertices))
+	for i, vertex := range vertices {
+		dimacsIDs[vertex.ID] = i + 1
+	}
+	arcsNum := 0
+	for _, edge := range expandedEdges {
+		_, okSource := dimacsIDs[int64(edge.Source)]
+		_, okTarget := dimacsIDs[int64(edge.Target)]
+		if okSource && okTarget {
+			arcsNum++
+		}
+	}
+
+	bw := bufio.NewWriter(gr)
+	fmt.Fprintf(bw, "c Expanded graph prepared by osm2ch\n")
+	fmt.Fprintf(bw, "c Weights are multiplied by %g and rounded\n", weightFactor)
+	fmt.Fprintf(bw, "p sp %d %d\n", len(vertices), arcsNum)
+	for _, edge := range expandedEdges {
+		source, okSource := dimacsIDs[int64(edge.Source)]
+		target, okTarget := dimacsIDs[int64(edge.Target)]
+		if !okSource || !okTarget {
+			continue
+		}
+		weight := int64(math.Round(edge.CostMeters * weightFactor))
+		if weight < 1 {
+			weight = 1
+		}
+		fmt.Fprintf(bw, "a %d %d %d\n", source, target, weight)
+	}
+	err := bw.Flush()
+	if err != nil {
+		return errors.Wrap(err, "Can't write DIMACS graph")
+	}
+
+	bw = bufio.NewWriter(co)
+	fmt.Fprintf(bw, "c Coordinates of vertices (longitude and latitude multiplied by 10^6)\n")
+	fmt.Fprintf(bw, "p aux sp co %d\n", len(vertices))
+	for i, vertex := range vertices {
+		fmt.Fprintf(bw, "v %d %d %d\n", i+1, int64(math.Round(vertex.Geom.Lon*dimacsCoordinatesScale)), int64(math.Round(vertex.Geom.Lat*dimacsCoordinatesScale)))
+	}
+	err = bw.Flush()
+	if err != nil {
+		return errors.Wrap(err, "Can't write DIMACS coordinates")
+	}
+
+	bw = bufio.NewWriter(mapping)
+	fmt.Fprintf(bw, "dimacs_id;vertex_id\n")
+	for i, vertex := range vertices {
+		fmt.Fprintf(bw, "%d;%d\n", i+1, vertex.ID)
+	}
+	err = bw.Flush()
+	if err != nil {
+		return errors.Wrap(err, "Can't write mapping of DIMACS IDs")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package osm2ch
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteDIMACS(t *testing.T) {
+	expandedEdges := []ExpandedEdge{
+		{ID: 1, Source: 10, Target: 20, CostMeters: 0.25},
+		{ID: 2, Source: 20, Target: 10, CostMeters: 0.0000001},
+		{ID: 3, Source: 20, Target: 30, CostMeters: 1},
+	}
+	vertices := []Vertex{{ID: 20, Geom: GeoPoint{Lon: 37.6, Lat: 55.75}}, {ID: 10, Geom: GeoPoint{Lon: -1.5, Lat: 0.0000004}}}
+	gr, co, mapping := bytes.Buffer{}, bytes.Buffer{}, bytes.Buffer{}
+	err := WriteDIMACS(&gr, &co, &mapping, expandedEdges, vertices, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Third edge is skipped since its target vertex is not provided. Second one gets minimal weight
+	expectedGr := "c Expanded graph prepared by osm2ch\nc Weights are multiplied by 100000 and rounded\np sp 2 2\na 2 1 25000\na 1 2 1\n"
+	if gr.String() != expectedGr {
+		t.Errorf("Bad graph file:\n%s\nExpected:\n%s", gr.String(), expectedGr)
+	}
+	expectedCo := "c Coordinates of vertices (longitude and latitude multiplied by 10^6)\np aux sp co 2\nv 1 37600000 55750000\nv 2 -1500000 0\n"
+	if co.String() != expectedCo {
+		t.Errorf("Bad coordinates file:\n%s\nExpected:\n%s", co.String(), expectedCo)
+	}
+	expectedMapping := "dimacs_id;vertex_id\n1;20\n2;10\n"
+	if mapping.String() != expectedMapping {
+		t.Errorf("Bad mapping file:\n%s\nExpected:\n%s", mapping.String(), expectedMapping)
+	}
+}
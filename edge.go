@@ -16,3 +16,46 @@ type Edge struct {
 	/* CostSeconds  float64 */ //@todo: consider cost customization
 	Geom                       []GeoPoint
 }
+
+// prepareWayEdges Splits way into edges by nodes which are used more than once (intersections). IDs of edges are not set
+/*
+	For two-way roads reverse edge goes right after forward one
+*/
+func prepareWayEdges(way Way, nodes map[osm.NodeID]Node) []Edge {
+	edges := []Edge{}
+	var source osm.NodeID
+	geometry := []GeoPoint{}
+	for i, wayNode := range way.Nodes {
+		node := nodes[wayNode.ID]
+		if i == 0 {
+			source = wayNode.ID
+			geometry = append(geometry, GeoPoint{Lon: node.node.Lon, Lat: node.node.Lat})
+		} else {
+			geometry = append(geometry, GeoPoint{Lon: node.node.Lon, Lat: node.node.Lat})
+			if node.useCount > 1 {
+				cost := getSphericalLength(geometry)
+				edges = append(edges, Edge{
+					WayID:        way.ID,
+					SourceNodeID: source,
+					TargetNodeID: wayNode.ID,
+					CostMeters:   cost,
+					Geom:         copyLine(geometry),
+					WasOneway:    way.Oneway,
+				})
+				if !way.Oneway {
+					edges = append(edges, Edge{
+						WayID:        way.ID,
+						SourceNodeID: wayNode.ID,
+						TargetNodeID: source,
+						CostMeters:   cost,
+						Geom:         reverseLine(geometry),
+						WasOneway:    false,
+					})
+				}
+				source = wayNode.ID
+				geometry = []GeoPoint{GeoPoint{Lon: node.node.Lon, Lat: node.node.Lat}}
+			}
+		}
+	}
+	return edges
+}
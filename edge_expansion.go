@@ -0,0 +1,171 @@
+package osm2ch
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/paulmach/osm"
+)
+
+// expansionOptions Options of edge expanding technique
+type expansionOptions struct {
+	workers       int     // If workers <= 0 then number of logical CPUs is used
+	deadEndUTurns bool    // Allow u-turns at dead-end (or boundary) nodes
+	uTurnPenalty  float64 // Penalty added to cost of u-turn (same units as CostMeters)
+}
+
+// expansionOptions Returns options of edge expanding technique
+func (cfg *OsmConfiguration) expansionOptions() expansionOptions {
+	return expansionOptions{
+		workers:       cfg.Workers,
+		deadEndUTurns: cfg.DeadEndUTurns,
+		uTurnPenalty:  cfg.UTurnPenalty,
+	}
+}
+
+// expandEdges Applies edge expanding technique: every edge becomes vertex and every possible transition between two adjacent edges becomes edge
+/*
+	Returns expanded edges (numbered from 1 in order of source edges) and number of ignored cycles (u-turns)
+*/
+func expandEdges(edges []Edge, options expansionOptions) ([]ExpandedEdge, int) {
+	sources := make([]int, len(edges))
+	for i := range edges {
+		sources[i] = i
+	}
+	groups, cycles := expandEdgesGroups(edges, sources, options)
+	total := 0
+	for i := range groups {
+		total += len(groups[i])
+	}
+	expandedEdges := make([]ExpandedEdge, 0, total)
+	for i := range groups {
+		expandedEdges = append(expandedEdges, groups[i]...)
+	}
+	// Assign IDs in deterministic order
+	for i := range expandedEdges {
+		expandedEdges[i].ID = int64(i + 1)
+	}
+	return expandedEdges, cycles
+}
+
+// expandEdgesGroups Applies edge expanding technique for given source edges (indices in slice of edges)
+/*
+	Work is partitioned into contiguous chunks of sources. Each chunk is processed by its own worker and results are
+	placed in order of given sources, so output is the very same as in sequential run.
+	Returns expanded edges (IDs are not set) for each given source and number of ignored cycles (u-turns)
+*/
+func expandEdgesGroups(edges []Edge, sources []int, options expansionOptions) ([][]ExpandedEdge, int) {
+	// create edge index by SourceNodeID (values are indices in slice of edges, since IDs could be taken from persistent mapping)
+	edgesBySourceNodeID := make(map[osm.NodeID][]int)
+	for i, edge := range edges {
+		edgesBySourceNodeID[edge.SourceNodeID] = append(edgesBySourceNodeID[edge.SourceNodeID], i)
+	}
+
+	workers := options.workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(sources) {
+		workers = len(sources)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	groups := make([][]ExpandedEdge, len(sources))
+	chunkSize := (len(sources) + workers - 1) / workers
+	chunksCycles := make([]int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		from := w * chunkSize
+		to := from + chunkSize
+		if to > len(sources) {
+			to = len(sources)
+		}
+		if from >= to {
+			continue
+		}
+		wg.Add(1)
+		go func(w, from, to int) {
+			defer wg.Done()
+			for i := from; i < to; i++ {
+				expanded, cycles := expandEdge(edges[sources[i]], edges, edgesBySourceNodeID, options)
+				groups[i] = expanded
+				chunksCycles[w] += cycles
+			}
+		}(w, from, to)
+	}
+	wg.Wait()
+
+	cycles := 0
+	for w := range chunksCycles {
+		cycles += chunksCycles[w]
+	}
+	return groups, cycles
+}
+
+// expandEdge Returns expanded edges for every possible transition from given edge and number of ignored cycles (u-turns)
+/*
+	U-turns are forbidden at regular intersections. If options.deadEndUTurns is set then u-turns are allowed at nodes where
+	u-turn is the only possible transition (dead ends and boundaries of extract): penalty is added to cost of such transition.
+	IDs of returned expanded edges are not set
+*/
+func expandEdge(edgeAsFromVertex Edge, edges []Edge, edgesBySourceNodeID map[osm.NodeID][]int, options expansionOptions) ([]ExpandedEdge, int) {
+	cycles := 0
+	expandedEdges := []ExpandedEdge{}
+	uTurns := []Edge{}
+	outcomingEdges := edgesBySourceNodeID[edgeAsFromVertex.TargetNodeID]
+	for _, outcomingEdgeIdx := range outcomingEdges {
+		edgeAsToVertex := edges[outcomingEdgeIdx]
+		if edgeAsToVertex.ID == edgeAsFromVertex.ID {
+			continue
+		}
+		// cycles, u-turn?
+		if edgeAsFromVertex.Geom[0] == edgeAsToVertex.Geom[len(edgeAsToVertex.Geom)-1] && edgeAsFromVertex.Geom[len(edgeAsFromVertex.Geom)-1] == edgeAsToVertex.Geom[0] {
+			uTurns = append(uTurns, edgeAsToVertex)
+			continue
+		}
+		expandedEdges = append(expandedEdges, prepareExpandedEdge(edgeAsFromVertex, edgeAsToVertex, 0))
+	}
+	if len(expandedEdges) == 0 && options.deadEndUTurns {
+		// Dead end (or boundary): u-turn is the only way to leave it
+		for _, edgeAsToVertex := range uTurns {
+			expandedEdges = append(expandedEdges, prepareExpandedEdge(edgeAsFromVertex, edgeAsToVertex, options.uTurnPenalty))
+		}
+		return expandedEdges, 0
+	}
+	cycles += len(uTurns)
+	return expandedEdges, cycles
+}
+
+// prepareExpandedEdge Returns expanded edge for transition between two edges. Geometry goes from the middle of source edge to the middle of target one
+/*
+	penalty - additional cost of transition (same units as CostMeters)
+*/
+func prepareExpandedEdge(edgeAsFromVertex, edgeAsToVertex Edge, penalty float64) ExpandedEdge {
+	costMetersFromVertex := edgeAsFromVertex.CostMeters
+	costMetersToVertex := edgeAsToVertex.CostMeters
+	beforeFromIdx, fromMiddlePoint := findMiddlePoint(edgeAsFromVertex.Geom)
+	fromGeomHalf := append([]GeoPoint{fromMiddlePoint}, edgeAsFromVertex.Geom[beforeFromIdx+1:len(edgeAsFromVertex.Geom)]...)
+	beforeToIdx, toMiddlePoint := findMiddlePoint(edgeAsToVertex.Geom)
+	toGeomHalf := append(make([]GeoPoint, 0, len(edgeAsToVertex.Geom[:beforeToIdx+1])+1), edgeAsToVertex.Geom[:beforeToIdx+1]...)
+	toGeomHalf = append(toGeomHalf, toMiddlePoint)
+	completedNewGeom := append(fromGeomHalf, toGeomHalf...)
+	return ExpandedEdge{
+		Source:         edgeAsFromVertex.ID,
+		Target:         edgeAsToVertex.ID,
+		SourceOSMWayID: edgeAsFromVertex.WayID,
+		TargetOSMWayID: edgeAsToVertex.WayID,
+		SourceComponent: expandedEdgeComponent{
+			SourceNodeID: edgeAsFromVertex.SourceNodeID,
+			TargetNodeID: edgeAsFromVertex.TargetNodeID,
+		},
+		TargeComponent: expandedEdgeComponent{
+			SourceNodeID: edgeAsToVertex.SourceNodeID,
+			TargetNodeID: edgeAsToVertex.TargetNodeID,
+		},
+		CostMeters: (costMetersFromVertex+costMetersToVertex)/2.0 + penalty,
+		WasOneway:  edgeAsFromVertex.WasOneway,
+		Geom:       completedNewGeom,
+	}
+}
@@ -0,0 +1,106 @@
+package osm2ch
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/osm"
+)
+
+// prepareGridEdges returns edges of two-way grid of (size x size) nodes. Every street segment has an intermediate point
+func prepareGridEdges(size int) []Edge {
+	edges := []Edge{}
+	nodeID := func(i, j int) osm.NodeID {
+		return osm.NodeID(i*size + j + 1)
+	}
+	nodePoint := func(i, j int) GeoPoint {
+		return GeoPoint{Lon: 37.6 + float64(j)*0.001, Lat: 55.7 + float64(i)*0.001}
+	}
+	addSegment := func(wayID osm.WayID, si, sj, ti, tj int) {
+		source, target := nodePoint(si, sj), nodePoint(ti, tj)
+		geom := []GeoPoint{source, {Lon: (source.Lon + target.Lon) / 2.0, Lat: (source.Lat+target.Lat)/2.0 + 0.0001}, target}
+		cost := getSphericalLength(geom)
+		edges = append(edges, Edge{
+			ID:           EdgeID(len(edges) + 1),
+			WayID:        wayID,
+			SourceNodeID: nodeID(si, sj),
+			TargetNodeID: nodeID(ti, tj),
+			CostMeters:   cost,
+			Geom:         geom,
+		})
+		edges = append(edges, Edge{
+			ID:           EdgeID(len(edges) + 1),
+			WayID:        wayID,
+			SourceNodeID: nodeID(ti, tj),
+			TargetNodeID: nodeID(si, sj),
+			CostMeters:   cost,
+			Geom:         reverseLine(geom),
+		})
+	}
+	for i := 0; i < size; i++ {
+		for j := 0; j < size-1; j++ {
+			addSegment(osm.WayID(i+1), i, j, i, j+1)
+			addSegment(osm.WayID(size+j+1), j, i, j+1, i)
+		}
+	}
+	return edges
+}
+
+func TestExpandEdgesParallel(t *testing.T) {
+	edges := prepareGridEdges(12)
+	sequential, sequentialCycles := expandEdges(edges, expansionOptions{workers: 1})
+	if len(sequential) == 0 {
+		t.Fatalf("Expanded graph should not be empty")
+	}
+	for _, workers := range []int{2, 3, 7, 16, len(edges) + 5} {
+		parallel, parallelCycles := expandEdges(edges, expansionOptions{workers: workers})
+		if parallelCycles != sequentialCycles {
+			t.Errorf("Number of cycles should be %d, but got %d (workers = %d)", sequentialCycles, parallelCycles, workers)
+		}
+		if !reflect.DeepEqual(sequential, parallel) {
+			t.Errorf("Parallel edge expansion (workers = %d) should produce the same output as sequential one", workers)
+		}
+	}
+}
+
+func TestExpandEdgesDeadEndUTurns(t *testing.T) {
+	// Two-way T-junction: node 2 is intersection, nodes 1, 3 and 4 are dead ends
+	points := map[osm.NodeID]GeoPoint{
+		1: {Lon: 37.600, Lat: 55.700},
+		2: {Lon: 37.601, Lat: 55.700},
+		3: {Lon: 37.602, Lat: 55.700},
+		4: {Lon: 37.601, Lat: 55.701},
+	}
+	edges := []Edge{}
+	for _, pair := range [][2]osm.NodeID{{1, 2}, {2, 3}, {2, 4}} {
+		geom := []GeoPoint{points[pair[0]], points[pair[1]]}
+		cost := getSphericalLength(geom)
+		edges = append(edges, Edge{ID: EdgeID(len(edges) + 1), WayID: osm.WayID(pair[0]*10 + pair[1]), SourceNodeID: pair[0], TargetNodeID: pair[1], CostMeters: cost, Geom: geom})
+		edges = append(edges, Edge{ID: EdgeID(len(edges) + 1), WayID: osm.WayID(pair[0]*10 + pair[1]), SourceNodeID: pair[1], TargetNodeID: pair[0], CostMeters: cost, Geom: reverseLine(geom)})
+	}
+
+	expanded, cycles := expandEdges(edges, expansionOptions{workers: 1})
+	// Each of 3 incoming edges of node 2 has 2 transitions
+	if len(expanded) != 6 || cycles != 6 {
+		t.Errorf("Without u-turns there should be 6 expanded edges and 6 ignored cycles, but got %d and %d", len(expanded), cycles)
+	}
+
+	penalty := 0.5
+	expanded, cycles = expandEdges(edges, expansionOptions{workers: 1, deadEndUTurns: true, uTurnPenalty: penalty})
+	if len(expanded) != 9 || cycles != 3 {
+		t.Errorf("With u-turns at dead ends there should be 9 expanded edges and 3 ignored cycles, but got %d and %d", len(expanded), cycles)
+	}
+	for _, edge := range expanded {
+		if edge.SourceOSMWayID != edge.TargetOSMWayID {
+			continue
+		}
+		if edge.SourceComponent.TargetNodeID == 2 {
+			t.Errorf("U-turn at intersection should be forbidden")
+		}
+		correctCost := edges[edge.Source-1].CostMeters + penalty
+		if math.Abs(edge.CostMeters-correctCost) > 1e-12 {
+			t.Errorf("Cost of u-turn should be %f, but got %f", correctCost, edge.CostMeters)
+		}
+	}
+}
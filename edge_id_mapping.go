@@ -0,0 +1,173 @@
+package osm2ch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/paulmach/osm"
+	"github.com/pkg/errors"
+)
+
+// EdgeKey identifies edge by OSM data which edge has been built from
+/*
+	Seq is number of edge among edges with the same way and nodes (e.g. closed two-way ring consisting of single edge gives two edges
+	with the same source and target nodes)
+*/
+type EdgeKey struct {
+	WayID        osm.WayID
+	SourceNodeID osm.NodeID
+	TargetNodeID osm.NodeID
+	Seq          int
+}
+
+// EdgeIDMapping Persistent mapping between edges and their IDs (which are IDs of vertices in expanded graph)
+/*
+	Mapping could be reused across runs on refreshed OSM data: edges which already have been seen keep their IDs,
+	new edges get new IDs (greater than any of previously assigned ones). IDs are never reused.
+*/
+type EdgeIDMapping struct {
+	ids   map[EdgeKey]EdgeID
+	maxID EdgeID
+}
+
+// NewEdgeIDMapping Returns empty mapping
+func NewEdgeIDMapping() *EdgeIDMapping {
+	return &EdgeIDMapping{
+		ids: make(map[EdgeKey]EdgeID),
+	}
+}
+
+// Len Returns number of known edges
+func (mapping *EdgeIDMapping) Len() int {
+	return len(mapping.ids)
+}
+
+// Get Returns ID for given key
+func (mapping *EdgeIDMapping) Get(key EdgeKey) (EdgeID, bool) {
+	id, ok := mapping.ids[key]
+	return id, ok
+}
+
+// assign Replaces IDs of edges with IDs from mapping. Edges which are not in mapping yet get new IDs in order of given slice
+/*
+	Returns number of newly assigned IDs
+*/
+func (mapping *EdgeIDMapping) assign(edges []Edge) int {
+	newIDs := 0
+	seqs := make(map[EdgeKey]int)
+	for i := range edges {
+		key := EdgeKey{
+			WayID:        edges[i].WayID,
+			SourceNodeID: edges[i].SourceNodeID,
+			TargetNodeID: edges[i].TargetNodeID,
+		}
+		key.Seq = seqs[key]
+		seqs[key]++
+		id, ok := mapping.ids[key]
+		if !ok {
+			mapping.maxID++
+			id = mapping.maxID
+			mapping.ids[key] = id
+			newIDs++
+		}
+		edges[i].ID = id
+	}
+	return newIDs
+}
+
+// LoadEdgeIDMapping Loads mapping from CSV-file with header: way_id;source_node_id;target_node_id;seq;edge_id
+/*
+	If file does not exist then empty mapping is returned
+*/
+func LoadEdgeIDMapping(fname string) (*EdgeIDMapping, error) {
+	mapping := NewEdgeIDMapping()
+	file, err := os.Open(fname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return mapping, nil
+		}
+		return nil, errors.Wrap(err, "Can't open mapping file")
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.Comma = ';'
+	// Skip header of CSV-file
+	_, err = reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return mapping, nil
+		}
+		return nil, errors.Wrap(err, "Can't read header of mapping file")
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Can't read mapping record")
+		}
+		values := make([]int64, 5)
+		for i := range values {
+			values[i], err = strconv.ParseInt(record[i], 10, 64)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("Can't parse column %d of mapping record", i+1))
+			}
+		}
+		key := EdgeKey{
+			WayID:        osm.WayID(values[0]),
+			SourceNodeID: osm.NodeID(values[1]),
+			TargetNodeID: osm.NodeID(values[2]),
+			Seq:          int(values[3]),
+		}
+		id := EdgeID(values[4])
+		mapping.ids[key] = id
+		if id > mapping.maxID {
+			mapping.maxID = id
+		}
+	}
+	return mapping, nil
+}
+
+// SaveToFile Saves mapping to CSV-file with header: way_id;source_node_id;target_node_id;seq;edge_id
+/*
+	Rows are sorted by edge_id
+*/
+func (mapping *EdgeIDMapping) SaveToFile(fname string) error {
+	file, err := os.Create(fname)
+	if err != nil {
+		return errors.Wrap(err, "Can't create mapping file")
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+	writer.Comma = ';'
+	err = writer.Write([]string{"way_id", "source_node_id", "target_node_id", "seq", "edge_id"})
+	if err != nil {
+		return errors.Wrap(err, "Can't write header to mapping file")
+	}
+	keys := make([]EdgeKey, 0, len(mapping.ids))
+	for key := range mapping.ids {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return mapping.ids[keys[i]] < mapping.ids[keys[j]]
+	})
+	for _, key := range keys {
+		err = writer.Write([]string{
+			fmt.Sprintf("%d", key.WayID),
+			fmt.Sprintf("%d", key.SourceNodeID),
+			fmt.Sprintf("%d", key.TargetNodeID),
+			fmt.Sprintf("%d", key.Seq),
+			fmt.Sprintf("%d", mapping.ids[key]),
+		})
+		if err != nil {
+			return errors.Wrap(err, "Can't write mapping record")
+		}
+	}
+	return nil
+}
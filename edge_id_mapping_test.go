@@ -0,0 +1,74 @@
+package osm2ch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/osm"
+)
+
+func TestEdgeIDMappingRoundTrip(t *testing.T) {
+	cfg := OsmConfiguration{
+		EntityName: "highway",
+		Tags:       []string{"residential"},
+		Workers:    1,
+	}
+	nodes, ways, relations := prepareGridOSM(4)
+	state := prepareTestGraphState(t, nodes, ways, relations, &cfg)
+	idsBefore := make(map[EdgeKey]EdgeID)
+	seqs := make(map[EdgeKey]int)
+	for _, edge := range state.Edges() {
+		key := EdgeKey{WayID: edge.WayID, SourceNodeID: edge.SourceNodeID, TargetNodeID: edge.TargetNodeID}
+		key.Seq = seqs[key]
+		seqs[key]++
+		idsBefore[key] = edge.ID
+	}
+
+	dir, err := ioutil.TempDir("", "osm2ch_idmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "idmap.csv")
+	err = state.EdgeIDs().SaveToFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := LoadEdgeIDMapping(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapping.Len() != len(idsBefore) || mapping.maxID != state.EdgeIDs().maxID {
+		t.Fatalf("Loaded mapping should have %d edges (max ID = %d), but got %d (max ID = %d)", len(idsBefore), state.EdgeIDs().maxID, mapping.Len(), mapping.maxID)
+	}
+	maxID := mapping.maxID
+
+	// Re-import with new way which is not connected to others, so existing edges are not split
+	nodes[3000] = &osm.Node{ID: 3000, Version: 1, Lon: 37.59, Lat: 55.69}
+	nodes[3001] = &osm.Node{ID: 3001, Version: 1, Lon: 37.591, Lat: 55.69}
+	ways[300] = &osm.Way{ID: 300, Version: 1, Tags: osm.Tags{{Key: "highway", Value: "residential"}}, Nodes: osm.WayNodes{{ID: 3000}, {ID: 3001}}}
+	cfg.EdgeIDs = mapping
+	reimported := prepareTestGraphState(t, nodes, ways, relations, &cfg)
+	newID := maxID
+	seqs = make(map[EdgeKey]int)
+	for _, edge := range reimported.Edges() {
+		key := EdgeKey{WayID: edge.WayID, SourceNodeID: edge.SourceNodeID, TargetNodeID: edge.TargetNodeID}
+		key.Seq = seqs[key]
+		seqs[key]++
+		if id, ok := idsBefore[key]; ok {
+			if edge.ID != id {
+				t.Errorf("Edge %+v should keep ID %d, but got %d", key, id, edge.ID)
+			}
+			continue
+		}
+		newID++
+		if edge.ID != newID {
+			t.Errorf("New edge %+v should get ID %d, but got %d", key, newID, edge.ID)
+		}
+	}
+	if newID != maxID+2 {
+		t.Errorf("Two-way new way should give 2 new edges, but got %d", newID-maxID)
+	}
+}
@@ -0,0 +1,180 @@
+package osm2ch
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Minimal FlatBuffers encoder (enough for FlatGeobuf headers and features)
+/*
+	Objects are laid out forward: every table is followed by objects it references, so all offsets are positive
+	as FlatBuffers requires. Vtables are not shared. See ref. https://flatbuffers.dev/md__internals.html
+*/
+
+// fbObject Non-scalar FlatBuffers object (table, vector or string) referenced by offset
+type fbObject interface {
+	// write Appends object to buffer and returns position of the object
+	write(buf []byte) ([]byte, int)
+}
+
+// fbTable Fields of table, index of field is its ID in schema. Zero value of field means absent field
+type fbTable []fbField
+
+// fbField Field of table: either little-endian scalar or reference to another object
+type fbField struct {
+	scalar []byte
+	object fbObject
+}
+
+// fbString FlatBuffers string
+type fbString string
+
+// fbBytes Vector of ubyte
+type fbBytes []byte
+
+// fbFloat64Vector Vector of double
+type fbFloat64Vector []float64
+
+// fbTableVector Vector of tables
+type fbTableVector []fbTable
+
+func fbUint8(v uint8) fbField {
+	return fbField{scalar: []byte{v}}
+}
+
+func fbUint16(v uint16) fbField {
+	scalar := make([]byte, 2)
+	binary.LittleEndian.PutUint16(scalar, v)
+	return fbField{scalar: scalar}
+}
+
+func fbInt32(v int32) fbField {
+	scalar := make([]byte, 4)
+	binary.LittleEndian.PutUint32(scalar, uint32(v))
+	return fbField{scalar: scalar}
+}
+
+func fbUint64(v uint64) fbField {
+	scalar := make([]byte, 8)
+	binary.LittleEndian.PutUint64(scalar, v)
+	return fbField{scalar: scalar}
+}
+
+func fbRef(object fbObject) fbField {
+	return fbField{object: object}
+}
+
+// size Returns size of field in inline part of table (zero for absent field)
+func (field fbField) size() int {
+	if field.object != nil {
+		return 4
+	}
+	return len(field.scalar)
+}
+
+// prepareFlatBuffer Returns buffer with given root table
+func prepareFlatBuffer(root fbTable) []byte {
+	buf, pos := root.write(make([]byte, 4, 64))
+	binary.LittleEndian.PutUint32(buf, uint32(pos))
+	return buf
+}
+
+// fbPad Appends zeros until (len(buf) + extra) is multiple of align
+func fbPad(buf []byte, align, extra int) []byte {
+	for (len(buf)+extra)%align != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+func (t fbTable) write(buf []byte) ([]byte, int) {
+	// Inline part: offset to vtable and then fields aligned by their sizes
+	offsets := make([]int, len(t))
+	size := 4
+	for i, field := range t {
+		n := field.size()
+		if n == 0 {
+			continue
+		}
+		size = (size + n - 1) / n * n
+		offsets[i] = size
+		size += n
+	}
+	buf = fbPad(buf, 2, 0)
+	vtablePos := len(buf)
+	buf = appendUint16LE(buf, uint16(4+2*len(t)))
+	buf = appendUint16LE(buf, uint16(size))
+	for _, offset := range offsets {
+		buf = appendUint16LE(buf, uint16(offset))
+	}
+	// Table is aligned by the largest scalar
+	buf = fbPad(buf, 8, 0)
+	tablePos := len(buf)
+	buf = append(buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(buf[tablePos:], uint32(int32(tablePos-vtablePos)))
+	for i, field := range t {
+		copy(buf[tablePos+offsets[i]:], field.scalar)
+	}
+	for i, field := range t {
+		if field.object == nil {
+			continue
+		}
+		var pos int
+		buf, pos = field.object.write(buf)
+		fieldPos := tablePos + offsets[i]
+		binary.LittleEndian.PutUint32(buf[fieldPos:], uint32(pos-fieldPos))
+	}
+	return buf, tablePos
+}
+
+func (s fbString) write(buf []byte) ([]byte, int) {
+	buf = fbPad(buf, 4, 0)
+	pos := len(buf)
+	buf = appendUint32LE(buf, uint32(len(s)))
+	buf = append(buf, s...)
+	return append(buf, 0), pos
+}
+
+func (v fbBytes) write(buf []byte) ([]byte, int) {
+	buf = fbPad(buf, 4, 0)
+	pos := len(buf)
+	buf = appendUint32LE(buf, uint32(len(v)))
+	return append(buf, v...), pos
+}
+
+func (v fbFloat64Vector) write(buf []byte) ([]byte, int) {
+	// Elements (not length) should be aligned by 8
+	buf = fbPad(buf, 8, 4)
+	pos := len(buf)
+	buf = appendUint32LE(buf, uint32(len(v)))
+	for _, f := range v {
+		buf = appendUint64LE(buf, math.Float64bits(f))
+	}
+	return buf, pos
+}
+
+func (v fbTableVector) write(buf []byte) ([]byte, int) {
+	buf = fbPad(buf, 4, 0)
+	pos := len(buf)
+	buf = appendUint32LE(buf, uint32(len(v)))
+	buf = append(buf, make([]byte, 4*len(v))...)
+	for i, t := range v {
+		var tablePos int
+		buf, tablePos = t.write(buf)
+		elemPos := pos + 4 + 4*i
+		binary.LittleEndian.PutUint32(buf[elemPos:], uint32(tablePos-elemPos))
+	}
+	return buf, pos
+}
+
+func appendUint16LE(buf []byte, v uint16) []byte {
+	return append(buf, byte(v), byte(v>>8))
+}
+
+func appendUint32LE(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64LE(buf []byte, v uint64) []byte {
+	return appendUint32LE(appendUint32LE(buf, uint32(v)), uint32(v>>32))
+}
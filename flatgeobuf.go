@@ -0,0 +1,303 @@
+package osm2ch
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	flatGeobufIndexNodeSize = 16
+	// Size of node of packed R-tree: bounding box (4 doubles) and offset
+	flatGeobufNodeItemSize = 40
+
+	flatGeobufGeometryPoint      = 1
+	flatGeobufGeometryLineString = 2
+
+	flatGeobufColumnBool   = 2
+	flatGeobufColumnInt    = 5
+	flatGeobufColumnLong   = 7
+	flatGeobufColumnDouble = 10
+)
+
+// flatGeobufMagic FlatGeobuf 3.x
+var flatGeobufMagic = []byte{'f', 'g', 'b', 3, 'f', 'g', 'b', 0}
+
+// flatGeobufColumn Description of attribute column
+type flatGeobufColumn struct {
+	name       string
+	columnType uint8
+}
+
+// WriteFlatGeobufFiles Writes expanded edges and vertices into two FlatGeobuf files (see WriteFlatGeobufEdges and WriteFlatGeobufVertices)
+func WriteFlatGeobufFiles(edgesFname, verticesFname string, expandedEdges []ExpandedEdge, vertices []Vertex) error {
+	fileEdges, err := os.Create(edgesFname)
+	if err != nil {
+		return errors.Wrap(err, "Can't create FlatGeobuf file for edges")
+	}
+	defer fileEdges.Close()
+	err = WriteFlatGeobufEdges(fileEdges, expandedEdges)
+	if err != nil {
+		return err
+	}
+	fileVertices, err := os.Create(verticesFname)
+	if err != nil {
+		return errors.Wrap(err, "Can't create FlatGeobuf file for vertices")
+	}
+	defer fileVertices.Close()
+	err = WriteFlatGeobufVertices(fileVertices, vertices)
+	if err != nil {
+		return err
+	}
+	err = fileEdges.Close()
+	if err != nil {
+		return err
+	}
+	return fileVertices.Close()
+}
+
+// WriteFlatGeobufEdges Writes expanded edges as FlatGeobuf dataset "edges" of LineString features with spatial index
+/*
+	Columns: edge_id, from_vertex_id, to_vertex_id, weight, was_one_way, osm_way_from, osm_way_to, osm_way_from_source_node,
+	osm_way_from_target_node, osm_way_to_source_node, osm_way_to_target_node (the same as columns of edges CSV-file).
+	Expanded edges having less than two points are skipped (the same way as for CSV-file).
+	Features are written in Hilbert order of their bounding boxes, so subsets could be fetched via HTTP range requests
+*/
+func WriteFlatGeobufEdges(w io.Writer, expandedEdges []ExpandedEdge) error {
+	columns := []flatGeobufColumn{
+		{"edge_id", flatGeobufColumnLong},
+		{"from_vertex_id", flatGeobufColumnLong},
+		{"to_vertex_id", flatGeobufColumnLong},
+		{"weight", flatGeobufColumnDouble},
+		{"was_one_way", flatGeobufColumnBool},
+		{"osm_way_from", flatGeobufColumnLong},
+		{"osm_way_to", flatGeobufColumnLong},
+		{"osm_way_from_source_node", flatGeobufColumnLong},
+		{"osm_way_from_target_node", flatGeobufColumnLong},
+		{"osm_way_to_source_node", flatGeobufColumnLong},
+		{"osm_way_to_target_node", flatGeobufColumnLong},
+	}
+	edges := make([]ExpandedEdge, 0, len(expandedEdges))
+	boxes := make([]boundingBox, 0, len(expandedEdges))
+	for _, edge := range expandedEdges {
+		if len(edge.Geom) < 2 {
+			continue
+		}
+		edges = append(edges, edge)
+		boxes = append(boxes, lineBoundingBox(edge.Geom))
+	}
+	prepareFeature := func(i int) ([]byte, error) {
+		edge := edges[i]
+		properties, err := prepareFlatGeobufProperties(edge.ID, int64(edge.Source), int64(edge.Target), edge.CostMeters, edge.WasOneway,
+			int64(edge.SourceOSMWayID), int64(edge.TargetOSMWayID),
+			int64(edge.SourceComponent.SourceNodeID), int64(edge.SourceComponent.TargetNodeID),
+			int64(edge.TargeComponent.SourceNodeID), int64(edge.TargeComponent.TargetNodeID),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return prepareFlatGeobufFeature(edge.Geom, properties), nil
+	}
+	return writeFlatGeobuf(w, "edges", flatGeobufGeometryLineString, columns, boxes, prepareFeature)
+}
+
+// WriteFlatGeobufVertices Writes vertices as FlatGeobuf dataset "vertices" of Point features with spatial index
+/*
+	Columns: vertex_id, order_pos, importance (the same as columns of vertices CSV-file)
+*/
+func WriteFlatGeobufVertices(w io.Writer, vertices []Vertex) error {
+	columns := []flatGeobufColumn{
+		{"vertex_id", flatGeobufColumnLong},
+		{"order_pos", flatGeobufColumnLong},
+		{"importance", flatGeobufColumnInt},
+	}
+	boxes := make([]boundingBox, len(vertices))
+	for i, vertex := range vertices {
+		boxes[i] = lineBoundingBox([]GeoPoint{vertex.Geom})
+	}
+	prepareFeature := func(i int) ([]byte, error) {
+		vertex := vertices[i]
+		properties, err := prepareFlatGeobufProperties(vertex.ID, vertex.OrderPos, int32(vertex.Importance))
+		if err != nil {
+			return nil, err
+		}
+		return prepareFlatGeobufFeature([]GeoPoint{vertex.Geom}, properties), nil
+	}
+	return writeFlatGeobuf(w, "vertices", flatGeobufGeometryPoint, columns, boxes, prepareFeature)
+}
+
+// writeFlatGeobuf Writes FlatGeobuf file: magic bytes, header, packed Hilbert R-tree and features
+/*
+	boxes - bounding boxes of features, prepareFeature - returns size-prefixed feature by its index (its error stops writing).
+	Features are encoded twice (to evaluate offsets for index and then to write them), so encoded features are never held in memory.
+	See ref. https://github.com/flatgeobuf/flatgeobuf/blob/master/src/fbs/header.fbs
+*/
+func writeFlatGeobuf(w io.Writer, name string, geometryType uint8, columns []flatGeobufColumn, boxes []boundingBox, prepareFeature func(i int) ([]byte, error)) error {
+	bw := bufio.NewWriter(w)
+	extent := emptyBoundingBox()
+	for _, bbox := range boxes {
+		extent.extend(bbox)
+	}
+
+	columnsTables := make(fbTableVector, len(columns))
+	for i, column := range columns {
+		columnsTables[i] = fbTable{fbRef(fbString(column.name)), fbUint8(column.columnType)}
+	}
+	header := fbTable{
+		fbRef(fbString(name)),
+		{},
+		fbUint8(geometryType),
+		{}, {}, {}, {},
+		fbRef(columnsTables),
+		fbUint64(uint64(len(boxes))),
+		fbUint16(flatGeobufIndexNodeSize),
+		fbRef(fbTable{fbRef(fbString("EPSG")), fbInt32(4326)}),
+	}
+	if !extent.isEmpty() {
+		header[1] = fbRef(fbFloat64Vector{extent.minLon, extent.minLat, extent.maxLon, extent.maxLat})
+	}
+	headerBuf := prepareFlatBuffer(header)
+	bw.Write(flatGeobufMagic)
+	bw.Write(appendUint32LE(nil, uint32(len(headerBuf))))
+	bw.Write(headerBuf)
+
+	order := hilbertOrder(boxes)
+	if len(order) > 0 {
+		// Leaves are referencing features by their offsets in features section
+		offsets := make([]uint64, len(order))
+		offset := uint64(0)
+		for pos, idx := range order {
+			offsets[pos] = offset
+			feature, err := prepareFeature(idx)
+			if err != nil {
+				return err
+			}
+			offset += uint64(len(feature))
+		}
+		err := writeFlatGeobufIndex(bw, boxes, order, offsets)
+		if err != nil {
+			return err
+		}
+	}
+	for _, idx := range order {
+		feature, err := prepareFeature(idx)
+		if err != nil {
+			return err
+		}
+		_, err = bw.Write(feature)
+		if err != nil {
+			return errors.Wrap(err, "Can't write FlatGeobuf feature")
+		}
+	}
+	err := bw.Flush()
+	if err != nil {
+		return errors.Wrap(err, "Can't write FlatGeobuf file")
+	}
+	return nil
+}
+
+// writeFlatGeobufIndex Writes packed Hilbert R-tree of FlatGeobuf
+/*
+	Nodes are stored level by level starting from the root, leaves are the last ones.
+	Offset of leaf is offset of feature in features section, offset of other node is position of its first child in tree
+*/
+func writeFlatGeobufIndex(w io.Writer, boxes []boundingBox, order []int, offsets []uint64) error {
+	// levelsNum[0] - number of leaves, the last level is the root
+	levelsNum := []int{len(order)}
+	nodesNum := len(order)
+	for n := len(order); ; {
+		n = (n + flatGeobufIndexNodeSize - 1) / flatGeobufIndexNodeSize
+		levelsNum = append(levelsNum, n)
+		nodesNum += n
+		if n == 1 {
+			break
+		}
+	}
+	levelsStart := make([]int, len(levelsNum))
+	start := nodesNum
+	for level, n := range levelsNum {
+		start -= n
+		levelsStart[level] = start
+	}
+	nodes := make([]boundingBox, nodesNum)
+	nodesOffsets := make([]uint64, nodesNum)
+	for pos, idx := range order {
+		nodes[levelsStart[0]+pos] = boxes[idx]
+		nodesOffsets[levelsStart[0]+pos] = offsets[pos]
+	}
+	for level := 0; level < len(levelsNum)-1; level++ {
+		parent := levelsStart[level+1]
+		end := levelsStart[level] + levelsNum[level]
+		for child := levelsStart[level]; child < end; child += flatGeobufIndexNodeSize {
+			bbox := emptyBoundingBox()
+			for _, childBox := range nodes[child:minInt(child+flatGeobufIndexNodeSize, end)] {
+				bbox.extend(childBox)
+			}
+			nodes[parent] = bbox
+			nodesOffsets[parent] = uint64(child)
+			parent++
+		}
+	}
+	item := make([]byte, flatGeobufNodeItemSize)
+	for i, bbox := range nodes {
+		binary.LittleEndian.PutUint64(item, math.Float64bits(bbox.minLon))
+		binary.LittleEndian.PutUint64(item[8:], math.Float64bits(bbox.minLat))
+		binary.LittleEndian.PutUint64(item[16:], math.Float64bits(bbox.maxLon))
+		binary.LittleEndian.PutUint64(item[24:], math.Float64bits(bbox.maxLat))
+		binary.LittleEndian.PutUint64(item[32:], nodesOffsets[i])
+		_, err := w.Write(item)
+		if err != nil {
+			return errors.Wrap(err, "Can't write FlatGeobuf index")
+		}
+	}
+	return nil
+}
+
+// prepareFlatGeobufFeature Returns size-prefixed FlatGeobuf feature (type of geometry is defined in header)
+func prepareFlatGeobufFeature(pts []GeoPoint, properties []byte) []byte {
+	xy := make(fbFloat64Vector, 0, 2*len(pts))
+	for _, pt := range pts {
+		xy = append(xy, pt.Lon, pt.Lat)
+	}
+	geometry := fbTable{{}, fbRef(xy)}
+	buf := prepareFlatBuffer(fbTable{fbRef(geometry), fbRef(fbBytes(properties))})
+	return append(appendUint32LE(make([]byte, 0, 4+len(buf)), uint32(len(buf))), buf...)
+}
+
+// prepareFlatGeobufProperties Returns properties of feature: values of columns (in order of columns) prefixed by their indices
+func prepareFlatGeobufProperties(values ...interface{}) ([]byte, error) {
+	properties := make([]byte, 0, 10*len(values))
+	for i, value := range values {
+		var err error
+		properties, err = appendFlatGeobufProperty(properties, uint16(i), value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return properties, nil
+}
+
+// appendFlatGeobufProperty Appends index of column and little-endian value (int64, int32, float64 or bool) to properties of feature
+func appendFlatGeobufProperty(properties []byte, column uint16, value interface{}) ([]byte, error) {
+	properties = appendUint16LE(properties, column)
+	switch v := value.(type) {
+	case int64:
+		return appendUint64LE(properties, uint64(v)), nil
+	case int32:
+		return appendUint32LE(properties, uint32(v)), nil
+	case float64:
+		return appendUint64LE(properties, math.Float64bits(v)), nil
+	case bool:
+		if v {
+			return append(properties, 1), nil
+		}
+		return append(properties, 0), nil
+	default:
+		return nil, fmt.Errorf("Unsupported type of FlatGeobuf property: %T", value)
+	}
+}
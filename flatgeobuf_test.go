@@ -0,0 +1,99 @@
+package osm2ch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestWriteFlatGeobufEdges(t *testing.T) {
+	expandedEdges, _ := expandEdges(prepareGridEdges(5), expansionOptions{workers: 1})
+	// Edge without proper geometry should be skipped
+	expandedEdges = append(expandedEdges, ExpandedEdge{ID: 1000, Source: 1, Target: 2, Geom: []GeoPoint{{Lon: 1, Lat: 1}}})
+	buf := bytes.Buffer{}
+	err := WriteFlatGeobufEdges(&buf, expandedEdges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !bytes.Equal(data[:8], flatGeobufMagic) {
+		t.Fatalf("Bad magic bytes: %x", data[:8])
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data[8:]))
+	header := data[12 : 12+headerSize]
+	root := int(binary.LittleEndian.Uint32(header))
+	featuresNum := len(expandedEdges) - 1
+	if got := binary.LittleEndian.Uint64(header[readFlatBufferField(header, root, 8):]); got != uint64(featuresNum) {
+		t.Errorf("Header should contain %d features, but got %d", featuresNum, got)
+	}
+	if got := header[readFlatBufferField(header, root, 2)]; got != flatGeobufGeometryLineString {
+		t.Errorf("Geometry type should be LineString, but got %d", got)
+	}
+
+	// Features are going after index: leaves + ceil(leaves / 16) + ... + root
+	nodesNum := featuresNum
+	for n := featuresNum; n > 1; {
+		n = (n + flatGeobufIndexNodeSize - 1) / flatGeobufIndexNodeSize
+		nodesNum += n
+	}
+	index := data[12+headerSize : 12+headerSize+nodesNum*flatGeobufNodeItemSize]
+	features := data[12+headerSize+len(index):]
+	featuresOffsets := map[uint64]bool{}
+	ids := map[int64]bool{}
+	for offset := 0; offset < len(features); {
+		featuresOffsets[uint64(offset)] = true
+		size := int(binary.LittleEndian.Uint32(features[offset:]))
+		feature := features[offset+4 : offset+4+size]
+		featureRoot := int(binary.LittleEndian.Uint32(feature))
+		// Properties are vector of bytes referenced by offset
+		propertiesPos := readFlatBufferField(feature, featureRoot, 1)
+		propertiesPos += int(binary.LittleEndian.Uint32(feature[propertiesPos:]))
+		properties := feature[propertiesPos+4 : propertiesPos+4+int(binary.LittleEndian.Uint32(feature[propertiesPos:]))]
+		if binary.LittleEndian.Uint16(properties) != 0 {
+			t.Errorf("The first property should be edge_id")
+		}
+		ids[int64(binary.LittleEndian.Uint64(properties[2:]))] = true
+		offset += 4 + size
+	}
+	if len(ids) != featuresNum || ids[1000] {
+		t.Errorf("Features should be written once for every edge with proper geometry")
+	}
+
+	// The root covers everything, leaves are referencing features
+	rootMinLon := math.Float64frombits(binary.LittleEndian.Uint64(index))
+	rootMaxLat := math.Float64frombits(binary.LittleEndian.Uint64(index[24:]))
+	extent := emptyBoundingBox()
+	for _, edge := range expandedEdges[:featuresNum] {
+		extent.extend(lineBoundingBox(edge.Geom))
+	}
+	if rootMinLon != extent.minLon || rootMaxLat != extent.maxLat {
+		t.Errorf("Root of index should cover all features")
+	}
+	for i := nodesNum - featuresNum; i < nodesNum; i++ {
+		offset := binary.LittleEndian.Uint64(index[i*flatGeobufNodeItemSize+32:])
+		if !featuresOffsets[offset] {
+			t.Errorf("Leaf %d is referencing to %d which is not a start of feature", i, offset)
+		}
+	}
+}
+
+func TestPrepareFlatGeobufProperties(t *testing.T) {
+	properties, err := prepareFlatGeobufProperties(int64(1), int32(2), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 2, 0, 0, 0, 2, 0, 1}
+	if !bytes.Equal(properties, expected) {
+		t.Errorf("Properties should be %v, but got %v", expected, properties)
+	}
+	if _, err = prepareFlatGeobufProperties(int64(1), "text"); err == nil {
+		t.Errorf("Values of unsupported types should not be accepted")
+	}
+}
+
+// readFlatBufferField Returns position of field of table in buffer (field should be present)
+func readFlatBufferField(buf []byte, tablePos int, field int) int {
+	vtablePos := tablePos - int(int32(binary.LittleEndian.Uint32(buf[tablePos:])))
+	return tablePos + int(binary.LittleEndian.Uint16(buf[vtablePos+4+2*field:]))
+}
@@ -0,0 +1,157 @@
+package osm2ch
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+// GeoJSONWriter Writes features one by one, so whole FeatureCollection is never held in memory
+/*
+	If newlineDelimited is true then every feature is written as single line without enclosing FeatureCollection
+	(newline-delimited GeoJSON, also known as GeoJSONSeq or NDJSON), otherwise features are wrapped into FeatureCollection.
+	Coordinates are rounded to given number of decimal digits (negative precision keeps coordinates as is)
+*/
+type GeoJSONWriter struct {
+	w                *bufio.Writer
+	newlineDelimited bool
+	precision        int
+	featuresNum      int
+	err              error
+}
+
+// NewGeoJSONWriter Returns writer of features. Close should be called to finish output
+func NewGeoJSONWriter(w io.Writer, newlineDelimited bool, precision int) *GeoJSONWriter {
+	gw := &GeoJSONWriter{
+		w:                bufio.NewWriter(w),
+		newlineDelimited: newlineDelimited,
+		precision:        precision,
+	}
+	if !newlineDelimited {
+		_, gw.err = gw.w.WriteString(`{"type":"FeatureCollection","features":[` + "\n")
+	}
+	return gw
+}
+
+// WriteEdge Writes expanded edge as LineString feature
+/*
+	Properties: edge_id, from_vertex_id, to_vertex_id, weight, was_one_way, osm_way_from, osm_way_to, osm_way_from_source_node,
+	osm_way_from_target_node, osm_way_to_source_node, osm_way_to_target_node (the same as columns of edges CSV-file).
+	ID of feature is ID of expanded edge. Expanded edges having less than two points get null geometry
+*/
+func (gw *GeoJSONWriter) WriteEdge(edge ExpandedEdge) error {
+	var feature *geojson.Feature
+	if len(edge.Geom) >= 2 {
+		pts2d := make([][]float64, len(edge.Geom))
+		for i := range edge.Geom {
+			pts2d[i] = []float64{roundCoordinate(edge.Geom[i].Lon, gw.precision), roundCoordinate(edge.Geom[i].Lat, gw.precision)}
+		}
+		feature = geojson.NewLineStringFeature(pts2d)
+	} else {
+		feature = geojson.NewFeature(nil)
+	}
+	feature.ID = edge.ID
+	feature.SetProperty("edge_id", edge.ID)
+	feature.SetProperty("from_vertex_id", edge.Source)
+	feature.SetProperty("to_vertex_id", edge.Target)
+	feature.SetProperty("weight", edge.CostMeters)
+	feature.SetProperty("was_one_way", edge.WasOneway)
+	feature.SetProperty("osm_way_from", edge.SourceOSMWayID)
+	feature.SetProperty("osm_way_to", edge.TargetOSMWayID)
+	feature.SetProperty("osm_way_from_source_node", edge.SourceComponent.SourceNodeID)
+	feature.SetProperty("osm_way_from_target_node", edge.SourceComponent.TargetNodeID)
+	feature.SetProperty("osm_way_to_source_node", edge.TargeComponent.SourceNodeID)
+	feature.SetProperty("osm_way_to_target_node", edge.TargeComponent.TargetNodeID)
+	return gw.writeFeature(feature)
+}
+
+// WriteVertex Writes vertex as Point feature
+/*
+	Properties: vertex_id, order_pos, importance (the same as columns of vertices CSV-file). ID of feature is ID of vertex
+*/
+func (gw *GeoJSONWriter) WriteVertex(vertex Vertex) error {
+	feature := geojson.NewPointFeature([]float64{roundCoordinate(vertex.Geom.Lon, gw.precision), roundCoordinate(vertex.Geom.Lat, gw.precision)})
+	feature.ID = vertex.ID
+	feature.SetProperty("vertex_id", vertex.ID)
+	feature.SetProperty("order_pos", vertex.OrderPos)
+	feature.SetProperty("importance", vertex.Importance)
+	return gw.writeFeature(feature)
+}
+
+func (gw *GeoJSONWriter) writeFeature(feature *geojson.Feature) error {
+	if gw.err != nil {
+		return gw.err
+	}
+	b, err := feature.MarshalJSON()
+	if err != nil {
+		gw.err = errors.Wrap(err, "Can't convert feature to GeoJSON")
+		return gw.err
+	}
+	if gw.featuresNum > 0 && !gw.newlineDelimited {
+		_, gw.err = gw.w.WriteString(",\n")
+	}
+	if gw.err == nil {
+		_, gw.err = gw.w.Write(b)
+	}
+	if gw.err == nil && gw.newlineDelimited {
+		gw.err = gw.w.WriteByte('\n')
+	}
+	gw.featuresNum++
+	return gw.err
+}
+
+// Close Finishes FeatureCollection and flushes buffered data. Underlying writer is not closed
+func (gw *GeoJSONWriter) Close() error {
+	if gw.err == nil && !gw.newlineDelimited {
+		_, gw.err = gw.w.WriteString("\n]}\n")
+	}
+	if gw.err == nil {
+		gw.err = gw.w.Flush()
+	}
+	return gw.err
+}
+
+// WriteGeoJSONFiles Writes expanded edges and vertices into two GeoJSON files (see GeoJSONWriter)
+func WriteGeoJSONFiles(edgesFname, verticesFname string, expandedEdges []ExpandedEdge, vertices []Vertex, newlineDelimited bool, precision int) error {
+	fileEdges, err := os.Create(edgesFname)
+	if err != nil {
+		return errors.Wrap(err, "Can't create GeoJSON file for edges")
+	}
+	defer fileEdges.Close()
+	writerEdges := NewGeoJSONWriter(fileEdges, newlineDelimited, precision)
+	for _, edge := range expandedEdges {
+		err = writerEdges.WriteEdge(edge)
+		if err != nil {
+			return err
+		}
+	}
+	err = writerEdges.Close()
+	if err != nil {
+		return err
+	}
+
+	fileVertices, err := os.Create(verticesFname)
+	if err != nil {
+		return errors.Wrap(err, "Can't create GeoJSON file for vertices")
+	}
+	defer fileVertices.Close()
+	writerVertices := NewGeoJSONWriter(fileVertices, newlineDelimited, precision)
+	for _, vertex := range vertices {
+		err = writerVertices.WriteVertex(vertex)
+		if err != nil {
+			return err
+		}
+	}
+	err = writerVertices.Close()
+	if err != nil {
+		return err
+	}
+	err = fileEdges.Close()
+	if err != nil {
+		return err
+	}
+	return fileVertices.Close()
+}
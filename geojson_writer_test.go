@@ -0,0 +1,81 @@
+package osm2ch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+func TestGeoJSONWriter(t *testing.T) {
+	expandedEdges := []ExpandedEdge{
+		{ID: 1, Source: 1, Target: 2, CostMeters: 0.25, WasOneway: true, SourceOSMWayID: 10, TargetOSMWayID: 11, Geom: []GeoPoint{{Lon: 1, Lat: 2}, {Lon: 3, Lat: 4}}},
+		{ID: 2, Source: 2, Target: 1, CostMeters: 0.5, Geom: []GeoPoint{{Lon: 3, Lat: 4}}},
+	}
+	vertices := []Vertex{{ID: 1, OrderPos: 5, Importance: 3, Geom: GeoPoint{Lon: 1, Lat: 2}}}
+	write := func(newlineDelimited bool) []byte {
+		buf := bytes.Buffer{}
+		gw := NewGeoJSONWriter(&buf, newlineDelimited, -1)
+		for _, edge := range expandedEdges {
+			if err := gw.WriteEdge(edge); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := gw.WriteVertex(vertices[0]); err != nil {
+			t.Fatal(err)
+		}
+		if err := gw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	fc, err := geojson.UnmarshalFeatureCollection(write(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Features) != 3 {
+		t.Fatalf("FeatureCollection should contain 3 features, but got %d", len(fc.Features))
+	}
+	edge := fc.Features[0]
+	if !edge.Geometry.IsLineString() || len(edge.Geometry.LineString) != 2 || edge.Geometry.LineString[1][0] != 3 {
+		t.Errorf("Bad geometry of edge: %v", edge.Geometry)
+	}
+	if edge.Properties["weight"] != 0.25 || edge.Properties["was_one_way"] != true || edge.Properties["osm_way_to"] != 11.0 || edge.ID != 1.0 {
+		t.Errorf("Bad properties of edge: %v", edge.Properties)
+	}
+	if fc.Features[1].Geometry != nil {
+		t.Errorf("Edge without proper geometry should get null geometry")
+	}
+	vertex := fc.Features[2]
+	if !vertex.Geometry.IsPoint() || vertex.Properties["order_pos"] != 5.0 || vertex.Properties["importance"] != 3.0 {
+		t.Errorf("Bad vertex: %v %v", vertex.Geometry, vertex.Properties)
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(bytes.NewReader(write(true)))
+	for scanner.Scan() {
+		feature := geojson.Feature{}
+		if err := json.Unmarshal(scanner.Bytes(), &feature); err != nil {
+			t.Errorf("Every line should be GeoJSON feature: %s", err)
+		}
+		lines++
+	}
+	if lines != 3 {
+		t.Errorf("Newline-delimited output should contain 3 lines, but got %d", lines)
+	}
+
+	buf := bytes.Buffer{}
+	gw := NewGeoJSONWriter(&buf, true, 3)
+	if err := gw.WriteVertex(Vertex{ID: 2, Geom: GeoPoint{Lon: 37.61234567, Lat: -0.0000001}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"coordinates":[37.612,0]`)) {
+		t.Errorf("Coordinates should be rounded to given precision, but got %s", buf.String())
+	}
+}
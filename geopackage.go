@@ -0,0 +1,419 @@
+package osm2ch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// "GPKG" in ASCII
+	geoPackageApplicationID = 0x47504B47
+	// GeoPackage 1.2
+	geoPackageUserVersion = 10200
+	geoPackageSRID        = 4326
+	// Maximum number of cells in node of SQLite R-tree with 2 dimensions (node size is 1228 bytes for pages of 4096 bytes)
+	rtreeMaxCells = 51
+	rtreeNodeSize = 4 + 24*rtreeMaxCells
+	// Value of last_change column of gpkg_contents. It is fixed (Unix epoch), so the same graph always gives the same file
+	geoPackageLastChange = "1970-01-01T00:00:00.000Z"
+)
+
+// WriteGeoPackageFile Writes expanded graph into GeoPackage file (SQLite database), so it could be opened in GIS software directly
+/*
+	Tables:
+		edges - expanded edges (LINESTRING) with the same columns as edges CSV-file
+		vertices - vertices (POINT) with the same columns as vertices CSV-file
+		shortcuts - shortcuts (attributes table without geometry) with the same columns as shortcuts CSV-file
+	Both geometry columns are named "geom", are in EPSG:4326 and have spatial index (gpkg_rtree_index extension).
+	Expanded edges having less than two points get NULL geometry. Weights are written as is (caller is responsible for units).
+	Output is byte-identical for the same input: last_change of gpkg_contents is fixed to Unix epoch.
+	Database is written from scratch without SQLite library, so existing file is overwritten
+*/
+func WriteGeoPackageFile(fname string, expandedEdges []ExpandedEdge, vertices []Vertex, shortcuts []Shortcut) error {
+	db, err := newSQLiteWriter(fname, geoPackageApplicationID, geoPackageUserVersion)
+	if err != nil {
+		return err
+	}
+	err = writeGeoPackage(db, expandedEdges, vertices, shortcuts)
+	if err != nil {
+		db.file.Close()
+		return err
+	}
+	return db.Close()
+}
+
+func writeGeoPackage(db *sqliteWriter, expandedEdges []ExpandedEdge, vertices []Vertex, shortcuts []Shortcut) error {
+	/* Edges */
+	edgesTable := db.createTable("edges", `CREATE TABLE "edges" (fid INTEGER PRIMARY KEY NOT NULL, geom LINESTRING, from_vertex_id INTEGER NOT NULL, to_vertex_id INTEGER NOT NULL, weight DOUBLE NOT NULL, was_one_way BOOLEAN NOT NULL, edge_id INTEGER NOT NULL, osm_way_from INTEGER NOT NULL, osm_way_to INTEGER NOT NULL, osm_way_from_source_node INTEGER NOT NULL, osm_way_from_target_node INTEGER NOT NULL, osm_way_to_source_node INTEGER NOT NULL, osm_way_to_target_node INTEGER NOT NULL)`)
+	edgesExtent := emptyBoundingBox()
+	edgesIDs := []int64{}
+	edgesBoxes := []boundingBox{}
+	for i, edge := range expandedEdges {
+		fid := int64(i + 1)
+		var geom interface{}
+		if len(edge.Geom) >= 2 {
+			bbox := lineBoundingBox(edge.Geom)
+			geom = prepareGeoPackageGeometry(prepareWKBLinestring(edge.Geom), &bbox)
+			edgesExtent.extend(bbox)
+			edgesIDs = append(edgesIDs, fid)
+			edgesBoxes = append(edgesBoxes, bbox)
+		}
+		wasOneway := int64(0)
+		if edge.WasOneway {
+			wasOneway = 1
+		}
+		err := edgesTable.insert(fid, nil, geom, int64(edge.Source), int64(edge.Target), edge.CostMeters, wasOneway, edge.ID,
+			int64(edge.SourceOSMWayID), int64(edge.TargetOSMWayID),
+			int64(edge.SourceComponent.SourceNodeID), int64(edge.SourceComponent.TargetNodeID),
+			int64(edge.TargeComponent.SourceNodeID), int64(edge.TargeComponent.TargetNodeID),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	err := edgesTable.finish()
+	if err != nil {
+		return err
+	}
+
+	/* Vertices */
+	verticesTable := db.createTable("vertices", `CREATE TABLE "vertices" (fid INTEGER PRIMARY KEY NOT NULL, geom POINT, vertex_id INTEGER NOT NULL, order_pos INTEGER NOT NULL, importance INTEGER NOT NULL)`)
+	verticesExtent := emptyBoundingBox()
+	verticesIDs := make([]int64, len(vertices))
+	verticesBoxes := make([]boundingBox, len(vertices))
+	for i, vertex := range vertices {
+		fid := int64(i + 1)
+		bbox := lineBoundingBox([]GeoPoint{vertex.Geom})
+		verticesExtent.extend(bbox)
+		verticesIDs[i] = fid
+		verticesBoxes[i] = bbox
+		err := verticesTable.insert(fid, nil, prepareGeoPackageGeometry(prepareWKBPoint(vertex.Geom), nil), vertex.ID, vertex.OrderPos, int64(vertex.Importance))
+		if err != nil {
+			return err
+		}
+	}
+	err = verticesTable.finish()
+	if err != nil {
+		return err
+	}
+
+	/* Shortcuts */
+	shortcutsTable := db.createTable("shortcuts", `CREATE TABLE "shortcuts" (fid INTEGER PRIMARY KEY NOT NULL, from_vertex_id INTEGER NOT NULL, to_vertex_id INTEGER NOT NULL, weight DOUBLE NOT NULL, via_vertex_id INTEGER NOT NULL)`)
+	for i, shortcut := range shortcuts {
+		err := shortcutsTable.insert(int64(i+1), nil, shortcut.From, shortcut.To, shortcut.Weight, shortcut.Via)
+		if err != nil {
+			return err
+		}
+	}
+	err = shortcutsTable.finish()
+	if err != nil {
+		return err
+	}
+
+	/* Spatial indices */
+	err = writeGeoPackageRTree(db, "edges", "geom", edgesIDs, edgesBoxes)
+	if err != nil {
+		return err
+	}
+	err = writeGeoPackageRTree(db, "vertices", "geom", verticesIDs, verticesBoxes)
+	if err != nil {
+		return err
+	}
+
+	/* Metadata tables */
+	srsTable := db.createTable("gpkg_spatial_ref_sys", `CREATE TABLE gpkg_spatial_ref_sys (srs_name TEXT NOT NULL, srs_id INTEGER NOT NULL PRIMARY KEY, organization TEXT NOT NULL, organization_coordsys_id INTEGER NOT NULL, definition  TEXT NOT NULL, description TEXT)`)
+	srsRows := []struct {
+		name, organization, definition, description string
+		id, organizationID                          int64
+	}{
+		{"Undefined cartesian SRS", "NONE", "undefined", "undefined cartesian coordinate reference system", -1, -1},
+		{"Undefined geographic SRS", "NONE", "undefined", "undefined geographic coordinate reference system", 0, 0},
+		{"WGS 84 geodetic", "EPSG", `GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AXIS["Latitude",NORTH],AXIS["Longitude",EAST],AUTHORITY["EPSG","4326"]]`, "longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid", geoPackageSRID, geoPackageSRID},
+	}
+	for _, row := range srsRows {
+		err = srsTable.insert(row.id, row.name, nil, row.organization, row.organizationID, row.definition, row.description)
+		if err != nil {
+			return err
+		}
+	}
+	err = srsTable.finish()
+	if err != nil {
+		return err
+	}
+
+	contentsTable := db.createTable("gpkg_contents", `CREATE TABLE gpkg_contents (table_name TEXT NOT NULL PRIMARY KEY, data_type TEXT NOT NULL, identifier TEXT UNIQUE, description TEXT DEFAULT '', last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')), min_x DOUBLE, min_y DOUBLE, max_x DOUBLE, max_y DOUBLE, srs_id INTEGER, CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys(srs_id))`)
+	contentsRows := []struct {
+		tableName, dataType, description string
+		extent                           *boundingBox
+	}{
+		{"edges", "features", "Expanded edges", &edgesExtent},
+		{"vertices", "features", "Vertices of expanded graph", &verticesExtent},
+		{"shortcuts", "attributes", "Shortcuts of contraction hierarchies", nil},
+	}
+	contentsKeys := [][]interface{}{}
+	identifiersKeys := [][]interface{}{}
+	for i, row := range contentsRows {
+		rowid := int64(i + 1)
+		values := []interface{}{row.tableName, row.dataType, row.tableName, row.description, geoPackageLastChange, nil, nil, nil, nil, nil}
+		if row.extent != nil {
+			values[9] = int64(geoPackageSRID)
+			if !row.extent.isEmpty() {
+				values[5], values[6], values[7], values[8] = row.extent.minLon, row.extent.minLat, row.extent.maxLon, row.extent.maxLat
+			}
+		}
+		err = contentsTable.insert(rowid, values...)
+		if err != nil {
+			return err
+		}
+		contentsKeys = append(contentsKeys, []interface{}{row.tableName, rowid})
+		identifiersKeys = append(identifiersKeys, []interface{}{row.tableName, rowid})
+	}
+	err = contentsTable.finish()
+	if err != nil {
+		return err
+	}
+	err = db.createIndex("sqlite_autoindex_gpkg_contents_1", "gpkg_contents", "", contentsKeys)
+	if err != nil {
+		return err
+	}
+	err = db.createIndex("sqlite_autoindex_gpkg_contents_2", "gpkg_contents", "", identifiersKeys)
+	if err != nil {
+		return err
+	}
+
+	geometryColumnsTable := db.createTable("gpkg_geometry_columns", `CREATE TABLE gpkg_geometry_columns (table_name TEXT NOT NULL, column_name TEXT NOT NULL, geometry_type_name TEXT NOT NULL, srs_id INTEGER NOT NULL, z TINYINT NOT NULL, m TINYINT NOT NULL, CONSTRAINT pk_geom_cols PRIMARY KEY (table_name, column_name), CONSTRAINT uk_gc_table_name UNIQUE (table_name), CONSTRAINT fk_gc_tn FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name), CONSTRAINT fk_gc_srs FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id))`)
+	geometryColumnsRows := [][]string{{"edges", "geom", "LINESTRING"}, {"vertices", "geom", "POINT"}}
+	primaryKeys := [][]interface{}{}
+	uniqueKeys := [][]interface{}{}
+	for i, row := range geometryColumnsRows {
+		rowid := int64(i + 1)
+		err = geometryColumnsTable.insert(rowid, row[0], row[1], row[2], int64(geoPackageSRID), int64(0), int64(0))
+		if err != nil {
+			return err
+		}
+		primaryKeys = append(primaryKeys, []interface{}{row[0], row[1], rowid})
+		uniqueKeys = append(uniqueKeys, []interface{}{row[0], rowid})
+	}
+	err = geometryColumnsTable.finish()
+	if err != nil {
+		return err
+	}
+	err = db.createIndex("sqlite_autoindex_gpkg_geometry_columns_1", "gpkg_geometry_columns", "", primaryKeys)
+	if err != nil {
+		return err
+	}
+	err = db.createIndex("sqlite_autoindex_gpkg_geometry_columns_2", "gpkg_geometry_columns", "", uniqueKeys)
+	if err != nil {
+		return err
+	}
+
+	extensionsTable := db.createTable("gpkg_extensions", `CREATE TABLE gpkg_extensions (table_name TEXT, column_name TEXT, extension_name TEXT NOT NULL, definition TEXT NOT NULL, scope TEXT NOT NULL, CONSTRAINT ge_tce UNIQUE (table_name, column_name, extension_name))`)
+	extensionsKeys := [][]interface{}{}
+	for i, tableName := range []string{"edges", "vertices"} {
+		rowid := int64(i + 1)
+		err = extensionsTable.insert(rowid, tableName, "geom", "gpkg_rtree_index", "http://www.geopackage.org/spec120/#extension_rtree", "write-only")
+		if err != nil {
+			return err
+		}
+		extensionsKeys = append(extensionsKeys, []interface{}{tableName, "geom", "gpkg_rtree_index", rowid})
+	}
+	err = extensionsTable.finish()
+	if err != nil {
+		return err
+	}
+	err = db.createIndex("sqlite_autoindex_gpkg_extensions_1", "gpkg_extensions", "", extensionsKeys)
+	if err != nil {
+		return err
+	}
+
+	// Triggers which keep spatial indices up to date (they use functions provided by GeoPackage-aware software)
+	for _, tableName := range []string{"edges", "vertices"} {
+		for _, trigger := range prepareGeoPackageRTreeTriggers(tableName, "geom", "fid") {
+			db.addSchemaEntry("trigger", trigger[0], tableName, trigger[1])
+		}
+	}
+	return nil
+}
+
+// prepareGeoPackageGeometry Returns geometry in GeoPackageBinary format for given WKB
+/*
+	bbox - envelope of geometry (could be nil, e.g. for points)
+*/
+func prepareGeoPackageGeometry(wkb []byte, bbox *boundingBox) []byte {
+	headerSize := 8
+	flags := byte(1) // little-endian
+	if bbox != nil {
+		headerSize += 32
+		flags |= 1 << 1 // envelope [minx, maxx, miny, maxy]
+	}
+	geom := make([]byte, headerSize, headerSize+len(wkb))
+	geom[0], geom[1] = 'G', 'P'
+	geom[3] = flags
+	binary.LittleEndian.PutUint32(geom[4:], geoPackageSRID)
+	if bbox != nil {
+		for i, v := range []float64{bbox.minLon, bbox.maxLon, bbox.minLat, bbox.maxLat} {
+			binary.LittleEndian.PutUint64(geom[8+8*i:], math.Float64bits(v))
+		}
+	}
+	return append(geom, wkb...)
+}
+
+// writeGeoPackageRTree Writes spatial index (R-tree virtual table of SQLite) for geometry column
+/*
+	ids - rowids of table (in ascending order), boxes - bounding boxes of geometries of corresponding rows.
+	R-tree is packed in Hilbert order of boxes. Shadow tables of virtual table are written directly.
+	See ref. https://www.sqlite.org/rtree.html and http://www.geopackage.org/spec120/#extension_rtree
+*/
+func writeGeoPackageRTree(db *sqliteWriter, tableName, columnName string, ids []int64, boxes []boundingBox) error {
+	name := fmt.Sprintf("rtree_%s_%s", tableName, columnName)
+	// levels[0] - bounding boxes of leaves, the last level is the root
+	order := hilbertOrder(boxes)
+	levels := [][]boundingBox{{}}
+	for start := 0; start < len(order); start += rtreeMaxCells {
+		bbox := emptyBoundingBox()
+		for _, idx := range order[start:minInt(start+rtreeMaxCells, len(order))] {
+			bbox.extend(boxes[idx])
+		}
+		levels[0] = append(levels[0], bbox)
+	}
+	for len(levels[len(levels)-1]) > 1 {
+		children := levels[len(levels)-1]
+		parents := []boundingBox{}
+		for start := 0; start < len(children); start += rtreeMaxCells {
+			bbox := emptyBoundingBox()
+			for _, child := range children[start:minInt(start+rtreeMaxCells, len(children))] {
+				bbox.extend(child)
+			}
+			parents = append(parents, bbox)
+		}
+		levels = append(levels, parents)
+	}
+	if len(levels[0]) == 0 {
+		// Empty root
+		levels[0] = append(levels[0], emptyBoundingBox())
+	}
+	// Nodes are numbered from the root (number 1) level by level
+	firstNode := make([]int64, len(levels))
+	nodesNum := int64(1)
+	for level := len(levels) - 1; level >= 0; level-- {
+		firstNode[level] = nodesNum
+		nodesNum += int64(len(levels[level]))
+	}
+
+	db.addSchemaEntry("table", name, name, fmt.Sprintf("CREATE VIRTUAL TABLE %s USING rtree(id, minx, maxx, miny, maxy)", name))
+	rowidTable := db.createTable(name+"_rowid", fmt.Sprintf(`CREATE TABLE "%s_rowid"(rowid INTEGER PRIMARY KEY,nodeno)`, name))
+	leafOf := make([]int64, len(ids))
+	for pos, idx := range order {
+		leafOf[idx] = firstNode[0] + int64(pos/rtreeMaxCells)
+	}
+	for i, id := range ids {
+		err := rowidTable.insert(id, nil, leafOf[i])
+		if err != nil {
+			return err
+		}
+	}
+	err := rowidTable.finish()
+	if err != nil {
+		return err
+	}
+
+	nodeTable := db.createTable(name+"_node", fmt.Sprintf(`CREATE TABLE "%s_node"(nodeno INTEGER PRIMARY KEY,data)`, name))
+	node := make([]byte, rtreeNodeSize)
+	for level := len(levels) - 1; level >= 0; level-- {
+		for i := range levels[level] {
+			for j := range node {
+				node[j] = 0
+			}
+			if level == len(levels)-1 {
+				// Depth of tree is stored in the root
+				binary.BigEndian.PutUint16(node, uint16(len(levels)-1))
+			}
+			cells := 0
+			addCell := func(id int64, bbox boundingBox) {
+				cell := node[4+24*cells:]
+				binary.BigEndian.PutUint64(cell, uint64(id))
+				binary.BigEndian.PutUint32(cell[8:], math.Float32bits(float32Down(bbox.minLon)))
+				binary.BigEndian.PutUint32(cell[12:], math.Float32bits(float32Up(bbox.maxLon)))
+				binary.BigEndian.PutUint32(cell[16:], math.Float32bits(float32Down(bbox.minLat)))
+				binary.BigEndian.PutUint32(cell[20:], math.Float32bits(float32Up(bbox.maxLat)))
+				cells++
+			}
+			start := i * rtreeMaxCells
+			if level == 0 {
+				for _, idx := range order[start:minInt(start+rtreeMaxCells, len(order))] {
+					addCell(ids[idx], boxes[idx])
+				}
+			} else {
+				children := levels[level-1]
+				for j := start; j < minInt(start+rtreeMaxCells, len(children)); j++ {
+					addCell(firstNode[level-1]+int64(j), children[j])
+				}
+			}
+			binary.BigEndian.PutUint16(node[2:], uint16(cells))
+			err := nodeTable.insert(firstNode[level]+int64(i), nil, node)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = nodeTable.finish()
+	if err != nil {
+		return err
+	}
+
+	parentTable := db.createTable(name+"_parent", fmt.Sprintf(`CREATE TABLE "%s_parent"(nodeno INTEGER PRIMARY KEY,parentnode)`, name))
+	for level := len(levels) - 2; level >= 0; level-- {
+		for i := range levels[level] {
+			err := parentTable.insert(firstNode[level]+int64(i), nil, firstNode[level+1]+int64(i/rtreeMaxCells))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return parentTable.finish()
+}
+
+// prepareGeoPackageRTreeTriggers Returns names and SQL of triggers maintaining spatial index (see GeoPackage 1.2 spec, "RTree Spatial Indexes")
+func prepareGeoPackageRTreeTriggers(tableName, columnName, idColumnName string) [][2]string {
+	templates := [][2]string{
+		{"insert", `CREATE TRIGGER "rtree_<t>_<c>_insert" AFTER INSERT ON "<t>" WHEN (new."<c>" NOT NULL AND NOT ST_IsEmpty(NEW."<c>")) BEGIN INSERT OR REPLACE INTO "rtree_<t>_<c>" VALUES (NEW."<i>", ST_MinX(NEW."<c>"), ST_MaxX(NEW."<c>"), ST_MinY(NEW."<c>"), ST_MaxY(NEW."<c>")); END`},
+		{"update1", `CREATE TRIGGER "rtree_<t>_<c>_update1" AFTER UPDATE OF "<c>" ON "<t>" WHEN OLD."<i>" = NEW."<i>" AND (NEW."<c>" NOTNULL AND NOT ST_IsEmpty(NEW."<c>")) BEGIN INSERT OR REPLACE INTO "rtree_<t>_<c>" VALUES (NEW."<i>", ST_MinX(NEW."<c>"), ST_MaxX(NEW."<c>"), ST_MinY(NEW."<c>"), ST_MaxY(NEW."<c>")); END`},
+		{"update2", `CREATE TRIGGER "rtree_<t>_<c>_update2" AFTER UPDATE OF "<c>" ON "<t>" WHEN OLD."<i>" = NEW."<i>" AND (NEW."<c>" ISNULL OR ST_IsEmpty(NEW."<c>")) BEGIN DELETE FROM "rtree_<t>_<c>" WHERE id = OLD."<i>"; END`},
+		{"update3", `CREATE TRIGGER "rtree_<t>_<c>_update3" AFTER UPDATE ON "<t>" WHEN OLD."<i>" != NEW."<i>" AND (NEW."<c>" NOTNULL AND NOT ST_IsEmpty(NEW."<c>")) BEGIN DELETE FROM "rtree_<t>_<c>" WHERE id = OLD."<i>"; INSERT OR REPLACE INTO "rtree_<t>_<c>" VALUES (NEW."<i>", ST_MinX(NEW."<c>"), ST_MaxX(NEW."<c>"), ST_MinY(NEW."<c>"), ST_MaxY(NEW."<c>")); END`},
+		{"update4", `CREATE TRIGGER "rtree_<t>_<c>_update4" AFTER UPDATE ON "<t>" WHEN OLD."<i>" != NEW."<i>" AND (NEW."<c>" ISNULL OR ST_IsEmpty(NEW."<c>")) BEGIN DELETE FROM "rtree_<t>_<c>" WHERE id IN (OLD."<i>", NEW."<i>"); END`},
+		{"delete", `CREATE TRIGGER "rtree_<t>_<c>_delete" AFTER DELETE ON "<t>" WHEN old."<c>" NOT NULL BEGIN DELETE FROM "rtree_<t>_<c>" WHERE id = OLD."<i>"; END`},
+	}
+	replacer := strings.NewReplacer("<t>", tableName, "<c>", columnName, "<i>", idColumnName)
+	triggers := make([][2]string, len(templates))
+	for i, template := range templates {
+		triggers[i] = [2]string{fmt.Sprintf("rtree_%s_%s_%s", tableName, columnName, template[0]), replacer.Replace(template[1])}
+	}
+	return triggers
+}
+
+// float32Down Returns the largest float32 which is not greater than given value
+func float32Down(v float64) float32 {
+	f := float32(v)
+	if float64(f) > v {
+		f = math.Nextafter32(f, float32(math.Inf(-1)))
+	}
+	return f
+}
+
+// float32Up Returns the smallest float32 which is not less than given value
+func float32Up(v float64) float32 {
+	f := float32(v)
+	if float64(f) < v {
+		f = math.Nextafter32(f, float32(math.Inf(1)))
+	}
+	return f
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,183 @@
+package osm2ch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteGeoPackageFile(t *testing.T) {
+	expandedEdges, _ := expandEdges(prepareGridEdges(6), expansionOptions{workers: 1})
+	graph, err := PrepareGraph(expandedEdges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vertices := PrepareVertices(graph, expandedEdges)
+	// Edge without geometry gets NULL geometry and is not indexed
+	expandedEdges = append(expandedEdges, ExpandedEdge{ID: int64(len(expandedEdges) + 1), Source: expandedEdges[0].Source, Target: expandedEdges[0].Target})
+
+	dir, err := ioutil.TempDir("", "osm2ch_gpkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := [][]byte{}
+	for _, name := range []string{"first.gpkg", "second.gpkg"} {
+		fname := filepath.Join(dir, name)
+		err = WriteGeoPackageFile(fname, expandedEdges, vertices, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadFile(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, data)
+	}
+	if !bytes.Equal(files[0], files[1]) {
+		t.Fatalf("The same graph should give byte-identical GeoPackage files")
+	}
+	data := files[0]
+	if binary.BigEndian.Uint32(data[68:]) != geoPackageApplicationID {
+		t.Errorf("Application ID should be 'GPKG'")
+	}
+
+	// Root pages of tables by names (schema rows: type, name, tbl_name, rootpage, sql)
+	rootPages := map[string]uint32{}
+	readSQLiteTable(t, data, 1, func(rowid int64, payload []byte) {
+		values := readSQLiteRecord(t, payload)
+		if values[0] == "table" {
+			rootPages[values[1].(string)] = uint32(values[3].(int64))
+		}
+	})
+	readRows := func(tableName string) map[int64][]interface{} {
+		rootPage, ok := rootPages[tableName]
+		if !ok {
+			t.Fatalf("Table '%s' is not found in schema", tableName)
+		}
+		rows := map[int64][]interface{}{}
+		if rootPage == 0 {
+			// Virtual table
+			return rows
+		}
+		readSQLiteTable(t, data, rootPage, func(rowid int64, payload []byte) {
+			rows[rowid] = readSQLiteRecord(t, payload)
+		})
+		return rows
+	}
+
+	edgesExtent := emptyBoundingBox()
+	indexed := map[int64]boundingBox{}
+	for i, edge := range expandedEdges {
+		if len(edge.Geom) >= 2 {
+			indexed[int64(i+1)] = lineBoundingBox(edge.Geom)
+			edgesExtent.extend(indexed[int64(i+1)])
+		}
+	}
+	contents := readRows("gpkg_contents")
+	if len(contents) != 3 {
+		t.Fatalf("gpkg_contents should have 3 rows, but got %d", len(contents))
+	}
+	for _, row := range contents {
+		if row[4] != geoPackageLastChange {
+			t.Errorf("last_change of '%v' should be %s, but got %v", row[0], geoPackageLastChange, row[4])
+		}
+		if row[0] == "edges" && (row[5] != edgesExtent.minLon || row[6] != edgesExtent.minLat || row[7] != edgesExtent.maxLon || row[8] != edgesExtent.maxLat || row[9] != int64(geoPackageSRID)) {
+			t.Errorf("Extent of edges should be %+v (SRID %d), but got %v", edgesExtent, geoPackageSRID, row[5:])
+		}
+	}
+	if rows := readRows("gpkg_geometry_columns"); len(rows) != 2 {
+		t.Errorf("gpkg_geometry_columns should have 2 rows, but got %d", len(rows))
+	}
+	for _, row := range readRows("gpkg_extensions") {
+		if row[2] != "gpkg_rtree_index" {
+			t.Errorf("Unexpected extension: %v", row)
+		}
+	}
+	if rows := readRows("gpkg_spatial_ref_sys"); rows[geoPackageSRID] == nil {
+		t.Errorf("gpkg_spatial_ref_sys should contain SRS %d", geoPackageSRID)
+	}
+	if _, ok := rootPages["rtree_edges_geom"]; !ok {
+		t.Errorf("Virtual table of spatial index is not found in schema")
+	}
+
+	// Every indexed edge should be in leaf node which cell covers its bounding box
+	rowids := readRows("rtree_edges_geom_rowid")
+	if len(rowids) != len(indexed) {
+		t.Fatalf("Spatial index should contain %d edges, but got %d", len(indexed), len(rowids))
+	}
+	nodes := readRows("rtree_edges_geom_node")
+	parents := readRows("rtree_edges_geom_parent")
+	if len(nodes) < 2 || len(parents) != len(nodes)-1 {
+		t.Fatalf("Spatial index should have several nodes with parents, but got %d nodes and %d parents", len(nodes), len(parents))
+	}
+	for fid, bbox := range indexed {
+		node, ok := nodes[rowids[fid][1].(int64)]
+		if !ok {
+			t.Fatalf("Node of edge %d is not found", fid)
+		}
+		blob := node[1].([]byte)
+		found := false
+		for i := 0; i < int(binary.BigEndian.Uint16(blob[2:])); i++ {
+			cell := blob[4+24*i:]
+			if int64(binary.BigEndian.Uint64(cell)) != fid {
+				continue
+			}
+			found = true
+			box := make([]float64, 4)
+			for j := range box {
+				box[j] = float64(math.Float32frombits(binary.BigEndian.Uint32(cell[8+4*j:])))
+			}
+			if box[0] > bbox.minLon || box[1] < bbox.maxLon || box[2] > bbox.minLat || box[3] < bbox.maxLat {
+				t.Errorf("Cell %v of edge %d does not cover its bounding box %+v", box, fid, bbox)
+			}
+		}
+		if !found {
+			t.Errorf("Edge %d is not found in its node", fid)
+		}
+	}
+}
+
+// readSQLiteRecord Decodes record of SQLite format (see prepareSQLiteRecord)
+func readSQLiteRecord(t *testing.T, payload []byte) []interface{} {
+	headerSize, n := readSQLiteVarint(payload)
+	header := payload[n:headerSize]
+	body := payload[headerSize:]
+	values := []interface{}{}
+	for len(header) > 0 {
+		serialType, n := readSQLiteVarint(header)
+		header = header[n:]
+		switch {
+		case serialType == 0:
+			values = append(values, nil)
+		case serialType == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(body)))
+			body = body[8:]
+		case serialType == 8 || serialType == 9:
+			values = append(values, int64(serialType-8))
+		case serialType <= 6:
+			size := []int{0, 1, 2, 3, 4, 6, 8}[serialType]
+			v := int64(int8(body[0]))
+			for i := 1; i < size; i++ {
+				v = v<<8 | int64(body[i])
+			}
+			values = append(values, v)
+			body = body[size:]
+		case serialType >= 12:
+			size := int(serialType-12) / 2
+			if serialType%2 == 0 {
+				values = append(values, append([]byte{}, body[:size]...))
+			} else {
+				values = append(values, string(body[:size]))
+			}
+			body = body[size:]
+		default:
+			t.Fatalf("Unexpected serial type %d", serialType)
+		}
+	}
+	return values
+}
@@ -4,6 +4,7 @@ go 1.14
 
 require (
 	github.com/LdDl/ch v1.7.7
+	github.com/klauspost/compress v1.13.6
 	github.com/paulmach/go.geojson v1.4.0
 	github.com/paulmach/orb v0.5.0 // indirect
 	github.com/paulmach/osm v0.3.0
@@ -0,0 +1,230 @@
+package osm2ch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/paulmach/osm"
+)
+
+// GraphState Intermediate data of import: filtered OSM data, edges and expanded edges
+/*
+	State allows to apply OsmChange diffs (see ApplyChange) without full rebuild of graph: only edges of impacted ways and
+	expanded edges adjacent to them are recomputed. Output after applying diff is the very same as output of full rebuild
+	on updated OSM data with the same mapping of edge IDs.
+*/
+type GraphState struct {
+	cfg     OsmConfiguration
+	data    *osmData
+	edgeIDs *EdgeIDMapping
+
+	edges            []Edge
+	edgesByWay       map[osm.WayID][]Edge
+	expandedBySource map[EdgeID][]ExpandedEdge // Expanded edges before applying restrictions. IDs are not set
+	expandedEdges    []ExpandedEdge
+}
+
+// newGraphState Builds graph from scratch for given OSM data
+func newGraphState(data *osmData, cfg *OsmConfiguration) (*GraphState, error) {
+	state := &GraphState{
+		cfg:              *cfg,
+		data:             data,
+		edgeIDs:          cfg.EdgeIDs,
+		edgesByWay:       make(map[osm.WayID][]Edge),
+		expandedBySource: make(map[EdgeID][]ExpandedEdge),
+	}
+	state.cfg.EdgeIDs = nil
+	if state.edgeIDs == nil {
+		// Empty mapping gives IDs from 1 in order of edges
+		state.edgeIDs = NewEdgeIDMapping()
+	}
+	err := state.rebuild(nil, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// ExpandedEdges Returns expanded graph
+func (state *GraphState) ExpandedEdges() []ExpandedEdge {
+	return state.expandedEdges
+}
+
+// Edges Returns edges (vertices of expanded graph) in order of ways
+func (state *GraphState) Edges() []Edge {
+	return state.edges
+}
+
+// WayTags Returns tags of OSM way which graph has been built from (nil if there is no such way in graph)
+/*
+	Tags are kept through the whole pipeline (including saved state and applied diffs), so they could be joined
+	to expanded edges via SourceOSMWayID and TargetOSMWayID
+*/
+func (state *GraphState) WayTags(wayID osm.WayID) osm.Tags {
+	return state.data.ways[wayID].TagMap
+}
+
+// Configuration Returns configuration which state has been built with (mapping of IDs is not included, see EdgeIDs)
+/*
+	Configuration is saved with state (see SaveToFile), so diffs are applied with the same filter of ways and the same u-turns options
+*/
+func (state *GraphState) Configuration() OsmConfiguration {
+	cfg := state.cfg
+	cfg.Tags = append([]string{}, cfg.Tags...)
+	return cfg
+}
+
+// EdgeIDs Returns mapping which is used to assign IDs to edges
+func (state *GraphState) EdgeIDs() *EdgeIDMapping {
+	return state.edgeIDs
+}
+
+// rebuild Recomputes edges and expanded edges
+/*
+	impactedWays - ways which have been created, modified or deleted
+	impactedNodes - nodes which have been moved or deleted
+	If full is true then everything is recomputed
+*/
+func (state *GraphState) rebuild(impactedWays map[osm.WayID]struct{}, impactedNodes map[osm.NodeID]struct{}, full bool) error {
+	data := state.data
+	waysIDs := data.sortedWaysIDs()
+
+	fmt.Printf("Counting node use cases...")
+	st := time.Now()
+	// Nodes which became intersections (or stopped being intersections) split ways in another way
+	wasIntersection := make(map[osm.NodeID]bool)
+	if !full {
+		for id, node := range data.nodes {
+			wasIntersection[id] = node.useCount > 1
+		}
+	}
+	err := data.countNodeUses()
+	if err != nil {
+		return err
+	}
+	for id, node := range data.nodes {
+		// Nodes which are not used by filtered ways are kept, since diff could make their ways satisfy configuration
+		if full {
+			continue
+		}
+		if was, ok := wasIntersection[id]; ok && was != (node.useCount > 1) {
+			if impactedNodes == nil {
+				impactedNodes = make(map[osm.NodeID]struct{})
+			}
+			impactedNodes[id] = struct{}{}
+		}
+	}
+	if !full && len(impactedNodes) != 0 {
+		if impactedWays == nil {
+			impactedWays = make(map[osm.WayID]struct{})
+		}
+		for _, wayID := range waysIDs {
+			for _, wayNode := range data.ways[wayID].Nodes {
+				if _, ok := impactedNodes[wayNode.ID]; ok {
+					impactedWays[wayID] = struct{}{}
+					break
+				}
+			}
+		}
+	}
+	fmt.Printf("Done in %v\n", time.Since(st))
+
+	fmt.Printf("Preparing edges...")
+	st = time.Now()
+	// Source nodes of edges which have been created, modified or deleted: outcoming transitions from those nodes should be recomputed
+	touchedNodes := make(map[osm.NodeID]struct{})
+	prepareWay := func(wayID osm.WayID) {
+		for _, edge := range state.edgesByWay[wayID] {
+			touchedNodes[edge.SourceNodeID] = struct{}{}
+			delete(state.expandedBySource, edge.ID)
+		}
+		way, ok := data.ways[wayID]
+		if !ok {
+			delete(state.edgesByWay, wayID)
+			return
+		}
+		wayEdges := prepareWayEdges(way, data.nodes)
+		for _, edge := range wayEdges {
+			touchedNodes[edge.SourceNodeID] = struct{}{}
+		}
+		state.edgesByWay[wayID] = wayEdges
+	}
+	if full {
+		state.edgesByWay = make(map[osm.WayID][]Edge, len(waysIDs))
+		state.expandedBySource = make(map[EdgeID][]ExpandedEdge)
+		for _, wayID := range waysIDs {
+			prepareWay(wayID)
+		}
+	} else {
+		for wayID := range impactedWays {
+			prepareWay(wayID)
+		}
+	}
+	edges := make([]Edge, 0, len(state.edges))
+	for _, wayID := range waysIDs {
+		edges = append(edges, state.edgesByWay[wayID]...)
+	}
+	newIDs := state.edgeIDs.assign(edges)
+	notOnewayEdges := 0
+	for start, i := 0, 0; i < len(waysIDs); i++ {
+		wayEdges := state.edgesByWay[waysIDs[i]]
+		state.edgesByWay[waysIDs[i]] = edges[start : start+len(wayEdges)]
+		start += len(wayEdges)
+		if !data.ways[waysIDs[i]].Oneway {
+			notOnewayEdges += len(wayEdges) / 2
+		}
+	}
+	state.edges = edges
+	fmt.Printf("Done in %v\n\tEdges: (oneway = %d), (not oneway = %d) (total = %d)\n\tNew IDs: %d\n", time.Since(st), len(edges)-notOnewayEdges, notOnewayEdges, len(edges), newIDs)
+
+	fmt.Printf("Applying edge expanding technique...")
+	st = time.Now()
+	sources := []int{}
+	for i, edge := range edges {
+		if full {
+			sources = append(sources, i)
+			continue
+		}
+		_, wayImpacted := impactedWays[edge.WayID]
+		_, targetTouched := touchedNodes[edge.TargetNodeID]
+		if wayImpacted || targetTouched {
+			sources = append(sources, i)
+		}
+	}
+	groups, cycles := expandEdgesGroups(edges, sources, state.cfg.expansionOptions())
+	for i, idx := range sources {
+		state.expandedBySource[edges[idx].ID] = groups[i]
+	}
+	expandedEdgesNum := 0
+	for _, edge := range edges {
+		expandedEdgesNum += len(state.expandedBySource[edge.ID])
+	}
+	fmt.Printf("Done in %v\n", time.Since(st))
+	fmt.Printf("\tRecomputed source edges: %d\n", len(sources))
+	fmt.Printf("\tIgnored cycles: %d\n", cycles)
+	fmt.Printf("\tNumber of expanded edges: %d\n", expandedEdgesNum)
+
+	fmt.Printf("Working with maneuvers (restrictions)...")
+	st = time.Now()
+	state.prepareExpandedEdges()
+	fmt.Printf("Done in %v\n", time.Since(st))
+	fmt.Printf("\tUpdated of expanded edges: %d\n", len(state.expandedEdges))
+	return nil
+}
+
+// prepareExpandedEdges Collects expanded edges in order of source edges, assigns IDs to them and applies restrictions
+func (state *GraphState) prepareExpandedEdges() {
+	expandedEdges := []ExpandedEdge{}
+	for _, edge := range state.edges {
+		expandedEdges = append(expandedEdges, state.expandedBySource[edge.ID]...)
+	}
+	// Assign IDs in deterministic order
+	for i := range expandedEdges {
+		expandedEdges[i].ID = int64(i + 1)
+	}
+	waysSeen := make(map[osm.WayID]struct{}, len(state.data.ways))
+	for wayID := range state.data.ways {
+		waysSeen[wayID] = struct{}{}
+	}
+	state.expandedEdges = applyRestrictions(expandedEdges, state.data.restrictionsMap(), waysSeen)
+}
@@ -0,0 +1,204 @@
+package osm2ch
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"os"
+	"sort"
+
+	"github.com/paulmach/osm"
+	"github.com/pkg/errors"
+)
+
+// graphStateFile is representation of GraphState on disk
+type graphStateFile struct {
+	EntityName    string
+	Tags          []string
+	DeadEndUTurns bool
+	UTurnPenalty  float64
+	Ways          []Way
+	Nodes         []graphStateNode
+	Restrictions  []graphStateRestriction
+	// Restriction relations which have been skipped (with versions) and numbers of unsupported roles of kept ones
+	SkippedRestrictions         []graphStateRelationValue
+	UnsupportedRestrictionRoles []graphStateRelationValue
+	EdgeIDs                     []graphStateEdgeID
+	MaxEdgeID                   EdgeID
+	Edges                       []Edge
+	Expanded                    []ExpandedEdge // Expanded edges before applying restrictions in order of source edges
+}
+
+type graphStateNode struct {
+	ID      osm.NodeID
+	Version int
+	Lon     float64
+	Lat     float64
+}
+
+type graphStateRestriction struct {
+	ID          osm.RelationID
+	Restriction restriction
+}
+
+type graphStateRelationValue struct {
+	ID    osm.RelationID
+	Value int
+}
+
+type graphStateEdgeID struct {
+	Key EdgeKey
+	ID  EdgeID
+}
+
+// SaveToFile Saves state to gzipped file of gob-format
+func (state *GraphState) SaveToFile(fname string) error {
+	stateFile := graphStateFile{
+		EntityName:    state.cfg.EntityName,
+		Tags:          state.cfg.Tags,
+		DeadEndUTurns: state.cfg.DeadEndUTurns,
+		UTurnPenalty:  state.cfg.UTurnPenalty,
+		MaxEdgeID:     state.edgeIDs.maxID,
+		Edges:         state.edges,
+	}
+	for _, wayID := range state.data.sortedWaysIDs() {
+		stateFile.Ways = append(stateFile.Ways, state.data.ways[wayID])
+	}
+	for id, node := range state.data.nodes {
+		stateFile.Nodes = append(stateFile.Nodes, graphStateNode{
+			ID:      id,
+			Version: node.node.Version,
+			Lon:     node.node.Lon,
+			Lat:     node.node.Lat,
+		})
+	}
+	sort.Slice(stateFile.Nodes, func(i, j int) bool {
+		return stateFile.Nodes[i].ID < stateFile.Nodes[j].ID
+	})
+	for id, r := range state.data.restrictions {
+		stateFile.Restrictions = append(stateFile.Restrictions, graphStateRestriction{ID: id, Restriction: r})
+	}
+	sort.Slice(stateFile.Restrictions, func(i, j int) bool {
+		return stateFile.Restrictions[i].ID < stateFile.Restrictions[j].ID
+	})
+	stateFile.SkippedRestrictions = prepareGraphStateRelationValues(state.data.skippedRestrictions)
+	stateFile.UnsupportedRestrictionRoles = prepareGraphStateRelationValues(state.data.unsupportedRestrictionRoles)
+	for key, id := range state.edgeIDs.ids {
+		stateFile.EdgeIDs = append(stateFile.EdgeIDs, graphStateEdgeID{Key: key, ID: id})
+	}
+	sort.Slice(stateFile.EdgeIDs, func(i, j int) bool {
+		return stateFile.EdgeIDs[i].ID < stateFile.EdgeIDs[j].ID
+	})
+	for _, edge := range state.edges {
+		stateFile.Expanded = append(stateFile.Expanded, state.expandedBySource[edge.ID]...)
+	}
+
+	file, err := os.Create(fname)
+	if err != nil {
+		return errors.Wrap(err, "Can't create state file")
+	}
+	defer file.Close()
+	gzipWriter := gzip.NewWriter(file)
+	err = gob.NewEncoder(gzipWriter).Encode(&stateFile)
+	if err != nil {
+		return errors.Wrap(err, "Can't encode state")
+	}
+	err = gzipWriter.Close()
+	if err != nil {
+		return errors.Wrap(err, "Can't flush state file")
+	}
+	return nil
+}
+
+// LoadGraphState Loads state from file prepared by SaveToFile
+/*
+	workers - number of workers for edge expanding technique while applying diffs. If it is less or equal to zero then number of logical CPUs is used
+*/
+func LoadGraphState(fname string, workers int) (*GraphState, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't open state file")
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't prepare gzip reader")
+	}
+	defer gzipReader.Close()
+	stateFile := graphStateFile{}
+	err = gob.NewDecoder(gzipReader).Decode(&stateFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't decode state")
+	}
+
+	data := newOSMData()
+	for _, way := range stateFile.Ways {
+		data.ways[way.ID] = way
+	}
+	for _, node := range stateFile.Nodes {
+		data.nodes[node.ID] = Node{
+			ID: node.ID,
+			node: osm.Node{
+				ID:      node.ID,
+				Version: node.Version,
+				Lon:     node.Lon,
+				Lat:     node.Lat,
+			},
+		}
+	}
+	for _, r := range stateFile.Restrictions {
+		data.restrictions[r.ID] = r.Restriction
+	}
+	for _, record := range stateFile.SkippedRestrictions {
+		data.skippedRestrictions[record.ID] = record.Value
+	}
+	for _, record := range stateFile.UnsupportedRestrictionRoles {
+		data.unsupportedRestrictionRoles[record.ID] = record.Value
+	}
+	edgeIDs := NewEdgeIDMapping()
+	edgeIDs.maxID = stateFile.MaxEdgeID
+	for _, record := range stateFile.EdgeIDs {
+		edgeIDs.ids[record.Key] = record.ID
+	}
+
+	state := &GraphState{
+		cfg: OsmConfiguration{
+			EntityName:    stateFile.EntityName,
+			Tags:          stateFile.Tags,
+			Workers:       workers,
+			DeadEndUTurns: stateFile.DeadEndUTurns,
+			UTurnPenalty:  stateFile.UTurnPenalty,
+		},
+		data:             data,
+		edgeIDs:          edgeIDs,
+		edges:            stateFile.Edges,
+		edgesByWay:       make(map[osm.WayID][]Edge),
+		expandedBySource: make(map[EdgeID][]ExpandedEdge),
+	}
+	for start, end := 0, 0; start < len(state.edges); start = end {
+		for end = start; end < len(state.edges) && state.edges[end].WayID == state.edges[start].WayID; end++ {
+		}
+		state.edgesByWay[state.edges[start].WayID] = state.edges[start:end]
+	}
+	for _, expanded := range stateFile.Expanded {
+		state.expandedBySource[expanded.Source] = append(state.expandedBySource[expanded.Source], expanded)
+	}
+	// Use counts of nodes are needed to detect changes of intersections
+	err = data.countNodeUses()
+	if err != nil {
+		return nil, err
+	}
+	state.prepareExpandedEdges()
+	return state, nil
+}
+
+// prepareGraphStateRelationValues Returns values of relations sorted by ID (so state file is the same for the same data)
+func prepareGraphStateRelationValues(values map[osm.RelationID]int) []graphStateRelationValue {
+	records := make([]graphStateRelationValue, 0, len(values))
+	for id, value := range values {
+		records = append(records, graphStateRelationValue{ID: id, Value: value})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+	return records
+}
@@ -0,0 +1,269 @@
+package osm2ch
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/osm"
+)
+
+// prepareGridOSM returns OSM objects for grid of (size x size) intersections. Rows are two-way residential ways with intermediate nodes,
+// columns are residential ways without intermediate nodes
+func prepareGridOSM(size int) (map[osm.NodeID]*osm.Node, map[osm.WayID]*osm.Way, map[osm.RelationID]*osm.Relation) {
+	nodes := make(map[osm.NodeID]*osm.Node)
+	ways := make(map[osm.WayID]*osm.Way)
+	relations := make(map[osm.RelationID]*osm.Relation)
+	gridNodeID := func(i, j int) osm.NodeID {
+		return osm.NodeID(1000 + i*size + j)
+	}
+	middleNodeID := func(i, j int) osm.NodeID {
+		return osm.NodeID(2000 + i*size + j)
+	}
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			nodes[gridNodeID(i, j)] = &osm.Node{ID: gridNodeID(i, j), Version: 1, Lon: 37.6 + float64(j)*0.001, Lat: 55.7 + float64(i)*0.001}
+			if j < size-1 {
+				nodes[middleNodeID(i, j)] = &osm.Node{ID: middleNodeID(i, j), Version: 1, Lon: 37.6 + float64(j)*0.001 + 0.0005, Lat: 55.7 + float64(i)*0.001 + 0.0001}
+			}
+		}
+	}
+	residential := osm.Tags{{Key: "highway", Value: "residential"}}
+	for i := 0; i < size; i++ {
+		row := &osm.Way{ID: osm.WayID(100 + i), Version: 1, Tags: residential}
+		column := &osm.Way{ID: osm.WayID(200 + i), Version: 1, Tags: residential}
+		for j := 0; j < size; j++ {
+			row.Nodes = append(row.Nodes, osm.WayNode{ID: gridNodeID(i, j)})
+			if j < size-1 {
+				row.Nodes = append(row.Nodes, osm.WayNode{ID: middleNodeID(i, j)})
+			}
+			column.Nodes = append(column.Nodes, osm.WayNode{ID: gridNodeID(j, i)})
+		}
+		ways[row.ID] = row
+		ways[column.ID] = column
+	}
+	relations[1] = &osm.Relation{
+		ID:      1,
+		Version: 1,
+		Tags:    osm.Tags{{Key: "type", Value: "restriction"}, {Key: "restriction", Value: "no_right_turn"}},
+		Members: osm.Members{
+			{Type: osm.TypeWay, Ref: 102, Role: "from"},
+			{Type: osm.TypeNode, Ref: int64(gridNodeID(2, 2)), Role: "via"},
+			{Type: osm.TypeWay, Ref: 202, Role: "to"},
+		},
+	}
+	relations[2] = &osm.Relation{
+		ID:      2,
+		Version: 1,
+		Tags:    osm.Tags{{Key: "type", Value: "restriction"}, {Key: "restriction", Value: "only_straight_on"}},
+		Members: osm.Members{
+			{Type: osm.TypeWay, Ref: 203, Role: "from"},
+			{Type: osm.TypeNode, Ref: int64(gridNodeID(3, 3)), Role: "via"},
+			{Type: osm.TypeWay, Ref: 203, Role: "to"},
+		},
+	}
+	return nodes, ways, relations
+}
+
+func prepareTestGraphState(t *testing.T, nodes map[osm.NodeID]*osm.Node, ways map[osm.WayID]*osm.Way, relations map[osm.RelationID]*osm.Relation, cfg *OsmConfiguration) *GraphState {
+	data := newOSMData()
+	for _, way := range ways {
+		data.addWay(way, cfg)
+	}
+	// Nodes of all ways are kept (see readOSMFiles)
+	for _, node := range nodes {
+		used := false
+		for _, way := range ways {
+			for _, wayNode := range way.Nodes {
+				if wayNode.ID == node.ID {
+					used = true
+				}
+			}
+		}
+		if used {
+			data.addNode(node)
+		}
+	}
+	for _, relation := range relations {
+		data.addRelation(relation)
+	}
+	state, err := newGraphState(data, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func copyEdgeIDMapping(mapping *EdgeIDMapping) *EdgeIDMapping {
+	cp := NewEdgeIDMapping()
+	cp.maxID = mapping.maxID
+	for key, id := range mapping.ids {
+		cp.ids[key] = id
+	}
+	return cp
+}
+
+func TestApplyChange(t *testing.T) {
+	cfg := OsmConfiguration{
+		EntityName: "highway",
+		Tags:       []string{"residential"},
+		Workers:    3,
+	}
+	nodes, ways, relations := prepareGridOSM(6)
+	// Footways do not satisfy configuration, but their nodes are kept in state
+	for i := 0; i < 4; i++ {
+		nodes[osm.NodeID(5000+i)] = &osm.Node{ID: osm.NodeID(5000 + i), Version: 1, Lon: 37.599 - float64(i)*0.001, Lat: 55.699}
+	}
+	footwayTags := osm.Tags{{Key: "highway", Value: "footway"}}
+	ways[400] = &osm.Way{ID: 400, Version: 1, Tags: footwayTags, Nodes: osm.WayNodes{{ID: 1000}, {ID: 5000}, {ID: 5001}}}
+	ways[401] = &osm.Way{ID: 401, Version: 1, Tags: footwayTags, Nodes: osm.WayNodes{{ID: 5002}, {ID: 5003}}}
+	state := prepareTestGraphState(t, nodes, ways, relations, &cfg)
+	mappingBefore := copyEdgeIDMapping(state.EdgeIDs())
+
+	change := osm.Change{}
+	// Move intermediate node
+	movedNode := *nodes[2000+1*6+2]
+	movedNode.Version++
+	movedNode.Lat += 0.0002
+	change.AppendModify(&movedNode)
+	// Delete column: its nodes stop being intersections for rows
+	change.AppendDelete(&osm.Way{ID: 203, Version: 2})
+	// New way connected to intermediate node: it becomes intersection
+	newNode := &osm.Node{ID: 3000, Version: 1, Lon: 37.6025, Lat: 55.698}
+	change.AppendCreate(newNode)
+	change.AppendCreate(&osm.Way{ID: 300, Version: 1, Tags: osm.Tags{{Key: "highway", Value: "residential"}}, Nodes: osm.WayNodes{{ID: 3000}, {ID: 2000 + 0*6 + 2}}})
+	// Way becomes one way
+	onewayRow := *ways[104]
+	onewayRow.Version++
+	onewayRow.Tags = osm.Tags{{Key: "highway", Value: "residential"}, {Key: "oneway", Value: "yes"}}
+	change.AppendModify(&onewayRow)
+	// Way does not satisfy configuration anymore
+	footway := *ways[205]
+	footway.Version++
+	footway.Tags = osm.Tags{{Key: "highway", Value: "footway"}}
+	change.AppendModify(&footway)
+	// Restrictions
+	change.AppendDelete(&osm.Relation{ID: 1, Version: 2})
+	change.AppendCreate(&osm.Relation{
+		ID:      3,
+		Version: 1,
+		Tags:    osm.Tags{{Key: "type", Value: "restriction"}, {Key: "restriction", Value: "no_left_turn"}},
+		Members: osm.Members{
+			{Type: osm.TypeWay, Ref: 101, Role: "from"},
+			{Type: osm.TypeNode, Ref: 1000 + 1*6 + 1, Role: "via"},
+			{Type: osm.TypeWay, Ref: 201, Role: "to"},
+		},
+	})
+
+	// Round trip through OsmChange XML
+	changeXML, err := xml.Marshal(&change)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedChange, err := ReadChange(bytes.NewReader(changeXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = state.ApplyChange(parsedChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Full rebuild on updated data
+	nodes[movedNode.ID] = &movedNode
+	delete(ways, 203)
+	nodes[newNode.ID] = newNode
+	ways[300] = change.Create.Ways[0]
+	ways[onewayRow.ID] = &onewayRow
+	ways[footway.ID] = &footway
+	delete(relations, 1)
+	relations[3] = change.Create.Relations[0]
+	cfg.EdgeIDs = mappingBefore
+	expected := prepareTestGraphState(t, nodes, ways, relations, &cfg)
+
+	if !reflect.DeepEqual(expected.Edges(), state.Edges()) {
+		t.Errorf("Edges after applying diff should be equal to edges after full rebuild")
+	}
+	if !reflect.DeepEqual(expected.ExpandedEdges(), state.ExpandedEdges()) {
+		t.Errorf("Expanded edges after applying diff should be equal to expanded edges after full rebuild")
+	}
+	if !reflect.DeepEqual(expected.EdgeIDs(), state.EdgeIDs()) {
+		t.Errorf("Mapping of IDs after applying diff should be equal to mapping after full rebuild")
+	}
+	if got := PrepareTagsJSON(state.WayTags(onewayRow.ID)); got != `{"highway":"residential","oneway":"yes"}` {
+		t.Errorf("Tags of way should be updated by diff, but got %s", got)
+	}
+	if state.WayTags(203) != nil {
+		t.Errorf("Deleted way should not have tags")
+	}
+
+	// Small local change: only few expanded edges should be recomputed
+	mappingBefore = copyEdgeIDMapping(state.EdgeIDs())
+	localChange := osm.Change{}
+	localMovedNode := *nodes[2000+5*6+4]
+	localMovedNode.Version++
+	localMovedNode.Lon -= 0.0001
+	localChange.AppendModify(&localMovedNode)
+	err = state.ApplyChange(&localChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes[localMovedNode.ID] = &localMovedNode
+	cfg.EdgeIDs = mappingBefore
+	expected = prepareTestGraphState(t, nodes, ways, relations, &cfg)
+	if !reflect.DeepEqual(expected.ExpandedEdges(), state.ExpandedEdges()) {
+		t.Errorf("Expanded edges after applying local diff should be equal to expanded edges after full rebuild")
+	}
+
+	// State should survive saving and loading
+	dir, err := ioutil.TempDir("", "osm2ch_state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "state.gob.gz")
+	err = state.SaveToFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGraphState(fname, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state.ExpandedEdges(), loaded.ExpandedEdges()) {
+		t.Errorf("Expanded edges of loaded state should be equal to expanded edges of saved one")
+	}
+	if loadedCfg := loaded.Configuration(); !reflect.DeepEqual(loadedCfg.Tags, cfg.Tags) || loadedCfg.DeadEndUTurns != cfg.DeadEndUTurns || loadedCfg.UTurnPenalty != cfg.UTurnPenalty {
+		t.Errorf("Configuration of loaded state should be equal to configuration of saved one, but got %+v", loadedCfg)
+	}
+
+	// Ways out of filter: diff provides no nodes, since none of them have been changed
+	mappingBefore = copyEdgeIDMapping(loaded.EdgeIDs())
+	outOfFilterChange := osm.Change{}
+	// Way is retagged into filter
+	retagged := *ways[400]
+	retagged.Version++
+	retagged.Tags = osm.Tags{{Key: "highway", Value: "residential"}}
+	outOfFilterChange.AppendModify(&retagged)
+	// New way is attached to node of footway
+	attached := &osm.Way{ID: 301, Version: 1, Tags: osm.Tags{{Key: "highway", Value: "residential"}}, Nodes: osm.WayNodes{{ID: 1000 + 0*6 + 1}, {ID: 5002}}}
+	outOfFilterChange.AppendCreate(attached)
+	err = loaded.ApplyChange(&outOfFilterChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ways[retagged.ID] = &retagged
+	ways[attached.ID] = attached
+	cfg.EdgeIDs = mappingBefore
+	expected = prepareTestGraphState(t, nodes, ways, relations, &cfg)
+	if !reflect.DeepEqual(expected.Edges(), loaded.Edges()) || len(loaded.edgesByWay[400]) == 0 || len(loaded.edgesByWay[301]) == 0 {
+		t.Errorf("Edges after applying diff with ways out of filter should be equal to edges after full rebuild")
+	}
+	if !reflect.DeepEqual(expected.ExpandedEdges(), loaded.ExpandedEdges()) {
+		t.Errorf("Expanded edges after applying diff with ways out of filter should be equal to expanded edges after full rebuild")
+	}
+}
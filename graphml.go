@@ -0,0 +1,92 @@
+package osm2ch
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// WriteGraphMLFile Writes expanded graph into GraphML file (see WriteGraphML)
+func WriteGraphMLFile(fname string, expandedEdges []ExpandedEdge, vertices []Vertex) error {
+	file, err := os.Create(fname)
+	if err != nil {
+		return errors.Wrap(err, "Can't create GraphML file")
+	}
+	defer file.Close()
+	err = WriteGraphML(file, expandedEdges, vertices)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// WriteGraphML Writes expanded graph as directed graph in GraphML format
+/*
+	IDs of nodes are IDs of vertices. Attributes of nodes: order_pos, importance, lon, lat.
+	Attributes of edges: edge_id, weight, was_one_way, osm_way_from, osm_way_to, geom (WKT, omitted for edges having less than two points).
+	Shortcuts are not written. See ref. http://graphml.graphdrawing.org/specification.html
+*/
+func WriteGraphML(w io.Writer, expandedEdges []ExpandedEdge, vertices []Vertex) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	bw.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd">` + "\n")
+	keys := []struct {
+		domain, name, attrType string
+	}{
+		{"node", "order_pos", "long"},
+		{"node", "importance", "int"},
+		{"node", "lon", "double"},
+		{"node", "lat", "double"},
+		{"edge", "edge_id", "long"},
+		{"edge", "weight", "double"},
+		{"edge", "was_one_way", "boolean"},
+		{"edge", "osm_way_from", "long"},
+		{"edge", "osm_way_to", "long"},
+		{"edge", "geom", "string"},
+	}
+	for _, key := range keys {
+		fmt.Fprintf(bw, "  <key id=\"%s\" for=\"%s\" attr.name=\"%s\" attr.type=\"%s\"/>\n", key.name, key.domain, key.name, key.attrType)
+	}
+	bw.WriteString("  <graph id=\"G\" edgedefault=\"directed\">\n")
+	formatFloat := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	for _, vertex := range vertices {
+		fmt.Fprintf(bw, "    <node id=\"%d\">", vertex.ID)
+		writeGraphMLData(bw, "order_pos", strconv.FormatInt(vertex.OrderPos, 10))
+		writeGraphMLData(bw, "importance", strconv.Itoa(vertex.Importance))
+		writeGraphMLData(bw, "lon", formatFloat(vertex.Geom.Lon))
+		writeGraphMLData(bw, "lat", formatFloat(vertex.Geom.Lat))
+		bw.WriteString("</node>\n")
+	}
+	for _, edge := range expandedEdges {
+		fmt.Fprintf(bw, "    <edge id=\"e%d\" source=\"%d\" target=\"%d\">", edge.ID, edge.Source, edge.Target)
+		writeGraphMLData(bw, "edge_id", strconv.FormatInt(edge.ID, 10))
+		writeGraphMLData(bw, "weight", formatFloat(edge.CostMeters))
+		writeGraphMLData(bw, "was_one_way", strconv.FormatBool(edge.WasOneway))
+		writeGraphMLData(bw, "osm_way_from", strconv.FormatInt(int64(edge.SourceOSMWayID), 10))
+		writeGraphMLData(bw, "osm_way_to", strconv.FormatInt(int64(edge.TargetOSMWayID), 10))
+		if len(edge.Geom) >= 2 {
+			writeGraphMLData(bw, "geom", PrepareWKTLinestring(edge.Geom))
+		}
+		bw.WriteString("</edge>\n")
+	}
+	bw.WriteString("  </graph>\n</graphml>\n")
+	err := bw.Flush()
+	if err != nil {
+		return errors.Wrap(err, "Can't write GraphML")
+	}
+	return nil
+}
+
+// writeGraphMLData Writes value of attribute (escaped)
+func writeGraphMLData(bw *bufio.Writer, key, value string) {
+	bw.WriteString(`<data key="` + key + `">`)
+	xml.EscapeText(bw, []byte(value))
+	bw.WriteString("</data>")
+}
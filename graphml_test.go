@@ -0,0 +1,67 @@
+package osm2ch
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+func TestWriteGraphML(t *testing.T) {
+	expandedEdges := []ExpandedEdge{
+		{ID: 1, Source: 1, Target: 2, CostMeters: 0.25, WasOneway: true, SourceOSMWayID: 10, TargetOSMWayID: 11, Geom: []GeoPoint{{Lon: 1, Lat: 2}, {Lon: 3, Lat: 4}}},
+		{ID: 2, Source: 2, Target: 1, CostMeters: 0.5, Geom: []GeoPoint{{Lon: 3, Lat: 4}}},
+	}
+	vertices := []Vertex{{ID: 1, OrderPos: 5, Importance: 3, Geom: GeoPoint{Lon: 1, Lat: 2}}, {ID: 2, Geom: GeoPoint{Lon: 3, Lat: 4}}}
+	buf := bytes.Buffer{}
+	err := WriteGraphML(&buf, expandedEdges, vertices)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type data struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+	var doc struct {
+		Keys []struct {
+			ID string `xml:"id,attr"`
+		} `xml:"key"`
+		Graph struct {
+			EdgeDefault string `xml:"edgedefault,attr"`
+			Nodes       []struct {
+				ID   string `xml:"id,attr"`
+				Data []data `xml:"data"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+				Data   []data `xml:"data"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	err = xml.Unmarshal(buf.Bytes(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Keys) != 10 || doc.Graph.EdgeDefault != "directed" || len(doc.Graph.Nodes) != 2 || len(doc.Graph.Edges) != 2 {
+		t.Fatalf("Bad structure of GraphML: %+v", doc)
+	}
+	attrs := func(values []data) map[string]string {
+		ans := make(map[string]string)
+		for _, d := range values {
+			ans[d.Key] = d.Value
+		}
+		return ans
+	}
+	node := attrs(doc.Graph.Nodes[0].Data)
+	if doc.Graph.Nodes[0].ID != "1" || node["order_pos"] != "5" || node["importance"] != "3" || node["lon"] != "1" || node["lat"] != "2" {
+		t.Errorf("Bad node: %v", node)
+	}
+	edge := attrs(doc.Graph.Edges[0].Data)
+	if doc.Graph.Edges[0].Source != "1" || doc.Graph.Edges[0].Target != "2" || edge["weight"] != "0.25" || edge["was_one_way"] != "true" || edge["osm_way_to"] != "11" || edge["geom"] != "LINESTRING(1 2,3 4)" {
+		t.Errorf("Bad edge: %v", edge)
+	}
+	if _, ok := attrs(doc.Graph.Edges[1].Data)["geom"]; ok {
+		t.Errorf("Edge without proper geometry should not have 'geom' attribute")
+	}
+}
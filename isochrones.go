@@ -0,0 +1,390 @@
+package osm2ch
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+const (
+	// Meters per degree of latitude (spherical Earth)
+	metersPerDegree = earthRadius * 1000.0 * math.Pi / 180.0
+	// IsochroneMinCellSize Minimum size of cell of isochrones grid (meters)
+	IsochroneMinCellSize = 10.0
+	// IsochroneMaxCells Maximum number of cells of isochrones grid (estimated by bounding box of reachable geometry)
+	IsochroneMaxCells = 10000000
+	// IsochroneMaxLimits Maximum number of limits of isochrones for single source (every limit gives polygons over the whole grid)
+	IsochroneMaxLimits = 16
+)
+
+// Isochrone Area reachable from source within limit of cost
+type Isochrone struct {
+	// Limit of cost (in units of weights of graph)
+	Limit float64
+	// Polygons: every polygon is list of closed rings, the first ring is exterior one (counterclockwise), the others are holes (clockwise)
+	Polygons [][][]GeoPoint
+}
+
+// Isochrones Returns areas reachable from source vertex within every given limit of cost (sorted by limit)
+/*
+	Costs are found by bounded Dijkstra's algorithm (see ShortestPathTree). Geometry of expanded edges is reachable completely if cost
+	of its target vertex is within limit, otherwise it is reachable partially (proportionally to the rest of limit).
+	Reachable geometry is rasterized into square cells with side cellSize (meters), every cell crossed by geometry is buffered by its
+	neighbors, and polygons are outlines of filled cells.
+	Size of cell should be at least IsochroneMinCellSize. Grid covering bounding box of reachable geometry should not have more than
+	IsochroneMaxCells cells, otherwise error is returned before rasterization. Number of limits should not exceed IsochroneMaxLimits
+*/
+func (router *Router) Isochrones(source int64, limits []float64, cellSize float64) ([]Isochrone, error) {
+	if !(cellSize >= IsochroneMinCellSize) {
+		return nil, fmt.Errorf("Size of cell should be at least %g meters, but got %g", IsochroneMinCellSize, cellSize)
+	}
+	if len(limits) > IsochroneMaxLimits {
+		return nil, fmt.Errorf("Number of limits should not exceed %d, but got %d", IsochroneMaxLimits, len(limits))
+	}
+	sourceIdx, ok := router.verticesIdx[source]
+	if !ok {
+		return nil, fmt.Errorf("Vertex with ID = %d is not found in graph", source)
+	}
+	if len(limits) == 0 {
+		return []Isochrone{}, nil
+	}
+	limits = append([]float64{}, limits...)
+	sort.Float64s(limits)
+	costs := router.ShortestPathTree(source, limits[len(limits)-1])
+
+	origin := router.vertices[sourceIdx].Geom
+	grid := isochroneGrid{
+		origin:    origin,
+		cellLat:   cellSize / metersPerDegree,
+		cellLon:   cellSize / (metersPerDegree * math.Max(math.Cos(degreesToRadians(origin.Lat)), 1e-6)),
+		filled:    make(map[[2]int]struct{}),
+		rastered:  make(map[int]float64),
+		reachable: costs,
+	}
+	if cellsNum := grid.estimateCells(router); cellsNum > IsochroneMaxCells {
+		return nil, fmt.Errorf("Grid of isochrones would have about %.0f cells, but maximum is %d: increase size of cell or decrease limits", cellsNum, IsochroneMaxCells)
+	}
+	isochrones := make([]Isochrone, 0, len(limits))
+	for _, limit := range limits {
+		// Cells of smaller limits are filled already
+		grid.fill(router, limit)
+		isochrones = append(isochrones, Isochrone{Limit: limit, Polygons: grid.polygons()})
+	}
+	return isochrones, nil
+}
+
+// GeoJSONFeature Returns isochrone as MultiPolygon feature with property 'limit'
+func (isochrone Isochrone) GeoJSONFeature() *geojson.Feature {
+	polygons := make([][][][]float64, len(isochrone.Polygons))
+	for i, polygon := range isochrone.Polygons {
+		polygons[i] = make([][][]float64, len(polygon))
+		for j, ring := range polygon {
+			polygons[i][j] = make([][]float64, len(ring))
+			for k, pt := range ring {
+				polygons[i][j][k] = []float64{pt.Lon, pt.Lat}
+			}
+		}
+	}
+	feature := geojson.NewMultiPolygonFeature(polygons...)
+	feature.SetProperty("limit", isochrone.Limit)
+	return feature
+}
+
+// PrepareIsochronesGeoJSON Returns isochrones as FeatureCollection of MultiPolygon features (see Isochrone.GeoJSONFeature)
+func PrepareIsochronesGeoJSON(isochrones []Isochrone) *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	for _, isochrone := range isochrones {
+		fc.AddFeature(isochrone.GeoJSONFeature())
+	}
+	return fc
+}
+
+// isochroneGrid Grid of cells which are filled by reachable geometry
+type isochroneGrid struct {
+	origin           GeoPoint
+	cellLon, cellLat float64
+	filled           map[[2]int]struct{}
+	// Fractions of expanded edges which have been rasterized already
+	rastered  map[int]float64
+	reachable map[int64]float64
+}
+
+// cell Returns cell containing point
+func (grid *isochroneGrid) cell(pt GeoPoint) [2]int {
+	return [2]int{int(math.Floor((pt.Lon - grid.origin.Lon) / grid.cellLon)), int(math.Floor((pt.Lat - grid.origin.Lat) / grid.cellLat))}
+}
+
+// fillPoint Fills cell containing point and its neighbors (so reachable roads are buffered by one cell and do not fall apart into
+// cells touching by corners only)
+func (grid *isochroneGrid) fillPoint(pt GeoPoint) {
+	c := grid.cell(pt)
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			grid.filled[[2]int{c[0] + dx, c[1] + dy}] = struct{}{}
+		}
+	}
+}
+
+// corner Returns point of lower-left corner of cell
+func (grid *isochroneGrid) corner(c [2]int) GeoPoint {
+	return GeoPoint{Lon: grid.origin.Lon + float64(c[0])*grid.cellLon, Lat: grid.origin.Lat + float64(c[1])*grid.cellLat}
+}
+
+// estimateCells Returns number of cells of grid covering bounding box of reachable vertices and geometry of their outgoing expanded edges
+// (including buffering by neighbors)
+func (grid *isochroneGrid) estimateCells(router *Router) float64 {
+	bbox := emptyBoundingBox()
+	for vertexID := range grid.reachable {
+		vertexIdx := router.verticesIdx[vertexID]
+		if geom := router.vertices[vertexIdx].Geom; geom != (GeoPoint{}) {
+			bbox.extendPoint(geom)
+		}
+		for _, edgeIdx := range router.outEdges[vertexIdx] {
+			for _, pt := range router.edges[edgeIdx].Geom {
+				// Empty (zero) points are skipped by rasterization too
+				if pt != (GeoPoint{}) {
+					bbox.extendPoint(pt)
+				}
+			}
+		}
+	}
+	if bbox.isEmpty() {
+		return 0
+	}
+	return (math.Floor((bbox.maxLon-bbox.minLon)/grid.cellLon) + 3) * (math.Floor((bbox.maxLat-bbox.minLat)/grid.cellLat) + 3)
+}
+
+// fill Fills cells by geometry reachable within limit
+func (grid *isochroneGrid) fill(router *Router, limit float64) {
+	for vertexID, cost := range grid.reachable {
+		if cost > limit {
+			continue
+		}
+		vertexIdx := router.verticesIdx[vertexID]
+		if geom := router.vertices[vertexIdx].Geom; geom != (GeoPoint{}) {
+			grid.fillPoint(geom)
+		}
+		for _, edgeIdx := range router.outEdges[vertexIdx] {
+			edge := router.edges[edgeIdx]
+			fraction := 1.0
+			if edge.CostMeters > 0 && cost+edge.CostMeters > limit {
+				fraction = (limit - cost) / edge.CostMeters
+			}
+			if fraction <= grid.rastered[edgeIdx] {
+				continue
+			}
+			grid.rastered[edgeIdx] = fraction
+			grid.fillLine(cutLine(edge.Geom, fraction))
+		}
+	}
+}
+
+// fillLine Fills cells crossed by line (segments are sampled with step of half of cell)
+/*
+	Empty (zero) points are skipped, since they come from nodes missing in OSM data
+*/
+func (grid *isochroneGrid) fillLine(line []GeoPoint) {
+	for i, pt := range line {
+		if pt == (GeoPoint{}) {
+			continue
+		}
+		grid.fillPoint(pt)
+		if i == 0 || line[i-1] == (GeoPoint{}) {
+			continue
+		}
+		prev := line[i-1]
+		cellsNum := math.Hypot((pt.Lon-prev.Lon)/grid.cellLon, (pt.Lat-prev.Lat)/grid.cellLat)
+		steps := int(math.Ceil(2 * cellsNum))
+		for step := 1; step < steps; step++ {
+			t := float64(step) / float64(steps)
+			grid.fillPoint(GeoPoint{Lon: prev.Lon + t*(pt.Lon-prev.Lon), Lat: prev.Lat + t*(pt.Lat-prev.Lat)})
+		}
+	}
+}
+
+// polygons Returns outlines of filled cells: exterior rings with holes
+func (grid *isochroneGrid) polygons() [][][]GeoPoint {
+	// Directions: right, up, left, down. Boundary edges go counterclockwise around filled cells (filled cell is on the left)
+	dirs := [4][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	isFilled := func(x, y int) bool {
+		_, ok := grid.filled[[2]int{x, y}]
+		return ok
+	}
+	// Outgoing boundary edges by start corner
+	outgoing := make(map[[2]int][4]bool)
+	addEdge := func(x, y, dir int) {
+		edges := outgoing[[2]int{x, y}]
+		edges[dir] = true
+		outgoing[[2]int{x, y}] = edges
+	}
+	for c := range grid.filled {
+		x, y := c[0], c[1]
+		if !isFilled(x, y-1) {
+			addEdge(x, y, 0)
+		}
+		if !isFilled(x+1, y) {
+			addEdge(x+1, y, 1)
+		}
+		if !isFilled(x, y+1) {
+			addEdge(x+1, y+1, 2)
+		}
+		if !isFilled(x-1, y) {
+			addEdge(x, y+1, 3)
+		}
+	}
+	// Start corners are sorted to get stable output
+	starts := make([][2]int, 0, len(outgoing))
+	for corner := range outgoing {
+		starts = append(starts, corner)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		if starts[i][1] != starts[j][1] {
+			return starts[i][1] < starts[j][1]
+		}
+		return starts[i][0] < starts[j][0]
+	})
+	rings := [][][2]int{}
+	for _, start := range starts {
+		for startDir := 0; startDir < 4; startDir++ {
+			if !outgoing[start][startDir] {
+				continue
+			}
+			// Corners are added where direction changes only
+			ring := [][2]int{start}
+			corner, dir := start, startDir
+			for {
+				edges := outgoing[corner]
+				edges[dir] = false
+				outgoing[corner] = edges
+				corner = [2]int{corner[0] + dirs[dir][0], corner[1] + dirs[dir][1]}
+				if corner == start && !outgoing[corner][(dir+1)%4] {
+					break
+				}
+				// Left turn is preferred, so cells touching by corner only give separate rings
+				next := -1
+				for _, turn := range []int{1, 0, 3} {
+					if outgoing[corner][(dir+turn)%4] {
+						next = (dir + turn) % 4
+						break
+					}
+				}
+				if next < 0 {
+					break
+				}
+				if next != dir {
+					ring = append(ring, corner)
+				}
+				dir = next
+			}
+			rings = append(rings, ring)
+		}
+	}
+
+	// Exterior rings are counterclockwise, holes are clockwise
+	type exterior struct {
+		ring [][2]int
+		area float64
+	}
+	exteriors := []exterior{}
+	holes := [][][2]int{}
+	for _, ring := range rings {
+		area := ringArea(ring)
+		if area > 0 {
+			exteriors = append(exteriors, exterior{ring: ring, area: area})
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+	sort.Slice(exteriors, func(i, j int) bool {
+		return exteriors[i].area < exteriors[j].area
+	})
+	polygons := make([][][][2]int, len(exteriors))
+	for i := range exteriors {
+		polygons[i] = [][][2]int{exteriors[i].ring}
+	}
+	for _, hole := range holes {
+		// Center of cell on the left of the first edge of hole is filled, so it is inside of the smallest exterior ring containing hole
+		a, b := hole[0], hole[1]
+		dx, dy := sign(b[0]-a[0]), sign(b[1]-a[1])
+		px := float64(a[0]) + 0.5*float64(dx) - 0.5*float64(dy)
+		py := float64(a[1]) + 0.5*float64(dy) + 0.5*float64(dx)
+		for i := range exteriors {
+			if ringContains(exteriors[i].ring, px, py) {
+				polygons[i] = append(polygons[i], hole)
+				break
+			}
+		}
+	}
+
+	ans := make([][][]GeoPoint, len(polygons))
+	for i, polygon := range polygons {
+		ans[i] = make([][]GeoPoint, len(polygon))
+		for j, ring := range polygon {
+			pts := make([]GeoPoint, 0, len(ring)+1)
+			for _, corner := range ring {
+				pts = append(pts, grid.corner(corner))
+			}
+			ans[i][j] = append(pts, pts[0])
+		}
+	}
+	return ans
+}
+
+// cutLine Returns part of line from its start to given fraction of its length
+func cutLine(line []GeoPoint, fraction float64) []GeoPoint {
+	if fraction >= 1 || len(line) < 2 {
+		return line
+	}
+	rest := getSphericalLength(line) * math.Max(fraction, 0)
+	ans := []GeoPoint{line[0]}
+	for i := 1; i < len(line); i++ {
+		length := greatCircleDistance(line[i-1], line[i])
+		if length >= rest {
+			t := 0.0
+			if length > 0 {
+				t = rest / length
+			}
+			return append(ans, GeoPoint{Lon: line[i-1].Lon + t*(line[i].Lon-line[i-1].Lon), Lat: line[i-1].Lat + t*(line[i].Lat-line[i-1].Lat)})
+		}
+		rest -= length
+		ans = append(ans, line[i])
+	}
+	return ans
+}
+
+// ringArea Returns signed area of ring (positive for counterclockwise)
+func ringArea(ring [][2]int) float64 {
+	area := 0
+	for i := range ring {
+		j := (i + 1) % len(ring)
+		area += ring[i][0]*ring[j][1] - ring[j][0]*ring[i][1]
+	}
+	return float64(area) / 2
+}
+
+// ringContains Checks if point is inside of ring (ray casting)
+func ringContains(ring [][2]int, x, y float64) bool {
+	inside := false
+	for i := range ring {
+		j := (i + len(ring) - 1) % len(ring)
+		xi, yi := float64(ring[i][0]), float64(ring[i][1])
+		xj, yj := float64(ring[j][0]), float64(ring[j][1])
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,128 @@
+package osm2ch
+
+import (
+	"math"
+	"testing"
+)
+
+func TestIsochroneGridPolygons(t *testing.T) {
+	grid := isochroneGrid{cellLon: 1, cellLat: 1, filled: make(map[[2]int]struct{})}
+	// Ring of 3x3 cells with hole in the middle and single cell touching it by corner
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 3; y++ {
+			if x != 1 || y != 1 {
+				grid.filled[[2]int{x, y}] = struct{}{}
+			}
+		}
+	}
+	grid.filled[[2]int{3, 3}] = struct{}{}
+	polygons := grid.polygons()
+	if len(polygons) != 2 {
+		t.Fatalf("There should be 2 polygons, but got %d: %v", len(polygons), polygons)
+	}
+	// Polygons are sorted by area
+	if len(polygons[0]) != 1 || len(polygons[0][0]) != 5 {
+		t.Errorf("The first polygon should be single cell, but got %v", polygons[0])
+	}
+	if len(polygons[1]) != 2 || len(polygons[1][0]) != 5 || len(polygons[1][1]) != 5 {
+		t.Fatalf("The second polygon should be square with square hole, but got %v", polygons[1])
+	}
+	if polygons[1][1][0] != (GeoPoint{Lon: 1, Lat: 1}) && polygons[1][1][0] != (GeoPoint{Lon: 2, Lat: 1}) {
+		t.Errorf("Bad hole: %v", polygons[1][1])
+	}
+}
+
+func TestIsochrones(t *testing.T) {
+	router := prepareTestRouter(t, false)
+	vertices := router.Vertices()
+	source := vertices[0]
+	costs := router.ShortestPathTree(source.ID, math.Inf(1))
+	for _, target := range vertices {
+		expected, _ := router.graph.VanillaShortestPath(source.ID, target.ID)
+		if cost, ok := costs[target.ID]; !ok || math.Abs(cost-expected) > 1e-9 {
+			t.Errorf("Cost of path from %d to %d should be %f, but got %f", source.ID, target.ID, expected, cost)
+		}
+	}
+
+	isochrones, err := router.Isochrones(source.ID, []float64{0.5, 0.1}, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(isochrones) != 2 || isochrones[0].Limit != 0.1 || isochrones[1].Limit != 0.5 {
+		t.Fatalf("Isochrones should be sorted by limit, but got %v", isochrones)
+	}
+	contains := func(isochrone Isochrone, pt GeoPoint) bool {
+		for _, polygon := range isochrone.Polygons {
+			ring := make([][2]int, 0, len(polygon[0]))
+			// Coordinates are scaled to keep integer corners precise enough
+			for _, corner := range polygon[0] {
+				ring = append(ring, [2]int{int(math.Round(corner.Lon * 1e7)), int(math.Round(corner.Lat * 1e7))})
+			}
+			if ringContains(ring, pt.Lon*1e7, pt.Lat*1e7) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, isochrone := range isochrones {
+		if len(isochrone.Polygons) == 0 {
+			t.Fatalf("Isochrone for limit %f should not be empty", isochrone.Limit)
+		}
+		for _, polygon := range isochrone.Polygons {
+			for _, ring := range polygon {
+				if ring[0] != ring[len(ring)-1] {
+					t.Errorf("Ring should be closed: %v", ring)
+				}
+			}
+		}
+		for _, vertex := range vertices {
+			if costs[vertex.ID] <= isochrone.Limit && !contains(isochrone, vertex.Geom) {
+				t.Errorf("Vertex %d (cost %f) should be inside of isochrone for limit %f", vertex.ID, costs[vertex.ID], isochrone.Limit)
+			}
+		}
+	}
+	if contains(isochrones[0], vertices[len(vertices)-1].Geom) && costs[vertices[len(vertices)-1].ID] > 0.2 {
+		t.Errorf("Far vertex should not be inside of the smallest isochrone")
+	}
+}
+
+func TestIsochronesLimits(t *testing.T) {
+	router := prepareLongEdgesRouter(t)
+	source := router.Vertices()[0].ID
+	for _, cellSize := range []float64{-1, 0, 5, math.NaN()} {
+		if _, err := router.Isochrones(source, []float64{1}, cellSize); err == nil {
+			t.Errorf("Size of cell %v should be rejected", cellSize)
+		}
+	}
+	if _, err := router.Isochrones(source, make([]float64, IsochroneMaxLimits+1), 1000); err == nil {
+		t.Errorf("More than %d limits should be rejected", IsochroneMaxLimits)
+	}
+	// Reachable geometry covers about 1 degree x 1 degree
+	if _, err := router.Isochrones(source, []float64{1000}, IsochroneMinCellSize); err == nil {
+		t.Errorf("Too large grid should be rejected")
+	}
+	isochrones, err := router.Isochrones(source, []float64{1000}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(isochrones) != 1 || len(isochrones[0].Polygons) == 0 {
+		t.Errorf("Grid of large cells should give isochrone, but got %v", isochrones)
+	}
+}
+
+// prepareLongEdgesRouter Returns uncontracted router for two expanded edges between vertices at distance of about 1 degree
+func prepareLongEdgesRouter(t *testing.T) *Router {
+	expandedEdges := []ExpandedEdge{
+		{ID: 1, Source: 1, Target: 2, CostMeters: 150, Geom: []GeoPoint{{Lon: 37, Lat: 55}, {Lon: 38, Lat: 56}}},
+		{ID: 2, Source: 2, Target: 1, CostMeters: 150, Geom: []GeoPoint{{Lon: 38, Lat: 56}, {Lon: 37, Lat: 55}}},
+	}
+	graph, err := PrepareGraph(expandedEdges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, err := NewRouter(expandedEdges, PrepareVertices(graph, expandedEdges), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
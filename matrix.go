@@ -0,0 +1,66 @@
+package osm2ch
+
+import (
+	"runtime"
+	"sync"
+)
+
+// OneToMany Returns costs of shortest paths from source vertex to every target vertex (-1 if there is no path)
+func (router *Router) OneToMany(source int64, targets []int64) []float64 {
+	costs := make([]float64, len(targets))
+	if !router.contracted {
+		for i, target := range targets {
+			costs[i], _ = router.graph.VanillaShortestPath(source, target)
+		}
+		return costs
+	}
+	sourceInternal, ok := router.graph.FindVertex(source)
+	if !ok {
+		for i := range costs {
+			costs[i] = -1
+		}
+		return costs
+	}
+	found, _ := router.graph.ShortestPathOneToMany(source, targets)
+	for i, target := range targets {
+		switch {
+		case target == source:
+			costs[i] = 0
+		case target == sourceInternal:
+			// ShortestPathOneToMany compares internal ID of source with user defined ID of target, so such pairs are evaluated separately
+			costs[i], _ = router.graph.ShortestPath(source, target)
+		default:
+			costs[i] = found[i]
+		}
+	}
+	return costs
+}
+
+// DistanceMatrix Returns costs of shortest paths from every source vertex to every target vertex: matrix[i][j] is cost of path from
+// sources[i] to targets[j] (-1 if there is no path)
+/*
+	Sources are processed in parallel. If workers is less or equal to zero then number of logical CPUs is used
+*/
+func (router *Router) DistanceMatrix(sources, targets []int64, workers int) [][]float64 {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	matrix := make([][]float64, len(sources))
+	jobs := make(chan int, len(sources))
+	for i := range sources {
+		jobs <- i
+	}
+	close(jobs)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				matrix[i] = router.OneToMany(sources[i], targets)
+			}
+		}()
+	}
+	wg.Wait()
+	return matrix
+}
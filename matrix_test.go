@@ -0,0 +1,29 @@
+package osm2ch
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceMatrix(t *testing.T) {
+	router := prepareTestRouter(t, true)
+	vertices := router.Vertices()
+	ids := make([]int64, len(vertices))
+	for i := range vertices {
+		ids[i] = vertices[i].ID
+	}
+	// Unknown vertex gives no path
+	targets := append(ids[:len(ids):len(ids)], -1)
+	matrix := router.DistanceMatrix(ids, targets, 3)
+	for i, source := range ids {
+		if len(matrix[i]) != len(targets) {
+			t.Fatalf("Row #%d should have %d columns, but got %d", i, len(targets), len(matrix[i]))
+		}
+		for j, target := range targets {
+			expected, _ := router.graph.ShortestPath(source, target)
+			if math.Abs(matrix[i][j]-expected) > 1e-9 {
+				t.Errorf("Cost of path from %d to %d should be %f, but got %f", source, target, expected, matrix[i][j])
+			}
+		}
+	}
+}
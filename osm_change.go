@@ -0,0 +1,137 @@
+package osm2ch
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/paulmach/osm"
+	"github.com/pkg/errors"
+)
+
+// ReadChangeFile Reads OsmChange file (*.osc or gzipped *.osc.gz)
+/*
+	See ref. https://wiki.openstreetmap.org/wiki/OsmChange
+*/
+func ReadChangeFile(fileName string) (*osm.Change, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, errors.Wrap(err, "File open")
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(strings.ToLower(fileName), ".gz") {
+		gzipReader, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, errors.Wrap(err, "Can't prepare gzip reader")
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	}
+	return ReadChange(r)
+}
+
+// ReadChange Reads OsmChange data in XML format
+func ReadChange(r io.Reader) (*osm.Change, error) {
+	change := osm.Change{}
+	err := xml.NewDecoder(r).Decode(&change)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't decode OsmChange")
+	}
+	return &change, nil
+}
+
+// ApplyChange Applies OsmChange diff to the state: updates ways, nodes and restrictions and recomputes only impacted edges and expanded edges
+/*
+	Blocks of diff are applied in order: create, modify, delete. Objects with version lower than already known one are ignored.
+	State keeps nodes of all ways (not only filtered ones), so way which starts to satisfy configuration or uses node of another way
+	does not need unchanged nodes in diff. Nodes which are not used by any way are not in state: they should be provided by diff
+	if some way starts to use them, otherwise error is returned and full rebuild is needed. State should not be used after an error.
+*/
+func (state *GraphState) ApplyChange(change *osm.Change) error {
+	data := state.data
+	impactedWays := make(map[osm.WayID]struct{})
+	impactedNodes := make(map[osm.NodeID]struct{})
+	updatedNodes := make(map[osm.NodeID]Node)
+	deletedNodes := make(map[osm.NodeID]struct{})
+	// Nodes of all created and modified ways (even if they do not satisfy configuration)
+	wayNodes := make(map[osm.NodeID]struct{})
+
+	fmt.Printf("Applying changes...")
+	st := time.Now()
+	for _, block := range []*osm.OSM{change.Create, change.Modify} {
+		if block == nil {
+			continue
+		}
+		for _, node := range block.Nodes {
+			if prev, ok := updatedNodes[node.ID]; ok && prev.node.Version > node.Version {
+				continue
+			}
+			updatedNodes[node.ID] = prepareNode(node)
+			delete(deletedNodes, node.ID)
+		}
+		for _, way := range block.Ways {
+			for _, node := range way.Nodes {
+				wayNodes[node.ID] = struct{}{}
+			}
+			if prev, ok := data.ways[way.ID]; ok {
+				if prev.Version > way.Version {
+					continue
+				}
+				delete(data.ways, way.ID)
+			}
+			impactedWays[way.ID] = struct{}{}
+			// If way does not satisfy configuration anymore then it just will not be added back
+			data.addWay(way, &state.cfg)
+		}
+		for _, relation := range block.Relations {
+			if version, ok := data.relationVersion(relation.ID); ok && version > relation.Version {
+				continue
+			}
+			data.deleteRelation(relation.ID)
+			data.addRelation(relation)
+		}
+	}
+	if change.Delete != nil {
+		for _, node := range change.Delete.Nodes {
+			delete(updatedNodes, node.ID)
+			deletedNodes[node.ID] = struct{}{}
+		}
+		for _, way := range change.Delete.Ways {
+			if _, ok := data.ways[way.ID]; ok {
+				delete(data.ways, way.ID)
+				impactedWays[way.ID] = struct{}{}
+			}
+		}
+		for _, relation := range change.Delete.Relations {
+			data.deleteRelation(relation.ID)
+		}
+	}
+	for id, node := range updatedNodes {
+		if prev, ok := data.nodes[id]; ok {
+			if prev.node.Version > node.node.Version {
+				continue
+			}
+			if prev.node.Lon != node.node.Lon || prev.node.Lat != node.node.Lat {
+				impactedNodes[id] = struct{}{}
+			}
+		} else if _, ok := wayNodes[id]; !ok {
+			// Node is not used by any way (e.g. point of interest)
+			continue
+		}
+		data.nodes[id] = node
+	}
+	for id := range deletedNodes {
+		if _, ok := data.nodes[id]; ok {
+			delete(data.nodes, id)
+			impactedNodes[id] = struct{}{}
+		}
+	}
+	fmt.Printf("Done in %v\n\tImpacted ways: %d\n\tImpacted nodes: %d\n", time.Since(st), len(impactedWays), len(impactedNodes))
+
+	return state.rebuild(impactedWays, impactedNodes, false)
+}
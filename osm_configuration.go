@@ -2,8 +2,12 @@ package osm2ch
 
 // OsmConfiguration Allows to filter ways by certain tags from OSM data
 type OsmConfiguration struct {
-	EntityName string // Currrently we support 'highway' only
-	Tags       []string
+	EntityName    string // Currently we support 'highway' only
+	Tags          []string
+	Workers       int            // Number of workers for edge expanding technique. If it is less or equal to zero then number of logical CPUs is used
+	DeadEndUTurns bool           // Allow u-turns at nodes where u-turn is the only possible transition (dead ends and boundaries of extract). U-turns at regular intersections are forbidden always
+	UTurnPenalty  float64        // Penalty which is added to cost of u-turn (kilometers, same as CostMeters of edges)
+	EdgeIDs       *EdgeIDMapping // Persistent mapping for IDs of edges (vertices of expanded graph). If it is nil then edges are numbered from 1 on every run
 }
 
 // CheckTag Checks if incoming tag is represented in configuration
@@ -0,0 +1,256 @@
+package osm2ch
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/paulmach/osm"
+)
+
+// osmData Raw OSM data which is needed to build graph: filtered ways, nodes of all ways (not only filtered ones) and restrictions
+type osmData struct {
+	ways         map[osm.WayID]Way
+	nodes        map[osm.NodeID]Node
+	restrictions map[osm.RelationID]restriction
+
+	// Relations are tracked by ID (like restrictions), so the same relation in several extracts is counted once
+	skippedRestrictions         map[osm.RelationID]int // Versions of restriction relations which have not exactly 3 members
+	unsupportedRestrictionRoles map[osm.RelationID]int // Numbers of members with unsupported roles of kept restrictions
+}
+
+// newOSMData Returns empty storage for OSM data
+func newOSMData() *osmData {
+	return &osmData{
+		ways:         make(map[osm.WayID]Way),
+		nodes:        make(map[osm.NodeID]Node),
+		restrictions: make(map[osm.RelationID]restriction),
+
+		skippedRestrictions:         make(map[osm.RelationID]int),
+		unsupportedRestrictionRoles: make(map[osm.RelationID]int),
+	}
+}
+
+// restriction represents restriction relation which has exactly 3 members
+type restriction struct {
+	Tag     string
+	From    restrictionComponent
+	To      restrictionComponent
+	Via     restrictionComponent
+	Version int
+}
+
+// prepareWay Converts OSM way to the Way. Returns false if way does not satisfy configuration
+func prepareWay(way *osm.Way, cfg *OsmConfiguration) (Way, bool) {
+	tagMap := way.TagMap()
+	tag, ok := tagMap[cfg.EntityName]
+	if !ok {
+		return Way{}, false
+	}
+	if !cfg.CheckTag(tag) {
+		return Way{}, false
+	}
+	oneway := false
+	if v, ok := tagMap["oneway"]; ok {
+		if v == "yes" || v == "1" {
+			oneway = true
+		}
+	}
+	preparedWay := Way{
+		ID:      way.ID,
+		Version: way.Version,
+		Nodes:   make(osm.WayNodes, len(way.Nodes)),
+		Oneway:  oneway,
+		TagMap:  make(osm.Tags, len(way.Tags)),
+	}
+	copy(preparedWay.Nodes, way.Nodes)
+	copy(preparedWay.TagMap, way.Tags)
+	return preparedWay, true
+}
+
+// prepareNode Converts OSM node to the Node
+func prepareNode(node *osm.Node) Node {
+	return Node{
+		ID:       node.ID,
+		useCount: 0,
+		node: osm.Node{
+			ID:      node.ID,
+			Lat:     node.Lat,
+			Lon:     node.Lon,
+			Version: node.Version,
+		},
+	}
+}
+
+// prepareRestriction Converts OSM relation to the restriction
+/*
+	Returns false if relation is not a restriction or does not contain exactly 3 members.
+	Second returned value is number of members with unsupported roles (only 'from', 'to' and 'via' are supported)
+*/
+func prepareRestriction(relation *osm.Relation) (restriction, int, bool) {
+	tag, ok := relation.TagMap()["restriction"]
+	if !ok {
+		return restriction{}, 0, false
+	}
+	members := relation.Members
+	if len(members) != 3 {
+		return restriction{}, 0, false
+	}
+	unsupportedRoles := 0
+	prepared := restriction{
+		Tag:     tag,
+		From:    restrictionComponent{-1, ""},
+		To:      restrictionComponent{-1, ""},
+		Via:     restrictionComponent{-1, ""},
+		Version: relation.Version,
+	}
+	for _, member := range members {
+		switch member.Role {
+		case "from":
+			prepared.From = restrictionComponent{member.Ref, string(member.Type)}
+		case "via":
+			prepared.Via = restrictionComponent{member.Ref, string(member.Type)}
+		case "to":
+			prepared.To = restrictionComponent{member.Ref, string(member.Type)}
+		default:
+			unsupportedRoles++
+		}
+	}
+	return prepared, unsupportedRoles, true
+}
+
+// addWay Adds way if it satisfies configuration. Returns true if way has been added
+/*
+	If way with the same ID has been added already then the way with the highest version is kept (if versions are equal then
+	the way with more nodes is kept). If newer version of way does not satisfy configuration then older version is deleted
+*/
+func (data *osmData) addWay(way *osm.Way, cfg *OsmConfiguration) bool {
+	if prev, ok := data.ways[way.ID]; ok {
+		if prev.Version > way.Version || (prev.Version == way.Version && len(prev.Nodes) >= len(way.Nodes)) {
+			return false
+		}
+	}
+	preparedWay, ok := prepareWay(way, cfg)
+	if !ok {
+		delete(data.ways, way.ID)
+		return false
+	}
+	data.ways[way.ID] = preparedWay
+	return true
+}
+
+// addNode Adds node. If node with the same ID has been added already then the node with the highest version is kept
+func (data *osmData) addNode(node *osm.Node) {
+	if prev, ok := data.nodes[node.ID]; ok && prev.node.Version >= node.Version {
+		return
+	}
+	data.nodes[node.ID] = prepareNode(node)
+}
+
+// addRelation Adds relation if it is a restriction. If relation with the same ID has been added (or skipped) already then the relation with the highest version is kept
+func (data *osmData) addRelation(relation *osm.Relation) {
+	if _, ok := relation.TagMap()["restriction"]; !ok {
+		return
+	}
+	if version, ok := data.relationVersion(relation.ID); ok && version >= relation.Version {
+		return
+	}
+	data.deleteRelation(relation.ID)
+	prepared, unsupportedRoles, ok := prepareRestriction(relation)
+	if !ok {
+		data.skippedRestrictions[relation.ID] = relation.Version
+		return
+	}
+	if unsupportedRoles > 0 {
+		data.unsupportedRestrictionRoles[relation.ID] = unsupportedRoles
+	}
+	data.restrictions[relation.ID] = prepared
+}
+
+// relationVersion Returns version of restriction relation which has been added or skipped
+func (data *osmData) relationVersion(id osm.RelationID) (int, bool) {
+	if r, ok := data.restrictions[id]; ok {
+		return r.Version, true
+	}
+	version, ok := data.skippedRestrictions[id]
+	return version, ok
+}
+
+// deleteRelation Forgets restriction relation (either added or skipped)
+func (data *osmData) deleteRelation(id osm.RelationID) {
+	delete(data.restrictions, id)
+	delete(data.skippedRestrictions, id)
+	delete(data.unsupportedRestrictionRoles, id)
+}
+
+// unsupportedRolesNum Returns number of members with unsupported roles of kept restrictions
+func (data *osmData) unsupportedRolesNum() int {
+	num := 0
+	for _, roles := range data.unsupportedRestrictionRoles {
+		num += roles
+	}
+	return num
+}
+
+// sortedWaysIDs Returns IDs of ways in ascending order
+func (data *osmData) sortedWaysIDs() []osm.WayID {
+	ids := make([]osm.WayID, 0, len(data.ways))
+	for id := range data.ways {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// restrictionsMap Returns restrictions grouped by tag, 'from' member and 'to' member. Value is 'via' member
+/*
+	Restrictions are processed in ascending order of relations IDs: if there are several restrictions with the same tag
+	and the same 'from' and 'to' members, then only first one is kept
+*/
+func (data *osmData) restrictionsMap() map[string]map[restrictionComponent]map[restrictionComponent]restrictionComponent {
+	ids := make([]osm.RelationID, 0, len(data.restrictions))
+	for id := range data.restrictions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	restrictions := make(map[string]map[restrictionComponent]map[restrictionComponent]restrictionComponent)
+	for _, id := range ids {
+		r := data.restrictions[id]
+		if _, ok := restrictions[r.Tag]; !ok {
+			restrictions[r.Tag] = make(map[restrictionComponent]map[restrictionComponent]restrictionComponent)
+		}
+		if _, ok := restrictions[r.Tag][r.From]; !ok {
+			restrictions[r.Tag][r.From] = make(map[restrictionComponent]restrictionComponent)
+		}
+		if _, ok := restrictions[r.Tag][r.From][r.To]; !ok {
+			restrictions[r.Tag][r.From][r.To] = r.Via
+		}
+	}
+	return restrictions
+}
+
+// countNodeUses Counts how many times each node is used by ways. First and last nodes of way are counted twice, so they always split ways into edges
+func (data *osmData) countNodeUses() error {
+	for id, node := range data.nodes {
+		node.useCount = 0
+		data.nodes[id] = node
+	}
+	for _, way := range data.ways {
+		for i, wayNode := range way.Nodes {
+			node, ok := data.nodes[wayNode.ID]
+			if !ok {
+				return fmt.Errorf("Missing node with id: %d", wayNode.ID)
+			}
+			if i == 0 || i == len(way.Nodes)-1 {
+				node.useCount += 2
+			} else {
+				node.useCount++
+			}
+			data.nodes[wayNode.ID] = node
+		}
+	}
+	return nil
+}
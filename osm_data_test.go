@@ -0,0 +1,71 @@
+package osm2ch
+
+import (
+	"testing"
+
+	"github.com/paulmach/osm"
+)
+
+func TestOSMDataDeduplication(t *testing.T) {
+	cfg := OsmConfiguration{
+		EntityName: "highway",
+		Tags:       []string{"residential"},
+	}
+	residential := osm.Tags{{Key: "highway", Value: "residential"}}
+	data := newOSMData()
+
+	// Way clipped by boundary of first extract
+	data.addWay(&osm.Way{ID: 1, Version: 3, Tags: residential, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}}}, &cfg)
+	// The same way in second extract is complete
+	data.addWay(&osm.Way{ID: 1, Version: 3, Tags: residential, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}, {ID: 3}}}, &cfg)
+	if len(data.ways[1].Nodes) != 3 {
+		t.Errorf("Way with more nodes should be kept for equal versions, but got %d nodes", len(data.ways[1].Nodes))
+	}
+	// Older version should be ignored
+	data.addWay(&osm.Way{ID: 1, Version: 2, Tags: residential, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}}, &cfg)
+	if data.ways[1].Version != 3 || len(data.ways[1].Nodes) != 3 {
+		t.Errorf("Older version of way should be ignored")
+	}
+	// Newer version which does not satisfy configuration deletes way
+	data.addWay(&osm.Way{ID: 1, Version: 4, Tags: osm.Tags{{Key: "highway", Value: "footway"}}, Nodes: osm.WayNodes{{ID: 1}, {ID: 2}}}, &cfg)
+	if _, ok := data.ways[1]; ok {
+		t.Errorf("Way should be deleted since its newer version does not satisfy configuration")
+	}
+
+	data.addNode(&osm.Node{ID: 1, Version: 2, Lon: 1, Lat: 1})
+	data.addNode(&osm.Node{ID: 1, Version: 1, Lon: 2, Lat: 2})
+	if data.nodes[1].node.Lon != 1 {
+		t.Errorf("Node with the highest version should be kept")
+	}
+	data.addNode(&osm.Node{ID: 1, Version: 3, Lon: 3, Lat: 3})
+	if data.nodes[1].node.Lon != 3 {
+		t.Errorf("Node with the highest version should be kept")
+	}
+
+	restrictionTags := osm.Tags{{Key: "type", Value: "restriction"}, {Key: "restriction", Value: "no_left_turn"}}
+	malformed := &osm.Relation{ID: 10, Version: 1, Tags: restrictionTags, Members: osm.Members{{Type: osm.TypeWay, Ref: 1, Role: "from"}}}
+	withUnknownRole := &osm.Relation{ID: 11, Version: 1, Tags: restrictionTags, Members: osm.Members{
+		{Type: osm.TypeWay, Ref: 1, Role: "from"},
+		{Type: osm.TypeNode, Ref: 2, Role: "via"},
+		{Type: osm.TypeWay, Ref: 3, Role: "location_hint"},
+	}}
+	// The same relations in overlapping extracts
+	for i := 0; i < 2; i++ {
+		data.addRelation(malformed)
+		data.addRelation(withUnknownRole)
+	}
+	if len(data.skippedRestrictions) != 1 || data.unsupportedRolesNum() != 1 {
+		t.Errorf("Duplicated relations should be counted once, but got %d skipped restrictions and %d unsupported roles", len(data.skippedRestrictions), data.unsupportedRolesNum())
+	}
+	// Newer versions replace previous ones
+	validMembers := osm.Members{
+		{Type: osm.TypeWay, Ref: 1, Role: "from"},
+		{Type: osm.TypeNode, Ref: 2, Role: "via"},
+		{Type: osm.TypeWay, Ref: 3, Role: "to"},
+	}
+	data.addRelation(&osm.Relation{ID: 10, Version: 2, Tags: restrictionTags, Members: validMembers})
+	data.addRelation(&osm.Relation{ID: 11, Version: 2, Tags: restrictionTags, Members: validMembers})
+	if len(data.skippedRestrictions) != 0 || data.unsupportedRolesNum() != 0 || len(data.restrictions) != 2 {
+		t.Errorf("Newer versions of relations should replace previous ones, but got %d skipped restrictions, %d unsupported roles and %d restrictions", len(data.skippedRestrictions), data.unsupportedRolesNum(), len(data.restrictions))
+	}
+}
@@ -3,7 +3,6 @@ package osm2ch
 import (
 	"context"
 	"fmt"
-	"io"
 	"os"
 	"time"
 
@@ -16,422 +15,132 @@ import (
 // ImportFromOSMFile Imports graph from file of PBF-format (in OSM terms)
 /*
 	File should have PBF (Protocolbuffer Binary Format) extension according to https://github.com/paulmach/osm
+
+	Output is deterministic for the same file and configuration:
+		- ways are processed in ascending order of their OSM IDs;
+		- edges are numbered from 1 along each way (for two-way roads forward edge goes first and reverse edge goes right after it);
+		- expanded edges are ordered (and numbered from 1) by ID of source edge and then by ID of target edge.
+	If cfg.EdgeIDs is provided then IDs of edges are taken from this mapping (and mapping is extended by new edges), but order
+	of edges (and therefore of expanded edges) stays the same.
 */
 func ImportFromOSMFile(fileName string, cfg *OsmConfiguration) ([]ExpandedEdge, error) {
-	f, err := os.Open(fileName)
+	return ImportFromOSMFiles([]string{fileName}, cfg)
+}
+
+// ImportFromOSMFiles Imports single graph from several files of PBF-format (e.g. neighboring regional extracts)
+/*
+	Objects which are present in several files are deduplicated by ID: the one with the highest version wins
+	(if versions are equal then way with more nodes wins, since extracts could clip ways at their boundaries).
+	See ImportFromOSMFile for details about IDs.
+*/
+func ImportFromOSMFiles(fileNames []string, cfg *OsmConfiguration) ([]ExpandedEdge, error) {
+	state, err := ImportStateFromOSMFiles(fileNames, cfg)
 	if err != nil {
-		return nil, errors.Wrap(err, "File open")
+		return nil, err
 	}
-	defer f.Close()
+	return state.ExpandedEdges(), nil
+}
+
+// ImportStateFromOSMFile Imports graph from file of PBF-format (in OSM terms) and returns state of import
+/*
+	State could be saved (see SaveToFile) and used later to apply OsmChange diffs (see ApplyChange) without full rebuild
+*/
+func ImportStateFromOSMFile(fileName string, cfg *OsmConfiguration) (*GraphState, error) {
+	return ImportStateFromOSMFiles([]string{fileName}, cfg)
+}
 
-	scannerWays := osmpbf.New(context.Background(), f, 4)
-	defer scannerWays.Close()
+// ImportStateFromOSMFiles Imports single graph from several files of PBF-format and returns state of import
+/*
+	See ImportFromOSMFiles and ImportStateFromOSMFile
+*/
+func ImportStateFromOSMFiles(fileNames []string, cfg *OsmConfiguration) (*GraphState, error) {
+	data, err := readOSMFiles(fileNames, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newGraphState(data, cfg)
+}
 
-	ways := []Way{}
-	nodes := make(map[osm.NodeID]Node)
+// readOSMFiles Reads ways (filtered by configuration), nodes of all ways and restrictions from files of PBF-format
+/*
+	Every file is scanned three times: for ways, for nodes and for relations.
+	Nodes of ways which do not satisfy configuration are kept too: OsmChange diff could make such way satisfy configuration
+	(or connect new way to its node) without providing unchanged nodes (see ApplyChange)
+*/
+func readOSMFiles(fileNames []string, cfg *OsmConfiguration) (*osmData, error) {
+	if len(fileNames) == 0 {
+		return nil, errors.New("No files to read")
+	}
+	data := newOSMData()
 	nodesSeen := make(map[osm.NodeID]struct{})
 
 	fmt.Printf("Scanning ways...")
 	st := time.Now()
-	for scannerWays.Scan() {
-		obj := scannerWays.Object()
-		if obj.ObjectID().Type() != "way" {
-			continue
-		}
+	err := scanOSMFiles(fileNames, osm.TypeWay, func(obj osm.Object) {
 		way := obj.(*osm.Way)
-		tagMap := way.TagMap()
-		tag, ok := tagMap[cfg.EntityName]
-		if !ok {
-			continue
-		}
-		if !cfg.CheckTag(tag) {
-			continue
-		}
-		oneway := false
-		if v, ok := tagMap["oneway"]; ok {
-			if v == "yes" || v == "1" {
-				oneway = true
-			}
-		}
-		nodes := way.Nodes
-		preparedWay := Way{
-			ID:     way.ID,
-			Nodes:  make(osm.WayNodes, len(nodes)),
-			Oneway: oneway,
-			TagMap: make(osm.Tags, len(way.Tags)),
-		}
-		copy(preparedWay.Nodes, nodes)
-		copy(preparedWay.TagMap, way.Tags)
-		ways = append(ways, preparedWay)
-		for _, node := range nodes {
+		data.addWay(way, cfg)
+		for _, node := range way.Nodes {
 			nodesSeen[node.ID] = struct{}{}
 		}
-	}
-	if scannerWays.Err() != nil {
-		return nil, errors.Wrap(scannerWays.Err(), "Scanner error on Ways")
-	}
-	fmt.Printf("Done in %v\n\tWays: %d\n", time.Since(st), len(ways))
-
-	// Seek file to start
-	_, err = f.Seek(0, io.SeekStart)
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "Can't repeat seeking after ways scanning")
+		return nil, errors.Wrap(err, "Scanner error on Ways")
 	}
-	scannerNodes := osmpbf.New(context.Background(), f, 4)
-	defer scannerNodes.Close()
+	fmt.Printf("Done in %v\n\tWays: %d\n", time.Since(st), len(data.ways))
 
 	fmt.Printf("Scanning nodes...")
 	st = time.Now()
-	for scannerNodes.Scan() {
-		obj := scannerNodes.Object()
-		if obj.ObjectID().Type() != "node" {
-			continue
-		}
+	err = scanOSMFiles(fileNames, osm.TypeNode, func(obj osm.Object) {
 		node := obj.(*osm.Node)
 		if _, ok := nodesSeen[node.ID]; ok {
-			delete(nodesSeen, node.ID)
-			nodes[node.ID] = Node{
-				ID:       node.ID,
-				useCount: 0,
-				node:     *node,
-			}
+			data.addNode(node)
 		}
-	}
-	if scannerNodes.Err() != nil {
-		return nil, errors.Wrap(scannerNodes.Err(), "Scanner error on Nodes")
-	}
-	fmt.Printf("Done in %v\n\tNodes: %d\n", time.Since(st), len(nodes))
-
-	// Seek file to start
-	_, err = f.Seek(0, io.SeekStart)
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "Can't repeat seeking after nodes scanning")
+		return nil, errors.Wrap(err, "Scanner error on Nodes")
 	}
-	scannerManeuvers := osmpbf.New(context.Background(), f, 4)
-	defer scannerManeuvers.Close()
-	fmt.Printf("Scanning maneuvers (restrictions)...")
-	st = time.Now()
-	skippedRestrictions := 0
-	unsupportedRestrictionRoles := 0
-	possibleRestrictionCombos := make(map[string]map[string]bool)
-	restrictions := make(map[string]map[restrictionComponent]map[restrictionComponent]restrictionComponent)
-	for scannerManeuvers.Scan() {
-		obj := scannerManeuvers.Object()
-		if obj.ObjectID().Type() == "relation" {
-			relation := obj.(*osm.Relation)
-			tagMap := relation.TagMap()
-			tag, ok := tagMap["restriction"]
-			if !ok {
-				continue
-			}
-			members := relation.Members
-			if len(members) != 3 {
-				skippedRestrictions++
-				// fmt.Printf("Restriction does not contain 3 members, relation ID: %d. Skip it\n", relation.ID)
-				continue
-			}
-			firstMember := restrictionComponent{-1, ""}
-			secondMember := restrictionComponent{-1, ""}
-			thirdMember := restrictionComponent{-1, ""}
-
-			switch members[0].Role {
-			case "from":
-				firstMember = restrictionComponent{members[0].Ref, string(members[0].Type)}
-				break
-			case "via":
-				thirdMember = restrictionComponent{members[0].Ref, string(members[0].Type)}
-				break
-			case "to":
-				secondMember = restrictionComponent{members[0].Ref, string(members[0].Type)}
-				break
-			default:
-				unsupportedRestrictionRoles++
-				// fmt.Printf("Something went wrong for first member of relation with ID: %d\n", relation.ID)
-				break
-			}
-
-			switch members[1].Role {
-			case "from":
-				firstMember = restrictionComponent{members[1].Ref, string(members[1].Type)}
-				break
-			case "via":
-				thirdMember = restrictionComponent{members[1].Ref, string(members[1].Type)}
-				break
-			case "to":
-				secondMember = restrictionComponent{members[1].Ref, string(members[1].Type)}
-				break
-			default:
-				unsupportedRestrictionRoles++
-				// fmt.Printf("Something went wrong for second member of relation with ID: %d\n", relation.ID)
-				break
-			}
-
-			switch members[2].Role {
-			case "from":
-				firstMember = restrictionComponent{members[2].Ref, string(members[2].Type)}
-				break
-			case "via":
-				thirdMember = restrictionComponent{members[2].Ref, string(members[2].Type)}
-				break
-			case "to":
-				secondMember = restrictionComponent{members[2].Ref, string(members[2].Type)}
-				break
-			default:
-				unsupportedRestrictionRoles++
-				// fmt.Printf("Something went wrong for third member of relation with ID: %d\n", relation.ID)
-				break
-			}
-			if _, ok := possibleRestrictionCombos[tag]; !ok {
-				possibleRestrictionCombos[tag] = make(map[string]bool)
-			}
-			possibleRestrictionCombos[tag][fmt.Sprintf("%s;%s;%s", firstMember.Type, secondMember.Type, thirdMember.Type)] = true
-
-			if _, ok := restrictions[tag]; !ok {
-				restrictions[tag] = make(map[restrictionComponent]map[restrictionComponent]restrictionComponent)
-			}
-			if _, ok := restrictions[tag][firstMember]; !ok {
-				restrictions[tag][firstMember] = make(map[restrictionComponent]restrictionComponent)
-			}
-			if _, ok := restrictions[tag][firstMember][secondMember]; !ok {
-				restrictions[tag][firstMember][secondMember] = thirdMember
-			}
-		}
-	}
-	fmt.Printf("Done in %v\n", time.Since(st))
-	fmt.Printf("\tSkipped restrictions (which have not exactly 3 members): %d\n", skippedRestrictions)
-	fmt.Printf("\tNumber of unknow restriction roles (only 'from', 'to' and 'via' supported): %d\n", unsupportedRestrictionRoles)
+	fmt.Printf("Done in %v\n\tNodes: %d\n", time.Since(st), len(data.nodes))
 
-	fmt.Printf("Counting node use cases...")
+	fmt.Printf("Scanning maneuvers (restrictions)...")
 	st = time.Now()
-	for _, way := range ways {
-		for i, wayNode := range way.Nodes {
-			if node, ok := nodes[wayNode.ID]; ok {
-				if i == 0 || i == len(way.Nodes)-1 {
-					node.useCount += 2
-					nodes[wayNode.ID] = node
-				} else {
-					node.useCount += 1
-					nodes[wayNode.ID] = node
-				}
-			} else {
-				return nil, fmt.Errorf("Missing node with id: %d\n", wayNode.ID)
-			}
-		}
+	err = scanOSMFiles(fileNames, osm.TypeRelation, func(obj osm.Object) {
+		data.addRelation(obj.(*osm.Relation))
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Scanner error on Relations")
 	}
 	fmt.Printf("Done in %v\n", time.Since(st))
+	fmt.Printf("\tSkipped restrictions (which have not exactly 3 members): %d\n", len(data.skippedRestrictions))
+	fmt.Printf("\tNumber of unknow restriction roles (only 'from', 'to' and 'via' supported): %d\n", data.unsupportedRolesNum())
+	return data, nil
+}
 
-	fmt.Printf("Preparing edges...")
-	st = time.Now()
-	edges := []Edge{}
-	onewayEdges := 0
-	notOnewayEdges := 0
-	totalEdgesNum := int64(0)
-	waysSeen := make(map[osm.WayID]struct{})
-	for _, way := range ways {
-		var source osm.NodeID
-		waysSeen[way.ID] = struct{}{}
-		geometry := []GeoPoint{}
-		for i, wayNode := range way.Nodes {
-			node := nodes[wayNode.ID]
-			if i == 0 {
-				source = wayNode.ID
-				geometry = append(geometry, GeoPoint{Lon: node.node.Lon, Lat: node.node.Lat})
-			} else {
-				geometry = append(geometry, GeoPoint{Lon: node.node.Lon, Lat: node.node.Lat})
-				if node.useCount > 1 {
-					totalEdgesNum++
-					onewayEdges++
-					cost := getSphericalLength(geometry)
-					edges = append(edges, Edge{
-						ID:           EdgeID(totalEdgesNum),
-						WayID:        way.ID,
-						SourceNodeID: source,
-						TargetNodeID: wayNode.ID,
-						CostMeters:   cost,
-						Geom:         copyLine(geometry),
-						WasOneway:    way.Oneway,
-					})
-					if !way.Oneway {
-						totalEdgesNum++
-						notOnewayEdges++
-						edges = append(edges, Edge{
-							ID:           EdgeID(totalEdgesNum),
-							WayID:        way.ID,
-							SourceNodeID: wayNode.ID,
-							TargetNodeID: source,
-							CostMeters:   cost,
-							Geom:         reverseLine(geometry),
-							WasOneway:    false,
-						})
-					}
-					source = wayNode.ID
-					geometry = []GeoPoint{GeoPoint{Lon: node.node.Lon, Lat: node.node.Lat}}
-				}
-			}
-		}
-	}
-	fmt.Printf("Done in %v\n\tEdges: (oneway = %d), (not oneway = %d) (total = %d)\n", time.Since(st), onewayEdges, notOnewayEdges, totalEdgesNum)
-
-	fmt.Printf("Preparing nodes...")
-	st = time.Now()
-	nodesFiltered := []Node{}
-	for _, node := range nodes {
-		if node.useCount > 1 {
-			nodesFiltered = append(nodesFiltered, node)
-		}
-	}
-	fmt.Printf("Done in %v\n\tNodes: %d\n", time.Since(st), len(nodesFiltered))
-
-	fmt.Printf("Applying edge expanding technique...")
-	st = time.Now()
-
-	// create edge index by SourceNodeID
-	edgesBySourceNodeID := make(map[osm.NodeID][]EdgeID)
-	for _, edge := range edges {
-		edgesBySourceNodeID[edge.SourceNodeID] = append(edgesBySourceNodeID[edge.SourceNodeID], edge.ID)
-	}
-
-	cycles := 0
-	expandedEdges := []ExpandedEdge{}
-	expandedEdgesTotal := int64(0)
-	for _, edge := range edges {
-		edgeAsFromVertex := edge
-		costMetersFromVertex := edgeAsFromVertex.CostMeters
-		outcomingEdges := edgesBySourceNodeID[edgeAsFromVertex.TargetNodeID]
-		for _, outcomingEdge := range outcomingEdges {
-			if outcomingEdge == edgeAsFromVertex.ID {
-				continue
-			}
-			edgeAsToVertex := edges[outcomingEdge-1] // We assuming that EdgeID == (SliceIndex + 1) which is equivalent to SliceIndex == (EdgeID - 1)
-			// cycles, u-turn?
-			// @todo: some of those are deadend (or 'boundary') edges
-			if edgeAsFromVertex.Geom[0] == edgeAsToVertex.Geom[len(edgeAsToVertex.Geom)-1] && edgeAsFromVertex.Geom[len(edgeAsFromVertex.Geom)-1] == edgeAsToVertex.Geom[0] {
-				// fmt.Println(PrepareGeoJSONLinestring(edgeAsFromVertex.Geom))
-				cycles++
-				continue
-			}
-			costMetersToVertex := edgeAsToVertex.CostMeters
-			expandedEdgesTotal++
-			beforeFromIdx, fromMiddlePoint := findMiddlePoint(edgeAsFromVertex.Geom)
-			fromGeomHalf := append([]GeoPoint{fromMiddlePoint}, edgeAsFromVertex.Geom[beforeFromIdx+1:len(edgeAsFromVertex.Geom)]...)
-			beforeToIdx, toMiddlePoint := findMiddlePoint(edgeAsToVertex.Geom)
-			toGeomHalf := append(make([]GeoPoint, 0, len(edgeAsToVertex.Geom[:beforeToIdx+1])+1), edgeAsToVertex.Geom[:beforeToIdx+1]...)
-			toGeomHalf = append(toGeomHalf, toMiddlePoint)
-			completedNewGeom := append(fromGeomHalf, toGeomHalf...)
-			expandedEdges = append(expandedEdges, ExpandedEdge{
-				ID:             expandedEdgesTotal,
-				Source:         edgeAsFromVertex.ID,
-				Target:         edgeAsToVertex.ID,
-				SourceOSMWayID: edgeAsFromVertex.WayID,
-				TargetOSMWayID: edgeAsToVertex.WayID,
-				SourceComponent: expandedEdgeComponent{
-					SourceNodeID: edgeAsFromVertex.SourceNodeID,
-					TargetNodeID: edgeAsFromVertex.TargetNodeID,
-				},
-				TargeComponent: expandedEdgeComponent{
-					SourceNodeID: edgeAsToVertex.SourceNodeID,
-					TargetNodeID: edgeAsToVertex.TargetNodeID,
-				},
-				CostMeters: (costMetersFromVertex + costMetersToVertex) / 2.0,
-				WasOneway:  edgeAsFromVertex.WasOneway,
-				Geom:       completedNewGeom,
-			})
+// scanOSMFiles Calls handler for every object of given type in given files of PBF-format (files are scanned in given order)
+func scanOSMFiles(fileNames []string, objectType osm.Type, handle func(obj osm.Object)) error {
+	for _, fileName := range fileNames {
+		err := scanOSMFile(fileName, objectType, handle)
+		if err != nil {
+			return errors.Wrap(err, fileName)
 		}
 	}
-	fmt.Printf("Done in %v\n", time.Since(st))
-	fmt.Printf("\tIgnored cycles: %d\n", cycles)
-	fmt.Printf("\tNumber of expanded edges: %d\n", expandedEdgesTotal)
+	return nil
+}
 
-	// @todo: work with maneuvers (restrictions)
-	fmt.Printf("Working with maneuvers (restrictions)...")
-	st = time.Now()
-	// Handling restrictions of "no" type
-	for i, k := range restrictions {
-		switch i {
-		case "no_left_turn", "no_right_turn", "no_straight_on":
-			// handle only way(from)-way(to)-node(via)
-			for j, v := range k {
-				if j.Type != "way" { // way(from)
-					continue
-				}
-				fromOSMWayID := osm.WayID(j.ID)
-				if _, ok := waysSeen[fromOSMWayID]; !ok {
-					continue
-				}
-				for n := range v {
-					if n.Type != "way" { // way(to)
-						continue
-					}
-					if v[n].Type != "node" { // node(via)
-						continue
-					}
-					toOSMWayID := osm.WayID(n.ID)
-					if _, ok := waysSeen[toOSMWayID]; !ok {
-						continue
-					}
-					// Delete restricted expanded edge
-					{
-						temp := expandedEdges[:0]
-						for _, expEdge := range expandedEdges {
-							if expEdge.SourceOSMWayID != fromOSMWayID || expEdge.TargetOSMWayID != toOSMWayID {
-								temp = append(temp, expEdge)
-							}
-						}
-						expandedEdges = temp
-					}
-				}
-			}
-			break
-		default:
-			// @todo: need to think about U-turns: "no_u_turn"
-			break
-		}
+// scanOSMFile Calls handler for every object of given type in file of PBF-format
+func scanOSMFile(fileName string, objectType osm.Type, handle func(obj osm.Object)) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return errors.Wrap(err, "File open")
 	}
-	// Handling restrictions of "only" type
-	for i, k := range restrictions {
-		switch i {
-		case "only_left_turn", "only_right_turn", "only_straight_on":
-			// handle only way(from)-way(to)-node(via)
-			for j, v := range k {
-				if j.Type != "way" { // way(from)
-					continue
-				}
-				fromOSMWayID := osm.WayID(j.ID)
-				if _, ok := waysSeen[fromOSMWayID]; !ok {
-					continue
-				}
-				for n := range v {
-					if n.Type != "way" { // way(to)
-						continue
-					}
-					if v[n].Type != "node" { // node(via)
-						continue
-					}
-					toOSMWayID := osm.WayID(n.ID)
-					if _, ok := waysSeen[toOSMWayID]; !ok {
-						continue
-					}
-					rvertexVia := v[n].ID
-					{
-						temp := expandedEdges[:0]
-						for _, expEdge := range expandedEdges {
-							if !(expEdge.SourceOSMWayID == fromOSMWayID && expEdge.TargetOSMWayID != toOSMWayID && expEdge.SourceComponent.TargetNodeID == osm.NodeID(rvertexVia)) {
-								temp = append(temp, expEdge)
-							}
-						}
-						expandedEdges = temp
-					}
-				}
-			}
-			break
-		default:
-			// @todo: need to think about U-turns: "no_u_turn"
-			break
+	defer f.Close()
+	scanner := osmpbf.New(context.Background(), f, 4)
+	defer scanner.Close()
+	for scanner.Scan() {
+		obj := scanner.Object()
+		if obj.ObjectID().Type() != objectType {
+			continue
 		}
-
+		handle(obj)
 	}
-
-	fmt.Printf("Done in %v\n", time.Since(st))
-	fmt.Printf("\tUpdated of expanded edges: %d\n", len(expandedEdges))
-	return expandedEdges, nil
+	return scanner.Err()
 }
@@ -0,0 +1,131 @@
+package osm2ch
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const postGISSRID = 4326
+
+// WritePostGISDumpFile Writes expanded graph into SQL file for PostgreSQL (see WritePostGISDump)
+func WritePostGISDumpFile(fname, tableName string, expandedEdges []ExpandedEdge, vertices []Vertex, shortcuts []Shortcut) error {
+	file, err := os.Create(fname)
+	if err != nil {
+		return errors.Wrap(err, "Can't create SQL file")
+	}
+	defer file.Close()
+	err = WritePostGISDump(file, tableName, expandedEdges, vertices, shortcuts)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// WritePostGISDump Writes expanded graph as SQL script for PostgreSQL with PostGIS extension (could be loaded via psql)
+/*
+	tableName - name of edges table. Vertices and shortcuts go to tables with suffixes "_vertices" and "_shortcuts"
+	(the same way as CSV files are named). Columns of tables are the same as columns of CSV files.
+	Data is loaded via COPY blocks, geometries are in EWKB (hex) with SRID 4326, expanded edges having less than two points get NULL geometry.
+	Primary keys and spatial indices are created after loading of data. Whole script runs in single transaction
+*/
+func WritePostGISDump(w io.Writer, tableName string, expandedEdges []ExpandedEdge, vertices []Vertex, shortcuts []Shortcut) error {
+	bw := bufio.NewWriter(w)
+	edgesTable := quotePostgresIdentifier(tableName)
+	verticesTable := quotePostgresIdentifier(tableName + "_vertices")
+	shortcutsTable := quotePostgresIdentifier(tableName + "_shortcuts")
+	formatFloat := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	fmt.Fprintf(bw, "-- Expanded graph prepared by osm2ch\n")
+	fmt.Fprintf(bw, "BEGIN;\n")
+	fmt.Fprintf(bw, "CREATE EXTENSION IF NOT EXISTS postgis;\n\n")
+
+	fmt.Fprintf(bw, "CREATE TABLE %s (\n", edgesTable)
+	fmt.Fprintf(bw, "\tfrom_vertex_id bigint NOT NULL,\n")
+	fmt.Fprintf(bw, "\tto_vertex_id bigint NOT NULL,\n")
+	fmt.Fprintf(bw, "\tweight double precision NOT NULL,\n")
+	fmt.Fprintf(bw, "\tgeom geometry(LineString, %d),\n", postGISSRID)
+	fmt.Fprintf(bw, "\twas_one_way boolean NOT NULL,\n")
+	fmt.Fprintf(bw, "\tedge_id bigint NOT NULL,\n")
+	fmt.Fprintf(bw, "\tosm_way_from bigint NOT NULL,\n")
+	fmt.Fprintf(bw, "\tosm_way_to bigint NOT NULL,\n")
+	fmt.Fprintf(bw, "\tosm_way_from_source_node bigint NOT NULL,\n")
+	fmt.Fprintf(bw, "\tosm_way_from_target_node bigint NOT NULL,\n")
+	fmt.Fprintf(bw, "\tosm_way_to_source_node bigint NOT NULL,\n")
+	fmt.Fprintf(bw, "\tosm_way_to_target_node bigint NOT NULL\n")
+	fmt.Fprintf(bw, ");\n")
+	fmt.Fprintf(bw, "COPY %s (from_vertex_id, to_vertex_id, weight, geom, was_one_way, edge_id, osm_way_from, osm_way_to, osm_way_from_source_node, osm_way_from_target_node, osm_way_to_source_node, osm_way_to_target_node) FROM stdin;\n", edgesTable)
+	for _, edge := range expandedEdges {
+		geom := `\N`
+		if len(edge.Geom) >= 2 {
+			geom = strings.ToUpper(hex.EncodeToString(prepareEWKB(prepareWKBLinestring(edge.Geom), postGISSRID)))
+		}
+		wasOneway := "f"
+		if edge.WasOneway {
+			wasOneway = "t"
+		}
+		fmt.Fprintf(bw, "%d\t%d\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			edge.Source, edge.Target, formatFloat(edge.CostMeters), geom, wasOneway, edge.ID,
+			edge.SourceOSMWayID, edge.TargetOSMWayID,
+			edge.SourceComponent.SourceNodeID, edge.SourceComponent.TargetNodeID,
+			edge.TargeComponent.SourceNodeID, edge.TargeComponent.TargetNodeID,
+		)
+	}
+	fmt.Fprintf(bw, "\\.\n\n")
+
+	fmt.Fprintf(bw, "CREATE TABLE %s (\n", verticesTable)
+	fmt.Fprintf(bw, "\tvertex_id bigint NOT NULL,\n")
+	fmt.Fprintf(bw, "\torder_pos bigint NOT NULL,\n")
+	fmt.Fprintf(bw, "\timportance integer NOT NULL,\n")
+	fmt.Fprintf(bw, "\tgeom geometry(Point, %d)\n", postGISSRID)
+	fmt.Fprintf(bw, ");\n")
+	fmt.Fprintf(bw, "COPY %s (vertex_id, order_pos, importance, geom) FROM stdin;\n", verticesTable)
+	for _, vertex := range vertices {
+		geom := strings.ToUpper(hex.EncodeToString(prepareEWKB(prepareWKBPoint(vertex.Geom), postGISSRID)))
+		fmt.Fprintf(bw, "%d\t%d\t%d\t%s\n", vertex.ID, vertex.OrderPos, vertex.Importance, geom)
+	}
+	fmt.Fprintf(bw, "\\.\n\n")
+
+	fmt.Fprintf(bw, "CREATE TABLE %s (\n", shortcutsTable)
+	fmt.Fprintf(bw, "\tfrom_vertex_id bigint NOT NULL,\n")
+	fmt.Fprintf(bw, "\tto_vertex_id bigint NOT NULL,\n")
+	fmt.Fprintf(bw, "\tweight double precision NOT NULL,\n")
+	fmt.Fprintf(bw, "\tvia_vertex_id bigint NOT NULL\n")
+	fmt.Fprintf(bw, ");\n")
+	fmt.Fprintf(bw, "COPY %s (from_vertex_id, to_vertex_id, weight, via_vertex_id) FROM stdin;\n", shortcutsTable)
+	for _, shortcut := range shortcuts {
+		fmt.Fprintf(bw, "%d\t%d\t%s\t%d\n", shortcut.From, shortcut.To, formatFloat(shortcut.Weight), shortcut.Via)
+	}
+	fmt.Fprintf(bw, "\\.\n\n")
+
+	fmt.Fprintf(bw, "ALTER TABLE %s ADD PRIMARY KEY (edge_id);\n", edgesTable)
+	fmt.Fprintf(bw, "ALTER TABLE %s ADD PRIMARY KEY (vertex_id);\n", verticesTable)
+	fmt.Fprintf(bw, "ALTER TABLE %s ADD PRIMARY KEY (from_vertex_id, to_vertex_id);\n", shortcutsTable)
+	fmt.Fprintf(bw, "CREATE INDEX ON %s (from_vertex_id);\n", edgesTable)
+	fmt.Fprintf(bw, "CREATE INDEX ON %s (to_vertex_id);\n", edgesTable)
+	fmt.Fprintf(bw, "CREATE INDEX ON %s USING GIST (geom);\n", edgesTable)
+	fmt.Fprintf(bw, "CREATE INDEX ON %s USING GIST (geom);\n", verticesTable)
+	fmt.Fprintf(bw, "ANALYZE %s;\n", edgesTable)
+	fmt.Fprintf(bw, "ANALYZE %s;\n", verticesTable)
+	fmt.Fprintf(bw, "ANALYZE %s;\n", shortcutsTable)
+	fmt.Fprintf(bw, "COMMIT;\n")
+
+	err := bw.Flush()
+	if err != nil {
+		return errors.Wrap(err, "Can't write SQL dump")
+	}
+	return nil
+}
+
+// quotePostgresIdentifier Returns identifier quoted for PostgreSQL
+func quotePostgresIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,47 @@
+package osm2ch
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWritePostGISDump(t *testing.T) {
+	expandedEdges := []ExpandedEdge{
+		{ID: 1, Source: 1, Target: 2, CostMeters: 0.25, WasOneway: true, Geom: []GeoPoint{{Lon: 1, Lat: 2}, {Lon: 3, Lat: 4}}},
+		{ID: 2, Source: 2, Target: 1, CostMeters: 0.5, Geom: []GeoPoint{{Lon: 3, Lat: 4}}},
+	}
+	vertices := []Vertex{{ID: 1, OrderPos: 1, Importance: 3, Geom: GeoPoint{Lon: 1, Lat: 2}}, {ID: 2, Geom: GeoPoint{Lon: 3, Lat: 4}}}
+	shortcuts := []Shortcut{{From: 1, To: 2, Via: 3, Weight: 0.125}}
+	buf := bytes.Buffer{}
+	err := WritePostGISDump(&buf, `my "graph"`, expandedEdges, vertices, shortcuts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := buf.String()
+	expectedLines := []string{
+		`CREATE TABLE "my ""graph""" (`,
+		`COPY "my ""graph""_vertices" (vertex_id, order_pos, importance, geom) FROM stdin;`,
+		"1\t2\t0.25\t0102000020E610000002000000000000000000F03F000000000000004000000000000008400000000000001040\tt\t1\t0\t0\t0\t0\t0\t0",
+		"2\t1\t0.5\t\\N\tf\t2\t0\t0\t0\t0\t0\t0",
+		"1\t1\t3\t0101000020E6100000000000000000F03F0000000000000040",
+		"1\t2\t0.125\t3",
+		`ALTER TABLE "my ""graph""_shortcuts" ADD PRIMARY KEY (from_vertex_id, to_vertex_id);`,
+	}
+	lines := strings.Split(dump, "\n")
+	for _, expected := range expectedLines {
+		found := false
+		for _, line := range lines {
+			if line == expected {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Dump should contain line '%s'", expected)
+		}
+	}
+	if strings.Count(dump, "\n\\.\n") != 3 {
+		t.Errorf("Dump should contain 3 COPY blocks")
+	}
+}
@@ -0,0 +1,98 @@
+package osm2ch
+
+import (
+	"github.com/paulmach/osm"
+)
+
+// applyRestrictions Deletes expanded edges which are prohibited by restrictions. Returns filtered slice (it reuses memory of given one)
+/*
+	Handles only way(from)-way(to)-node(via) restrictions where both ways are known (waysSeen)
+*/
+func applyRestrictions(expandedEdges []ExpandedEdge, restrictions map[string]map[restrictionComponent]map[restrictionComponent]restrictionComponent, waysSeen map[osm.WayID]struct{}) []ExpandedEdge {
+	// Handling restrictions of "no" type
+	for i, k := range restrictions {
+		switch i {
+		case "no_left_turn", "no_right_turn", "no_straight_on":
+			// handle only way(from)-way(to)-node(via)
+			for j, v := range k {
+				if j.Type != "way" { // way(from)
+					continue
+				}
+				fromOSMWayID := osm.WayID(j.ID)
+				if _, ok := waysSeen[fromOSMWayID]; !ok {
+					continue
+				}
+				for n := range v {
+					if n.Type != "way" { // way(to)
+						continue
+					}
+					if v[n].Type != "node" { // node(via)
+						continue
+					}
+					toOSMWayID := osm.WayID(n.ID)
+					if _, ok := waysSeen[toOSMWayID]; !ok {
+						continue
+					}
+					// Delete restricted expanded edge
+					{
+						temp := expandedEdges[:0]
+						for _, expEdge := range expandedEdges {
+							if expEdge.SourceOSMWayID != fromOSMWayID || expEdge.TargetOSMWayID != toOSMWayID {
+								temp = append(temp, expEdge)
+							}
+						}
+						expandedEdges = temp
+					}
+				}
+			}
+			break
+		default:
+			// @todo: need to think about U-turns: "no_u_turn"
+			break
+		}
+	}
+	// Handling restrictions of "only" type
+	for i, k := range restrictions {
+		switch i {
+		case "only_left_turn", "only_right_turn", "only_straight_on":
+			// handle only way(from)-way(to)-node(via)
+			for j, v := range k {
+				if j.Type != "way" { // way(from)
+					continue
+				}
+				fromOSMWayID := osm.WayID(j.ID)
+				if _, ok := waysSeen[fromOSMWayID]; !ok {
+					continue
+				}
+				for n := range v {
+					if n.Type != "way" { // way(to)
+						continue
+					}
+					if v[n].Type != "node" { // node(via)
+						continue
+					}
+					toOSMWayID := osm.WayID(n.ID)
+					if _, ok := waysSeen[toOSMWayID]; !ok {
+						continue
+					}
+					rvertexVia := v[n].ID
+					{
+						temp := expandedEdges[:0]
+						for _, expEdge := range expandedEdges {
+							if !(expEdge.SourceOSMWayID == fromOSMWayID && expEdge.TargetOSMWayID != toOSMWayID && expEdge.SourceComponent.TargetNodeID == osm.NodeID(rvertexVia)) {
+								temp = append(temp, expEdge)
+							}
+						}
+						expandedEdges = temp
+					}
+				}
+			}
+			break
+		default:
+			// @todo: need to think about U-turns: "no_u_turn"
+			break
+		}
+
+	}
+	return expandedEdges
+}
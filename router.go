@@ -0,0 +1,238 @@
+package osm2ch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/LdDl/ch"
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// Router Answers shortest path queries over expanded graph (loaded from files written by osm2ch or prepared in-process)
+/*
+	Router is safe for concurrent queries
+*/
+type Router struct {
+	graph      *ch.Graph
+	contracted bool
+	vertices   []Vertex
+	// Indices of expanded edges by source and target vertices
+	edgesIdx map[[2]int64]int
+	edges    []ExpandedEdge
+	// Indices of vertices by their IDs and indices of expanded edges outgoing from every vertex
+	verticesIdx map[int64]int
+	outEdges    [][]int
+	// Spatial indices over geometries of vertices and expanded edges
+	verticesIndex *SpatialIndex
+	edgesIndex    *SpatialIndex
+}
+
+// Route Shortest path between two vertices of expanded graph
+type Route struct {
+	// Cost of route in units of weights of graph
+	Cost float64
+	// Vertices of expanded graph (IDs of vertices)
+	Vertices []int64
+	// Expanded edges between consecutive vertices
+	ExpandedEdges []ExpandedEdge
+	// Concatenated geometry of expanded edges
+	Geom []GeoPoint
+}
+
+// NewRouter Prepares router for given expanded edges, vertices and shortcuts
+/*
+	If graph has not been contracted (all vertices have the same order position) then shortest paths are found by
+	vanilla Dijkstra's algorithm instead of contraction hierarchies
+*/
+func NewRouter(expandedEdges []ExpandedEdge, vertices []Vertex, shortcuts []Shortcut) (*Router, error) {
+	graph, err := prepareContractedGraph(expandedEdges, vertices, shortcuts)
+	if err != nil {
+		return nil, err
+	}
+	router := Router{
+		graph:    graph,
+		vertices: vertices,
+		edgesIdx: make(map[[2]int64]int, len(expandedEdges)),
+		edges:    expandedEdges,
+	}
+	for _, vertex := range vertices {
+		if vertex.OrderPos != vertices[0].OrderPos {
+			router.contracted = true
+			break
+		}
+	}
+	router.verticesIdx = make(map[int64]int, len(vertices))
+	router.outEdges = make([][]int, len(vertices))
+	verticesGeoms := make([][]GeoPoint, len(vertices))
+	for i := range vertices {
+		router.verticesIdx[vertices[i].ID] = i
+		// Vertices with empty geometry are not indexed
+		if vertices[i].Geom != (GeoPoint{}) {
+			verticesGeoms[i] = []GeoPoint{vertices[i].Geom}
+		}
+	}
+	router.verticesIndex = NewSpatialIndex(verticesGeoms)
+	router.edgesIndex = NewExpandedEdgesIndex(expandedEdges)
+	for i, edge := range expandedEdges {
+		// Vertices of expanded edges are checked while preparing graph
+		source := router.verticesIdx[int64(edge.Source)]
+		router.outEdges[source] = append(router.outEdges[source], i)
+		key := [2]int64{int64(edge.Source), int64(edge.Target)}
+		// Keep the cheapest one if there are parallel expanded edges
+		if j, ok := router.edgesIdx[key]; ok && expandedEdges[j].CostMeters <= edge.CostMeters {
+			continue
+		}
+		router.edgesIdx[key] = i
+	}
+	return &router, nil
+}
+
+// NewRouterFromCSVFiles Prepares router for graph from CSV files written by osm2ch (see ReadCSVFiles)
+func NewRouterFromCSVFiles(edgesFname, verticesFname, shortcutsFname string) (*Router, error) {
+	expandedEdges, vertices, shortcuts, err := ReadCSVFiles(edgesFname, verticesFname, shortcutsFname)
+	if err != nil {
+		return nil, err
+	}
+	return NewRouter(expandedEdges, vertices, shortcuts)
+}
+
+// Graph Returns underlying graph
+func (router *Router) Graph() *ch.Graph {
+	return router.graph
+}
+
+// Vertices Returns vertices of graph
+func (router *Router) Vertices() []Vertex {
+	return router.vertices
+}
+
+// ExpandedEdges Returns expanded edges of graph
+func (router *Router) ExpandedEdges() []ExpandedEdge {
+	return router.edges
+}
+
+// NearestVertex Returns vertex which is the nearest to given point and distance to it (kilometers)
+/*
+	Vertices with empty geometry are ignored. False is returned if there are no vertices with geometry
+*/
+func (router *Router) NearestVertex(pt GeoPoint) (Vertex, float64, bool) {
+	nearest, ok := router.verticesIndex.Nearest(pt)
+	if !ok {
+		return Vertex{}, 0, false
+	}
+	return router.vertices[nearest.Index], nearest.Distance, true
+}
+
+// NearestExpandedEdge Returns expanded edge which geometry is the nearest to given point and projection of point onto it
+/*
+	False is returned if there are no expanded edges with geometry
+*/
+func (router *Router) NearestExpandedEdge(pt GeoPoint) (ExpandedEdge, NearestGeometry, bool) {
+	nearest, ok := router.edgesIndex.Nearest(pt)
+	if !ok {
+		return ExpandedEdge{}, NearestGeometry{}, false
+	}
+	return router.edges[nearest.Index], nearest, true
+}
+
+// ShortestPath Returns shortest path between two vertices. False is returned if there is no path
+func (router *Router) ShortestPath(source, target int64) (Route, bool) {
+	var cost float64
+	var path []int64
+	if router.contracted {
+		cost, path = router.graph.ShortestPath(source, target)
+	} else {
+		cost, path = router.graph.VanillaShortestPath(source, target)
+	}
+	if cost < 0 || len(path) == 0 {
+		return Route{}, false
+	}
+	return router.prepareRoute(cost, path), true
+}
+
+// prepareRoute Returns route for path of vertices: expanded edges and geometry
+func (router *Router) prepareRoute(cost float64, path []int64) Route {
+	route := Route{
+		Cost:          cost,
+		Vertices:      path,
+		ExpandedEdges: make([]ExpandedEdge, 0, len(path)),
+		Geom:          []GeoPoint{},
+	}
+	for i := 1; i < len(path); i++ {
+		idx, ok := router.edgesIdx[[2]int64{path[i-1], path[i]}]
+		if !ok {
+			continue
+		}
+		edge := router.edges[idx]
+		route.ExpandedEdges = append(route.ExpandedEdges, edge)
+		for _, pt := range edge.Geom {
+			// Expanded edges are joined in the middle of OSM edge, so the first point repeats the last one of previous edge
+			// (and the middle point could coincide with node of OSM edge)
+			if len(route.Geom) > 0 && route.Geom[len(route.Geom)-1] == pt {
+				continue
+			}
+			route.Geom = append(route.Geom, pt)
+		}
+	}
+	return route
+}
+
+// Route Returns shortest path between vertices which are the nearest to given points
+func (router *Router) Route(from, to GeoPoint) (Route, error) {
+	source, _, ok := router.NearestVertex(from)
+	if !ok {
+		return Route{}, fmt.Errorf("There are no vertices with geometry in graph")
+	}
+	target, _, _ := router.NearestVertex(to)
+	route, ok := router.ShortestPath(source.ID, target.ID)
+	if !ok {
+		return Route{}, fmt.Errorf("There is no path between vertices %d and %d", source.ID, target.ID)
+	}
+	return route, nil
+}
+
+// GeoJSONFeature Returns route as LineString feature
+/*
+	Properties: length (cost of route in units of weights of graph), vertices (IDs of vertices of expanded graph),
+	source_vertex_id, target_vertex_id. Route having less than two points gets null geometry
+*/
+func (route Route) GeoJSONFeature() *geojson.Feature {
+	var feature *geojson.Feature
+	if len(route.Geom) >= 2 {
+		pts2d := make([][]float64, len(route.Geom))
+		for i := range route.Geom {
+			pts2d[i] = []float64{route.Geom[i].Lon, route.Geom[i].Lat}
+		}
+		feature = geojson.NewLineStringFeature(pts2d)
+	} else {
+		feature = geojson.NewFeature(nil)
+	}
+	feature.SetProperty("length", route.Cost)
+	feature.SetProperty("vertices", route.Vertices)
+	if len(route.Vertices) > 0 {
+		feature.SetProperty("source_vertex_id", route.Vertices[0])
+		feature.SetProperty("target_vertex_id", route.Vertices[len(route.Vertices)-1])
+	}
+	return feature
+}
+
+// ParseGeoPoint Parses point from 'lon,lat' string
+func ParseGeoPoint(str string) (GeoPoint, error) {
+	parts := strings.Split(str, ",")
+	if len(parts) != 2 {
+		return GeoPoint{}, fmt.Errorf("Point should be provided as 'lon,lat', but got '%s'", str)
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return GeoPoint{}, fmt.Errorf("Bad longitude of point '%s'", str)
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return GeoPoint{}, fmt.Errorf("Bad latitude of point '%s'", str)
+	}
+	if lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+		return GeoPoint{}, fmt.Errorf("Point '%s' is out of range of longitude and latitude", str)
+	}
+	return GeoPoint{Lon: lon, Lat: lat}, nil
+}
@@ -0,0 +1,76 @@
+package osm2ch
+
+import (
+	"math"
+	"testing"
+)
+
+// prepareTestGraph Returns expanded edges of grid 5x5 (see prepareGridEdges), its vertices and shortcuts (empty if contraction is not needed)
+func prepareTestGraph(t *testing.T, contract bool) ([]ExpandedEdge, []Vertex, []Shortcut) {
+	// The second value is number of ignored u-turns, not an error
+	expandedEdges, _ := expandEdges(prepareGridEdges(5), expansionOptions{workers: 1})
+	graph, err := PrepareGraph(expandedEdges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shortcuts := []Shortcut{}
+	if contract {
+		graph.PrepareContractionHierarchies()
+		shortcuts, err = PrepareShortcuts(graph)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return expandedEdges, PrepareVertices(graph, expandedEdges), shortcuts
+}
+
+// prepareTestRouter Returns router for graph of prepareTestGraph
+func prepareTestRouter(t *testing.T, contract bool) *Router {
+	router, err := NewRouter(prepareTestGraph(t, contract))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func TestRouter(t *testing.T) {
+	router := prepareTestRouter(t, true)
+	vertices := router.Vertices()
+	// The same graph without contraction is queried by vanilla Dijkstra's algorithm
+	vanillaRouter := prepareTestRouter(t, false)
+	for i := 0; i < len(vertices); i += 7 {
+		for j := 0; j < len(vertices); j += 5 {
+			route, ok := router.ShortestPath(vertices[i].ID, vertices[j].ID)
+			vanillaRoute, vanillaOk := vanillaRouter.ShortestPath(vertices[i].ID, vertices[j].ID)
+			if ok != vanillaOk || math.Abs(route.Cost-vanillaRoute.Cost) > 1e-9 {
+				t.Errorf("Cost of path between %d and %d should be %f, but got %f", vertices[i].ID, vertices[j].ID, vanillaRoute.Cost, route.Cost)
+			}
+			if !ok || i == j {
+				continue
+			}
+			if len(route.ExpandedEdges) != len(route.Vertices)-1 {
+				t.Errorf("Route between %d and %d should have %d expanded edges, but got %d", vertices[i].ID, vertices[j].ID, len(route.Vertices)-1, len(route.ExpandedEdges))
+			}
+			cost := 0.0
+			for _, edge := range route.ExpandedEdges {
+				cost += edge.CostMeters
+			}
+			if math.Abs(cost-route.Cost) > 1e-9 {
+				t.Errorf("Cost of route between %d and %d should be sum of costs of expanded edges %f, but got %f", vertices[i].ID, vertices[j].ID, cost, route.Cost)
+			}
+			if route.Geom[0] != route.ExpandedEdges[0].Geom[0] {
+				t.Errorf("Route between %d and %d should start at the first point of the first expanded edge", vertices[i].ID, vertices[j].ID)
+			}
+		}
+	}
+
+	target := vertices[len(vertices)-1]
+	route, err := router.Route(GeoPoint{Lon: vertices[0].Geom.Lon + 1e-6, Lat: vertices[0].Geom.Lat}, target.Geom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Vertices of opposite directions of OSM edge share geometry, so check points only
+	if route.Geom[0] != vertices[0].Geom || route.Geom[len(route.Geom)-1] != target.Geom {
+		t.Errorf("Route should go from %v to %v, but got %v", vertices[0].Geom, target.Geom, route.Geom)
+	}
+}
@@ -0,0 +1,160 @@
+package osm2ch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// NewHTTPHandler Returns HTTP handler which exposes router
+/*
+	Endpoints (GET):
+	/health - {"status": "ok", "vertices": <number of vertices>, "edges": <number of expanded edges>}
+	/nearest?point=lon,lat - nearest vertex as GeoJSON Point feature with properties vertex_id, order_pos, importance, distance (meters)
+	/nearest_edge?point=lon,lat - projection of point onto the nearest expanded edge as GeoJSON Point feature with properties edge_id,
+	from_vertex_id, to_vertex_id, offset (fraction of length of edge geometry), distance (meters)
+	/route?from=lon,lat&to=lon,lat - shortest path between nearest vertices as GeoJSON LineString feature (see Route.GeoJSONFeature)
+	/isochrones?point=lon,lat&limits=l1,l2,...&cell=meters - isochrones for nearest vertex as GeoJSON FeatureCollection (see Router.Isochrones).
+	Size of cell is 100 meters by default (see IsochroneMinCellSize and IsochroneMaxCells for bounds), number of limits is bounded by IsochroneMaxLimits
+	Errors are returned as {"error": "<message>"} with status 400 for bad parameters (including too large grid of isochrones) and 404 if there is no path
+*/
+func NewHTTPHandler(router *Router) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(w, http.StatusOK, map[string]interface{}{
+			"status":   "ok",
+			"vertices": len(router.Vertices()),
+			"edges":    len(router.ExpandedEdges()),
+		})
+	})
+	mux.HandleFunc("/nearest", func(w http.ResponseWriter, r *http.Request) {
+		pt, err := ParseGeoPoint(r.URL.Query().Get("point"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		vertex, distance, ok := router.NearestVertex(pt)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, fmt.Errorf("There are no vertices with geometry in graph"))
+			return
+		}
+		feature := geojson.NewPointFeature([]float64{vertex.Geom.Lon, vertex.Geom.Lat})
+		feature.ID = vertex.ID
+		feature.SetProperty("vertex_id", vertex.ID)
+		feature.SetProperty("order_pos", vertex.OrderPos)
+		feature.SetProperty("importance", vertex.Importance)
+		feature.SetProperty("distance", distance*1000.0)
+		writeJSONResponse(w, http.StatusOK, feature)
+	})
+	mux.HandleFunc("/nearest_edge", func(w http.ResponseWriter, r *http.Request) {
+		pt, err := ParseGeoPoint(r.URL.Query().Get("point"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		edge, nearest, ok := router.NearestExpandedEdge(pt)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, fmt.Errorf("There are no expanded edges with geometry in graph"))
+			return
+		}
+		feature := geojson.NewPointFeature([]float64{nearest.Point.Lon, nearest.Point.Lat})
+		feature.ID = edge.ID
+		feature.SetProperty("edge_id", edge.ID)
+		feature.SetProperty("from_vertex_id", edge.Source)
+		feature.SetProperty("to_vertex_id", edge.Target)
+		feature.SetProperty("offset", nearest.Offset)
+		feature.SetProperty("distance", nearest.Distance*1000.0)
+		writeJSONResponse(w, http.StatusOK, feature)
+	})
+	mux.HandleFunc("/route", func(w http.ResponseWriter, r *http.Request) {
+		from, err := ParseGeoPoint(r.URL.Query().Get("from"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		to, err := ParseGeoPoint(r.URL.Query().Get("to"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		route, err := router.Route(from, to)
+		if err != nil {
+			writeJSONError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, route.GeoJSONFeature())
+	})
+	mux.HandleFunc("/isochrones", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		pt, err := ParseGeoPoint(query.Get("point"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		limitsStrs := strings.Split(query.Get("limits"), ",")
+		if len(limitsStrs) > IsochroneMaxLimits {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("Number of limits should not exceed %d, but got %d", IsochroneMaxLimits, len(limitsStrs)))
+			return
+		}
+		limits := []float64{}
+		for _, str := range limitsStrs {
+			limit, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+			if err != nil || limit < 0 {
+				writeJSONError(w, http.StatusBadRequest, fmt.Errorf("Limits should be non-negative numbers separated by commas, but got '%s'", query.Get("limits")))
+				return
+			}
+			limits = append(limits, limit)
+		}
+		cellSize := 100.0
+		if str := query.Get("cell"); str != "" {
+			cellSize, err = strconv.ParseFloat(str, 64)
+			if err != nil || !(cellSize >= IsochroneMinCellSize) {
+				writeJSONError(w, http.StatusBadRequest, fmt.Errorf("Size of cell should be number not less than %g, but got '%s'", IsochroneMinCellSize, str))
+				return
+			}
+		}
+		vertex, _, ok := router.NearestVertex(pt)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, fmt.Errorf("There are no vertices with geometry in graph"))
+			return
+		}
+		isochrones, err := router.Isochrones(vertex.ID, limits, cellSize)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, PrepareIsochronesGeoJSON(isochrones))
+	})
+	return onlyGET(mux)
+}
+
+// onlyGET Rejects requests with methods other than GET and HEAD
+func onlyGET(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method %s is not allowed", r.Method))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, value interface{}) {
+	contentType := "application/json"
+	switch value.(type) {
+	case *geojson.Feature, *geojson.FeatureCollection:
+		contentType = "application/geo+json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSONResponse(w, status, map[string]string{"error": err.Error()})
+}
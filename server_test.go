@@ -0,0 +1,142 @@
+package osm2ch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	router := prepareTestRouter(t, true)
+	vertices, expandedEdges := router.Vertices(), router.ExpandedEdges()
+	server := httptest.NewServer(NewHTTPHandler(router))
+	defer server.Close()
+
+	get := func(path string, expectedStatus int, value interface{}) {
+		getJSON(t, server.URL+path, expectedStatus, value)
+	}
+
+	health := map[string]interface{}{}
+	get("/health", http.StatusOK, &health)
+	if health["status"] != "ok" || health["vertices"] != float64(len(vertices)) || health["edges"] != float64(len(expandedEdges)) {
+		t.Errorf("Bad health response: %v", health)
+	}
+
+	source, target := vertices[0], vertices[len(vertices)-1]
+	nearest := geojson.Feature{}
+	get(fmt.Sprintf("/nearest?point=%v,%v", source.Geom.Lon, source.Geom.Lat), http.StatusOK, &nearest)
+	if !nearest.Geometry.IsPoint() || nearest.Geometry.Point[0] != source.Geom.Lon || nearest.Properties["distance"].(float64) > 1 {
+		t.Errorf("Bad nearest vertex: %+v", nearest)
+	}
+
+	edge := geojson.Feature{}
+	get(fmt.Sprintf("/nearest_edge?point=%v,%v", source.Geom.Lon, source.Geom.Lat+0.0001), http.StatusOK, &edge)
+	if !edge.Geometry.IsPoint() || edge.Properties["edge_id"] == nil || edge.Properties["offset"].(float64) < 0 || edge.Properties["offset"].(float64) > 1 {
+		t.Errorf("Bad nearest edge: %+v", edge)
+	}
+
+	route := geojson.Feature{}
+	get(fmt.Sprintf("/route?from=%v,%v&to=%v,%v", source.Geom.Lon, source.Geom.Lat, target.Geom.Lon, target.Geom.Lat), http.StatusOK, &route)
+	expected, err := router.Route(source.Geom, target.Geom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !route.Geometry.IsLineString() || len(route.Geometry.LineString) != len(expected.Geom) || route.Properties["length"] != expected.Cost {
+		t.Errorf("Bad route: %+v", route)
+	}
+
+	isochrones := geojson.FeatureCollection{}
+	get(fmt.Sprintf("/isochrones?point=%v,%v&limits=0.2,0.4&cell=50", source.Geom.Lon, source.Geom.Lat), http.StatusOK, &isochrones)
+	if len(isochrones.Features) != 2 || !isochrones.Features[0].Geometry.IsMultiPolygon() || isochrones.Features[1].Properties["limit"] != 0.4 {
+		t.Errorf("Bad isochrones: %+v", isochrones)
+	}
+
+	badPaths := []string{
+		"/route?from=1,2", "/nearest", "/nearest?point=abc", "/nearest?point=37.6", "/nearest_edge?point=37.6,x",
+		fmt.Sprintf("/isochrones?point=%v,%v&limits=0.2&cell=5", source.Geom.Lon, source.Geom.Lat),
+		fmt.Sprintf("/isochrones?point=%v,%v&limits=%s", source.Geom.Lon, source.Geom.Lat, strings.Repeat("0.1,", IsochroneMaxLimits)+"0.1"),
+	}
+	for _, path := range badPaths {
+		apiErr := map[string]string{}
+		get(path, http.StatusBadRequest, &apiErr)
+		if apiErr["error"] == "" {
+			t.Errorf("Error message should be provided for bad request '%s'", path)
+		}
+	}
+
+	// Too large grid of isochrones
+	longEdgesServer := httptest.NewServer(NewHTTPHandler(prepareLongEdgesRouter(t)))
+	defer longEdgesServer.Close()
+	apiErr := map[string]string{}
+	getJSON(t, longEdgesServer.URL+"/isochrones?point=37,55&limits=1000&cell=10", http.StatusBadRequest, &apiErr)
+	if apiErr["error"] == "" {
+		t.Errorf("Error message should be provided for too large grid of isochrones")
+	}
+
+	resp, err := http.Post(server.URL+"/health", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Status of POST request should be %d, but got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}
+
+func TestHTTPHandlerNotFound(t *testing.T) {
+	// Two disconnected expanded edges: there is no path between them
+	expandedEdges := []ExpandedEdge{
+		{ID: 1, Source: 1, Target: 2, CostMeters: 1, Geom: []GeoPoint{{Lon: 37.6, Lat: 55.7}, {Lon: 37.601, Lat: 55.7}}},
+		{ID: 2, Source: 3, Target: 4, CostMeters: 1, Geom: []GeoPoint{{Lon: 37.7, Lat: 55.7}, {Lon: 37.701, Lat: 55.7}}},
+	}
+	graph, err := PrepareGraph(expandedEdges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, err := NewRouter(expandedEdges, PrepareVertices(graph, expandedEdges), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(NewHTTPHandler(router))
+	defer server.Close()
+	apiErr := map[string]string{}
+	getJSON(t, server.URL+"/route?from=37.6,55.7&to=37.7,55.7", http.StatusNotFound, &apiErr)
+	if apiErr["error"] == "" {
+		t.Errorf("Error message should be provided if there is no path")
+	}
+
+	emptyRouter, err := NewRouter([]ExpandedEdge{}, []Vertex{}, []Shortcut{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	emptyServer := httptest.NewServer(NewHTTPHandler(emptyRouter))
+	defer emptyServer.Close()
+	for _, path := range []string{"/nearest?point=37.6,55.7", "/nearest_edge?point=37.6,55.7", "/route?from=37.6,55.7&to=37.7,55.7", "/isochrones?point=37.6,55.7&limits=1"} {
+		apiErr := map[string]string{}
+		getJSON(t, emptyServer.URL+path, http.StatusNotFound, &apiErr)
+		if apiErr["error"] == "" {
+			t.Errorf("Error message should be provided for '%s' if graph has no vertices", path)
+		}
+	}
+}
+
+// getJSON Sends GET request, checks status of response and decodes its body
+func getJSON(t *testing.T, url string, expectedStatus int, value interface{}) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != expectedStatus {
+		t.Fatalf("Status of '%s' should be %d, but got %d", url, expectedStatus, resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
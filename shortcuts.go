@@ -0,0 +1,97 @@
+package osm2ch
+
+import (
+	"encoding/csv"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/LdDl/ch"
+	"github.com/pkg/errors"
+)
+
+// Shortcut represents shortcut between two vertices prepared by contraction hierarchies
+type Shortcut struct {
+	From   int64
+	To     int64
+	Via    int64
+	Weight float64
+}
+
+// PrepareShortcuts Returns shortcuts of contracted graph ordered by source and target vertices
+/*
+	Shortcuts are stored in unexported map of ch.Graph, so the only way to get them is to export them via
+	ExportShortcutsToFile (into temporary file) and read back. Iteration over map is random, therefore sorting is needed
+	to get reproducible output.
+*/
+func PrepareShortcuts(graph *ch.Graph) ([]Shortcut, error) {
+	tmpFile, err := ioutil.TempFile("", "osm2ch_shortcuts_*.csv")
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't create temporary file for shortcuts")
+	}
+	tmpFileName := tmpFile.Name()
+	tmpFile.Close()
+	defer os.Remove(tmpFileName)
+
+	err = graph.ExportShortcutsToFile(tmpFileName)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't export shortcuts")
+	}
+	file, err := os.Open(tmpFileName)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't open temporary file for shortcuts")
+	}
+	defer file.Close()
+	shortcuts, err := readShortcuts(file)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(shortcuts, func(i, j int) bool {
+		if shortcuts[i].From == shortcuts[j].From {
+			return shortcuts[i].To < shortcuts[j].To
+		}
+		return shortcuts[i].From < shortcuts[j].From
+	})
+	return shortcuts, nil
+}
+
+// readShortcuts Reads shortcuts from CSV-file with header: from_vertex_id;to_vertex_id;weight;via_vertex_id
+func readShortcuts(r io.Reader) ([]Shortcut, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = ';'
+	// Skip header of CSV-file
+	_, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't read header of shortcuts file")
+	}
+	shortcuts := []Shortcut{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Can't read shortcut")
+		}
+		from, err := strconv.ParseInt(record[0], 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "Can't parse source vertex of shortcut")
+		}
+		to, err := strconv.ParseInt(record[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "Can't parse target vertex of shortcut")
+		}
+		weight, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "Can't parse weight of shortcut")
+		}
+		via, err := strconv.ParseInt(record[3], 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "Can't parse via vertex of shortcut")
+		}
+		shortcuts = append(shortcuts, Shortcut{From: from, To: to, Via: via, Weight: weight})
+	}
+	return shortcuts, nil
+}
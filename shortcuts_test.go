@@ -0,0 +1,37 @@
+package osm2ch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrepareShortcutsReproducible(t *testing.T) {
+	expandedEdges, _ := expandEdges(prepareGridEdges(6), expansionOptions{workers: 2})
+	contract := func() []Shortcut {
+		graph, err := PrepareGraph(expandedEdges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		graph.PrepareContractionHierarchies()
+		shortcuts, err := PrepareShortcuts(graph)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return shortcuts
+	}
+	shortcuts := contract()
+	if len(shortcuts) == 0 {
+		t.Fatal("Contraction of grid should give shortcuts")
+	}
+	for i := 1; i < len(shortcuts); i++ {
+		prev, cur := shortcuts[i-1], shortcuts[i]
+		if prev.From > cur.From || (prev.From == cur.From && prev.To >= cur.To) {
+			t.Fatalf("Shortcuts should be ordered by source and target, but %+v goes before %+v", prev, cur)
+		}
+	}
+	for run := 0; run < 3; run++ {
+		if again := contract(); !reflect.DeepEqual(shortcuts, again) {
+			t.Fatalf("Shortcuts of the same graph should be the same on every contraction (run %d)", run)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package osm2ch
+
+import (
+	"container/heap"
+	"math"
+)
+
+const (
+	// Maximum number of children of node of R-tree (the same as for FlatGeobuf index)
+	spatialIndexNodeSize = 16
+)
+
+// SpatialIndex Packed Hilbert R-tree over geometries (lines or points) for nearest-geometry search
+/*
+	Index is immutable, so it is safe for concurrent queries. Distances are compared in equirectangular projection
+	centered at query point, which is accurate enough for snapping of coordinates to road graph
+*/
+type SpatialIndex struct {
+	geoms [][]GeoPoint
+	// Leaves (in Hilbert order) go first, then inner nodes level by level. The last node is the root
+	boxes []boundingBox
+	// For leaf: index of geometry. For inner node: index of its first child
+	refs []int
+	// For inner node: index after its last child
+	ends      []int
+	leavesNum int
+}
+
+// NearestGeometry Result of nearest-geometry search
+type NearestGeometry struct {
+	// Index of geometry (e.g. edge) in slice which index has been built for
+	Index int
+	// Projection of query point onto geometry
+	Point GeoPoint
+	// Index of segment of geometry containing projected point (the first point of segment)
+	Segment int
+	// Fraction of length of geometry from its start to projected point, [0; 1]
+	Offset float64
+	// Distance from query point to projected point (kilometers)
+	Distance float64
+}
+
+// NewSpatialIndex Builds index over given geometries. Empty geometries are skipped
+func NewSpatialIndex(geoms [][]GeoPoint) *SpatialIndex {
+	index := SpatialIndex{
+		geoms: geoms,
+	}
+	ids := make([]int, 0, len(geoms))
+	boxes := make([]boundingBox, 0, len(geoms))
+	for i, geom := range geoms {
+		if len(geom) == 0 {
+			continue
+		}
+		ids = append(ids, i)
+		boxes = append(boxes, lineBoundingBox(geom))
+	}
+	index.leavesNum = len(ids)
+	for _, idx := range hilbertOrder(boxes) {
+		index.boxes = append(index.boxes, boxes[idx])
+		index.refs = append(index.refs, ids[idx])
+		index.ends = append(index.ends, 0)
+	}
+	for levelStart, levelEnd := 0, len(index.boxes); levelEnd-levelStart > 1; levelStart, levelEnd = levelEnd, len(index.boxes) {
+		for child := levelStart; child < levelEnd; child += spatialIndexNodeSize {
+			end := minInt(child+spatialIndexNodeSize, levelEnd)
+			bbox := emptyBoundingBox()
+			for _, childBox := range index.boxes[child:end] {
+				bbox.extend(childBox)
+			}
+			index.boxes = append(index.boxes, bbox)
+			index.refs = append(index.refs, child)
+			index.ends = append(index.ends, end)
+		}
+	}
+	return &index
+}
+
+// NewExpandedEdgesIndex Builds index over geometries of expanded edges. Index of result is index of expanded edge in slice
+func NewExpandedEdgesIndex(expandedEdges []ExpandedEdge) *SpatialIndex {
+	geoms := make([][]GeoPoint, len(expandedEdges))
+	for i := range expandedEdges {
+		geoms[i] = expandedEdges[i].Geom
+	}
+	return NewSpatialIndex(geoms)
+}
+
+// NewEdgesIndex Builds index over geometries of edges. Index of result is index of edge in slice
+func NewEdgesIndex(edges []Edge) *SpatialIndex {
+	geoms := make([][]GeoPoint, len(edges))
+	for i := range edges {
+		geoms[i] = edges[i].Geom
+	}
+	return NewSpatialIndex(geoms)
+}
+
+// Len Returns number of indexed (non-empty) geometries
+func (index *SpatialIndex) Len() int {
+	return index.leavesNum
+}
+
+// Nearest Returns geometry which is the nearest to given point. False is returned if index is empty
+func (index *SpatialIndex) Nearest(pt GeoPoint) (NearestGeometry, bool) {
+	found := index.NearestN(pt, 1)
+	if len(found) == 0 {
+		return NearestGeometry{}, false
+	}
+	return found[0], true
+}
+
+// NearestN Returns up to n geometries which are the nearest to given point (sorted by distance)
+func (index *SpatialIndex) NearestN(pt GeoPoint, n int) []NearestGeometry {
+	if index.leavesNum == 0 || n <= 0 {
+		return nil
+	}
+	lonScale := math.Cos(degreesToRadians(pt.Lat))
+	queue := &spatialIndexQueue{}
+	root := len(index.boxes) - 1
+	heap.Push(queue, spatialIndexQueueItem{node: root, distance: index.boxes[root].planeDistance(pt, lonScale)})
+	found := []NearestGeometry{}
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(spatialIndexQueueItem)
+		switch {
+		case item.result != nil:
+			// Exact distance is not less than distances of boxes remaining in queue
+			found = append(found, *item.result)
+			if len(found) == n {
+				return found
+			}
+		case item.node < index.leavesNum:
+			result, distance := projectOntoLine(pt, index.geoms[index.refs[item.node]], lonScale)
+			result.Index = index.refs[item.node]
+			heap.Push(queue, spatialIndexQueueItem{node: item.node, distance: distance, result: &result})
+		default:
+			for child := index.refs[item.node]; child < index.ends[item.node]; child++ {
+				heap.Push(queue, spatialIndexQueueItem{node: child, distance: index.boxes[child].planeDistance(pt, lonScale)})
+			}
+		}
+	}
+	return found
+}
+
+// planeDistance Returns distance from point to box in equirectangular projection centered at point (degrees of latitude)
+func (bbox boundingBox) planeDistance(pt GeoPoint, lonScale float64) float64 {
+	dx := math.Max(0, math.Max(bbox.minLon-pt.Lon, pt.Lon-bbox.maxLon)) * lonScale
+	dy := math.Max(0, math.Max(bbox.minLat-pt.Lat, pt.Lat-bbox.maxLat))
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+// projectOntoLine Returns projection of point onto line and distance to it in equirectangular projection centered at point (degrees of latitude)
+func projectOntoLine(pt GeoPoint, line []GeoPoint, lonScale float64) (NearestGeometry, float64) {
+	toPlane := func(p GeoPoint) (float64, float64) {
+		return (p.Lon - pt.Lon) * lonScale, p.Lat - pt.Lat
+	}
+	best := NearestGeometry{Point: line[0]}
+	ax, ay := toPlane(line[0])
+	bestDistance := math.Sqrt(ax*ax + ay*ay)
+	for i := 1; i < len(line); i++ {
+		ax, ay := toPlane(line[i-1])
+		bx, by := toPlane(line[i])
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if lengthSquared := dx*dx + dy*dy; lengthSquared > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lengthSquared))
+		}
+		px, py := ax+t*dx, ay+t*dy
+		distance := math.Sqrt(px*px + py*py)
+		if distance < bestDistance {
+			bestDistance = distance
+			best.Segment = i - 1
+			best.Point = GeoPoint{
+				Lon: line[i-1].Lon + t*(line[i].Lon-line[i-1].Lon),
+				Lat: line[i-1].Lat + t*(line[i].Lat-line[i-1].Lat),
+			}
+		}
+	}
+	if totalLength := getSphericalLength(line); totalLength > 0 {
+		best.Offset = (getSphericalLength(line[:best.Segment+1]) + greatCircleDistance(line[best.Segment], best.Point)) / totalLength
+		best.Offset = math.Min(best.Offset, 1)
+	}
+	best.Distance = greatCircleDistance(pt, best.Point)
+	return best, bestDistance
+}
+
+// spatialIndexQueueItem Node of R-tree (or found geometry if result is set) with distance to query point
+type spatialIndexQueueItem struct {
+	node     int
+	distance float64
+	result   *NearestGeometry
+}
+
+// spatialIndexQueue Min-heap of nodes by distance (nodes with the same distance go before found geometries)
+type spatialIndexQueue []spatialIndexQueueItem
+
+func (h spatialIndexQueue) Len() int { return len(h) }
+func (h spatialIndexQueue) Less(i, j int) bool {
+	if h[i].distance == h[j].distance {
+		return h[i].result == nil && h[j].result != nil
+	}
+	return h[i].distance < h[j].distance
+}
+func (h spatialIndexQueue) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *spatialIndexQueue) Push(x interface{}) { *h = append(*h, x.(spatialIndexQueueItem)) }
+func (h *spatialIndexQueue) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
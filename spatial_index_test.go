@@ -0,0 +1,67 @@
+package osm2ch
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSpatialIndexNearest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	randomPoint := func() GeoPoint {
+		return GeoPoint{Lon: 37.5 + rnd.Float64()*0.2, Lat: 55.7 + rnd.Float64()*0.1}
+	}
+	geoms := make([][]GeoPoint, 1000)
+	for i := range geoms {
+		if i%100 == 0 {
+			// Empty geometries should be skipped
+			continue
+		}
+		start := randomPoint()
+		geoms[i] = []GeoPoint{start}
+		for j := rnd.Intn(4); j >= 0; j-- {
+			last := geoms[i][len(geoms[i])-1]
+			geoms[i] = append(geoms[i], GeoPoint{Lon: last.Lon + (rnd.Float64()-0.5)*0.01, Lat: last.Lat + (rnd.Float64()-0.5)*0.005})
+		}
+	}
+	index := NewSpatialIndex(geoms)
+	if index.Len() != 990 {
+		t.Fatalf("Index should contain 990 geometries, but got %d", index.Len())
+	}
+	for q := 0; q < 200; q++ {
+		pt := randomPoint()
+		lonScale := math.Cos(degreesToRadians(pt.Lat))
+		expectedIdx, expectedDistance := -1, math.Inf(1)
+		for i, geom := range geoms {
+			if len(geom) == 0 {
+				continue
+			}
+			if _, distance := projectOntoLine(pt, geom, lonScale); distance < expectedDistance {
+				expectedIdx, expectedDistance = i, distance
+			}
+		}
+		found := index.NearestN(pt, 3)
+		if len(found) != 3 || found[0].Index != expectedIdx {
+			t.Fatalf("Nearest geometry for %v should be %d, but got %+v", pt, expectedIdx, found)
+		}
+		if found[0].Distance > found[1].Distance+1e-9 || found[1].Distance > found[2].Distance+1e-9 {
+			t.Errorf("Found geometries should be sorted by distance: %+v", found)
+		}
+		if found[0].Offset < 0 || found[0].Offset > 1 {
+			t.Errorf("Offset should be in [0; 1], but got %f", found[0].Offset)
+		}
+	}
+
+	// Projection onto the middle of segment
+	line := []GeoPoint{{Lon: 0, Lat: 0}, {Lon: 0, Lat: 1}, {Lon: 0, Lat: 3}}
+	nearest, ok := NewSpatialIndex([][]GeoPoint{line}).Nearest(GeoPoint{Lon: 0.001, Lat: 2})
+	if !ok {
+		t.Fatal("Nearest geometry should be found")
+	}
+	if nearest.Segment != 1 || math.Abs(nearest.Point.Lat-2) > 1e-9 || nearest.Point.Lon != 0 || math.Abs(nearest.Offset-2.0/3.0) > 1e-9 {
+		t.Errorf("Bad projection onto line: %+v", nearest)
+	}
+	if _, ok := NewSpatialIndex(nil).Nearest(GeoPoint{}); ok {
+		t.Errorf("Nothing should be found in empty index")
+	}
+}
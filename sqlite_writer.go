@@ -0,0 +1,525 @@
+package osm2ch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	sqlitePageSize = 4096
+	// Size of database header at the beginning of the first page
+	sqliteHeaderSize = 100
+	// Maximum and minimum local payload of table leaf cell, the rest of payload goes to overflow pages (see "B-tree Pages" of SQLite file format)
+	sqliteMaxLocal = sqlitePageSize - 35
+	sqliteMinLocal = (sqlitePageSize-12)*32/255 - 23
+	// Maximum payload of index cell without overflow
+	sqliteIndexMaxLocal = (sqlitePageSize-12)*64/255 - 23
+	// Maximum size of interior table cell: child page number and varint key
+	sqliteMaxInteriorCell = 4 + 9
+	// Version of SQLite library which is written into header (3.31.1)
+	sqliteVersionNumber = 3031001
+)
+
+const (
+	sqlitePageTableInterior = 0x05
+	sqlitePageTableLeaf     = 0x0d
+	sqlitePageIndexLeaf     = 0x0a
+)
+
+// sqliteWriter Writes SQLite database file from scratch
+/*
+	Only what is needed for bulk export is supported: tables are filled once with rows in ascending order of rowid
+	(b-trees are built bottom-up and pages are written sequentially) and indices must fit single page.
+	See ref. https://www.sqlite.org/fileformat.html
+*/
+type sqliteWriter struct {
+	file          *os.File
+	w             *bufio.Writer
+	pagesNum      uint32
+	schema        []sqliteSchemaEntry
+	applicationID uint32
+	userVersion   uint32
+}
+
+// sqliteSchemaEntry represents row of sqlite_master table
+type sqliteSchemaEntry struct {
+	entryType string
+	name      string
+	tableName string
+	rootPage  uint32
+	sql       string // empty for automatic indices
+}
+
+// sqliteTable Builds b-tree of table. Rows should be inserted in ascending order of rowid
+type sqliteTable struct {
+	db      *sqliteWriter
+	name    string
+	sql     string
+	reserve int // bytes reserved at the beginning of the root page
+	leaf    sqlitePageBuilder
+	// Already written leaves
+	leaves    []sqliteChild
+	lastRowid int64
+	rowsNum   int
+}
+
+// sqliteChild represents reference to child page of interior table page
+type sqliteChild struct {
+	page     uint32
+	maxRowid int64
+}
+
+// sqlitePageBuilder Collects cells of single page
+type sqlitePageBuilder struct {
+	cells [][]byte
+	// Summary size of cells and their pointers
+	size int
+}
+
+// newSQLiteWriter Creates database file. First page is written on close
+func newSQLiteWriter(fname string, applicationID, userVersion uint32) (*sqliteWriter, error) {
+	file, err := os.Create(fname)
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't create database file")
+	}
+	db := &sqliteWriter{
+		file:          file,
+		w:             bufio.NewWriterSize(file, 64*sqlitePageSize),
+		applicationID: applicationID,
+		userVersion:   userVersion,
+	}
+	// Placeholder for the first page
+	_, err = db.writePage(make([]byte, sqlitePageSize))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// writePage Appends page to file and returns its number
+func (db *sqliteWriter) writePage(page []byte) (uint32, error) {
+	_, err := db.w.Write(page)
+	if err != nil {
+		return 0, errors.Wrap(err, "Can't write page of database")
+	}
+	db.pagesNum++
+	return db.pagesNum, nil
+}
+
+// writeOverflow Writes chain of overflow pages for given part of payload and returns number of the first page
+func (db *sqliteWriter) writeOverflow(payload []byte) (uint32, error) {
+	first := db.pagesNum + 1
+	page := make([]byte, sqlitePageSize)
+	for len(payload) > 0 {
+		n := copy(page[4:], payload)
+		payload = payload[n:]
+		next := uint32(0)
+		if len(payload) > 0 {
+			// Pages of chain are written one after another
+			next = db.pagesNum + 2
+		}
+		binary.BigEndian.PutUint32(page, next)
+		for i := 4 + n; i < len(page); i++ {
+			page[i] = 0
+		}
+		_, err := db.writePage(page)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return first, nil
+}
+
+// addSchemaEntry Adds object which has no pages (virtual table, trigger) to schema
+func (db *sqliteWriter) addSchemaEntry(entryType, name, tableName, sql string) {
+	db.schema = append(db.schema, sqliteSchemaEntry{entryType: entryType, name: name, tableName: tableName, sql: sql})
+}
+
+// createTable Starts new table. Table is added to schema after finish() call
+func (db *sqliteWriter) createTable(name, sql string) *sqliteTable {
+	return &sqliteTable{db: db, name: name, sql: sql}
+}
+
+// createIndex Writes index which fits single page
+/*
+	records - index keys, each one followed by rowid of table row. They are sorted here
+*/
+func (db *sqliteWriter) createIndex(name, tableName, sql string, records [][]interface{}) error {
+	sort.SliceStable(records, func(i, j int) bool {
+		return compareSQLiteRecords(records[i], records[j]) < 0
+	})
+	pb := sqlitePageBuilder{}
+	for _, record := range records {
+		payload, err := prepareSQLiteRecord(record)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("Index '%s'", name))
+		}
+		if len(payload) > sqliteIndexMaxLocal {
+			return fmt.Errorf("Key of index '%s' is too big", name)
+		}
+		cell := appendSQLiteVarint(nil, uint64(len(payload)))
+		cell = append(cell, payload...)
+		if !pb.fits(cell, 8) {
+			return fmt.Errorf("Index '%s' does not fit single page", name)
+		}
+		pb.add(cell)
+	}
+	page, err := db.writePage(pb.build(sqlitePageIndexLeaf, 0, 0))
+	if err != nil {
+		return err
+	}
+	db.schema = append(db.schema, sqliteSchemaEntry{entryType: "index", name: name, tableName: tableName, rootPage: page, sql: sql})
+	return nil
+}
+
+// Close Writes schema and header into the first page and closes file
+func (db *sqliteWriter) Close() error {
+	defer db.file.Close()
+	master := db.createTable("sqlite_master", "")
+	master.reserve = sqliteHeaderSize
+	for i, entry := range db.schema {
+		var sql interface{}
+		if entry.sql != "" {
+			sql = entry.sql
+		}
+		err := master.insert(int64(i+1), entry.entryType, entry.name, entry.tableName, int64(entry.rootPage), sql)
+		if err != nil {
+			return err
+		}
+	}
+	firstPage, err := master.build()
+	if err != nil {
+		return err
+	}
+	err = db.w.Flush()
+	if err != nil {
+		return errors.Wrap(err, "Can't flush database file")
+	}
+
+	header := firstPage[:sqliteHeaderSize]
+	copy(header, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(header[16:], sqlitePageSize)
+	header[18] = 1 // File format write version (legacy)
+	header[19] = 1 // File format read version (legacy)
+	header[21] = 64
+	header[22] = 32
+	header[23] = 32
+	binary.BigEndian.PutUint32(header[24:], 1) // File change counter
+	binary.BigEndian.PutUint32(header[28:], db.pagesNum)
+	binary.BigEndian.PutUint32(header[40:], 1) // Schema cookie
+	binary.BigEndian.PutUint32(header[44:], 4) // Schema format number
+	binary.BigEndian.PutUint32(header[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(header[60:], db.userVersion)
+	binary.BigEndian.PutUint32(header[68:], db.applicationID)
+	binary.BigEndian.PutUint32(header[92:], 1) // Version-valid-for (equals to change counter)
+	binary.BigEndian.PutUint32(header[96:], sqliteVersionNumber)
+	_, err = db.file.WriteAt(firstPage, 0)
+	if err != nil {
+		return errors.Wrap(err, "Can't write header of database")
+	}
+	return db.file.Close()
+}
+
+// insert Adds row to table. Values could be nil, int64, float64, string or []byte (nil should be used for INTEGER PRIMARY KEY column)
+func (table *sqliteTable) insert(rowid int64, values ...interface{}) error {
+	if table.rowsNum > 0 && rowid <= table.lastRowid {
+		return fmt.Errorf("Rows of table '%s' should be inserted in ascending order of rowid", table.name)
+	}
+	payload, err := prepareSQLiteRecord(values)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Table '%s'", table.name))
+	}
+	cell := appendSQLiteVarint(nil, uint64(len(payload)))
+	cell = appendSQLiteVarint(cell, uint64(rowid))
+	local := len(payload)
+	if local > sqliteMaxLocal {
+		local = sqliteMinLocal + (len(payload)-sqliteMinLocal)%(sqlitePageSize-4)
+		if local > sqliteMaxLocal {
+			local = sqliteMinLocal
+		}
+	}
+	cell = append(cell, payload[:local]...)
+	if local < len(payload) {
+		overflowPage, err := table.db.writeOverflow(payload[local:])
+		if err != nil {
+			return err
+		}
+		var pageNum [4]byte
+		binary.BigEndian.PutUint32(pageNum[:], overflowPage)
+		cell = append(cell, pageNum[:]...)
+	}
+	if !table.leaf.fits(cell, table.reserve+8) {
+		if len(table.leaf.cells) == 0 {
+			return fmt.Errorf("Row of table '%s' does not fit page", table.name)
+		}
+		page, err := table.db.writePage(table.leaf.build(sqlitePageTableLeaf, 0, 0))
+		if err != nil {
+			return err
+		}
+		table.leaves = append(table.leaves, sqliteChild{page: page, maxRowid: table.lastRowid})
+		table.leaf = sqlitePageBuilder{}
+	}
+	table.leaf.add(cell)
+	table.lastRowid = rowid
+	table.rowsNum++
+	return nil
+}
+
+// finish Writes rest of table and adds it to schema
+func (table *sqliteTable) finish() error {
+	rootPage, err := table.build()
+	if err != nil {
+		return err
+	}
+	page, err := table.db.writePage(rootPage)
+	if err != nil {
+		return err
+	}
+	table.db.schema = append(table.db.schema, sqliteSchemaEntry{entryType: "table", name: table.name, tableName: table.name, rootPage: page, sql: table.sql})
+	return nil
+}
+
+// build Writes all pages of table except root one, which is returned
+func (table *sqliteTable) build() ([]byte, error) {
+	if len(table.leaves) == 0 {
+		return table.leaf.build(sqlitePageTableLeaf, table.reserve, 0), nil
+	}
+	if len(table.leaf.cells) > 0 {
+		page, err := table.db.writePage(table.leaf.build(sqlitePageTableLeaf, 0, 0))
+		if err != nil {
+			return nil, err
+		}
+		table.leaves = append(table.leaves, sqliteChild{page: page, maxRowid: table.lastRowid})
+	}
+	level := table.leaves
+	// Children of interior page: cells and the right-most pointer
+	perPage := (sqlitePageSize-table.reserve-12)/(sqliteMaxInteriorCell+2) + 1
+	for len(level) > perPage {
+		next := []sqliteChild{}
+		for start := 0; start < len(level); {
+			end := start + perPage
+			if end > len(level) {
+				end = len(level)
+			}
+			if len(level)-end == 1 {
+				// Interior page should have at least one cell, so the last page must not get the single child
+				end--
+			}
+			page, err := table.db.writePage(prepareTableInteriorPage(level[start:end], 0))
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, sqliteChild{page: page, maxRowid: level[end-1].maxRowid})
+			start = end
+		}
+		level = next
+	}
+	return prepareTableInteriorPage(level, table.reserve), nil
+}
+
+// prepareTableInteriorPage Returns interior page of table b-tree for given children
+func prepareTableInteriorPage(children []sqliteChild, reserve int) []byte {
+	pb := sqlitePageBuilder{}
+	for _, child := range children[:len(children)-1] {
+		cell := make([]byte, 4, sqliteMaxInteriorCell)
+		binary.BigEndian.PutUint32(cell, child.page)
+		pb.add(appendSQLiteVarint(cell, uint64(child.maxRowid)))
+	}
+	return pb.build(sqlitePageTableInterior, reserve, children[len(children)-1].page)
+}
+
+// fits Returns true if cell could be added to page with header of given size
+func (pb *sqlitePageBuilder) fits(cell []byte, headerSize int) bool {
+	return headerSize+pb.size+len(cell)+2 <= sqlitePageSize
+}
+
+func (pb *sqlitePageBuilder) add(cell []byte) {
+	pb.cells = append(pb.cells, cell)
+	pb.size += len(cell) + 2
+}
+
+// build Returns page with collected cells
+/*
+	reserve - offset of page header (100 for the first page of database)
+	rightPointer - the right-most pointer of interior page
+*/
+func (pb *sqlitePageBuilder) build(pageType byte, reserve int, rightPointer uint32) []byte {
+	page := make([]byte, sqlitePageSize)
+	page[reserve] = pageType
+	headerSize := 8
+	if pageType == sqlitePageTableInterior {
+		headerSize = 12
+		binary.BigEndian.PutUint32(page[reserve+8:], rightPointer)
+	}
+	binary.BigEndian.PutUint16(page[reserve+3:], uint16(len(pb.cells)))
+	content := sqlitePageSize
+	for i, cell := range pb.cells {
+		content -= len(cell)
+		copy(page[content:], cell)
+		binary.BigEndian.PutUint16(page[reserve+headerSize+2*i:], uint16(content))
+	}
+	binary.BigEndian.PutUint16(page[reserve+5:], uint16(content))
+	return page
+}
+
+// prepareSQLiteRecord Returns values in SQLite record format. Values could be nil, int64, float64, string or []byte
+func prepareSQLiteRecord(values []interface{}) ([]byte, error) {
+	header := []byte{}
+	body := []byte{}
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			header = append(header, 0)
+		case int64:
+			serialType, size := sqliteIntegerSerialType(v)
+			header = append(header, serialType)
+			for i := size - 1; i >= 0; i-- {
+				body = append(body, byte(v>>(8*uint(i))))
+			}
+		case float64:
+			header = append(header, 7)
+			var buf [8]byte
+			binary.BigEndian.PutUint64(buf[:], math.Float64bits(v))
+			body = append(body, buf[:]...)
+		case string:
+			header = appendSQLiteVarint(header, uint64(len(v))*2+13)
+			body = append(body, v...)
+		case []byte:
+			header = appendSQLiteVarint(header, uint64(len(v))*2+12)
+			body = append(body, v...)
+		default:
+			return nil, fmt.Errorf("Unsupported type of SQLite value: %T", value)
+		}
+	}
+	// Size of header includes size of itself
+	headerSize := len(header) + 1
+	for len(appendSQLiteVarint(nil, uint64(headerSize)))+len(header) != headerSize {
+		headerSize = len(appendSQLiteVarint(nil, uint64(headerSize))) + len(header)
+	}
+	record := appendSQLiteVarint(make([]byte, 0, headerSize+len(body)), uint64(headerSize))
+	record = append(record, header...)
+	return append(record, body...), nil
+}
+
+// sqliteIntegerSerialType Returns serial type of integer and size of its body
+func sqliteIntegerSerialType(v int64) (byte, int) {
+	switch {
+	case v == 0:
+		return 8, 0
+	case v == 1:
+		return 9, 0
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return 1, 1
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return 2, 2
+	case v >= -1<<23 && v < 1<<23:
+		return 3, 3
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return 4, 4
+	case v >= -1<<47 && v < 1<<47:
+		return 5, 6
+	}
+	return 6, 8
+}
+
+// appendSQLiteVarint Appends variable-length integer in SQLite format (big-endian, up to 9 bytes)
+func appendSQLiteVarint(b []byte, v uint64) []byte {
+	if v > 0x00ffffffffffffff {
+		var buf [9]byte
+		buf[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(b, buf[:]...)
+	}
+	var buf [8]byte
+	n := 0
+	for {
+		buf[n] = byte(v & 0x7f)
+		n++
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		c := buf[i]
+		if i != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+// compareSQLiteRecords Compares records the way SQLite compares keys of index with BINARY collation
+func compareSQLiteRecords(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareSQLiteValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// compareSQLiteValues Compares values: NULL < numbers < text < blobs
+func compareSQLiteValues(a, b interface{}) int {
+	classOf := func(v interface{}) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case int64, float64:
+			return 1
+		case string:
+			return 2
+		}
+		return 3
+	}
+	numberOf := func(v interface{}) float64 {
+		if i, ok := v.(int64); ok {
+			return float64(i)
+		}
+		return v.(float64)
+	}
+	ca, cb := classOf(a), classOf(b)
+	if ca != cb {
+		return ca - cb
+	}
+	switch ca {
+	case 1:
+		ai, aIsInt := a.(int64)
+		bi, bIsInt := b.(int64)
+		if aIsInt && bIsInt {
+			switch {
+			case ai < bi:
+				return -1
+			case ai > bi:
+				return 1
+			}
+			return 0
+		}
+		na, nb := numberOf(a), numberOf(b)
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		}
+		return 0
+	case 2:
+		return bytes.Compare([]byte(a.(string)), []byte(b.(string)))
+	case 3:
+		return bytes.Compare(a.([]byte), b.([]byte))
+	}
+	return 0
+}
@@ -0,0 +1,184 @@
+package osm2ch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteVarint(t *testing.T) {
+	cases := []struct {
+		value    uint64
+		expected []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x81, 0x00}},
+		{300, []byte{0x82, 0x2c}},
+		{0xffffffffffffffff, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, c := range cases {
+		if got := appendSQLiteVarint(nil, c.value); !bytes.Equal(got, c.expected) {
+			t.Errorf("Varint of %d should be %x, but got %x", c.value, c.expected, got)
+		}
+		if got, n := readSQLiteVarint(c.expected); got != c.value || n != len(c.expected) {
+			t.Errorf("Varint %x should be decoded as %d (%d bytes), but got %d (%d bytes)", c.expected, c.value, len(c.expected), got, n)
+		}
+	}
+	// Record: header size, serial types (NULL, 0, 1, 8-bit integer, text of 2 bytes, float), body
+	record, err := prepareSQLiteRecord([]interface{}{nil, int64(0), int64(1), int64(-2), "ab", 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0x07, 0x00, 0x08, 0x09, 0x01, 0x11, 0x07, 0xfe, 'a', 'b', 0x3f, 0xe0, 0, 0, 0, 0, 0, 0}
+	if !bytes.Equal(record, expected) {
+		t.Errorf("Record should be %x, but got %x", expected, record)
+	}
+	if _, err = prepareSQLiteRecord([]interface{}{int32(1)}); err == nil {
+		t.Errorf("Values of unsupported types should not be accepted")
+	}
+}
+
+func TestSQLiteWriterTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "osm2ch_sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "test.db")
+	db, err := newSQLiteWriter(fname, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Enough rows for several levels of b-tree, some of them need overflow pages
+	payloads := map[int64][]byte{}
+	table := db.createTable("t", "CREATE TABLE t(id INTEGER PRIMARY KEY, data)")
+	for i := int64(0); i < 100000; i++ {
+		rowid := i*3 - 1000
+		data := bytes.Repeat([]byte{byte(i)}, int(i%50))
+		if i%997 == 0 {
+			data = bytes.Repeat([]byte{byte(i)}, int(i%20000))
+		}
+		err = table.insert(rowid, nil, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payloads[rowid], err = prepareSQLiteRecord([]interface{}{nil, data})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = table.insert(0, nil, nil); err == nil {
+		t.Errorf("Rows with decreasing rowid should not be accepted")
+	}
+	err = table.finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 300; i++ {
+		db.addSchemaEntry("trigger", "trigger", "t", string(bytes.Repeat([]byte{'x'}, 100)))
+	}
+	rootPage := db.schema[0].rootPage
+	schemaSize := len(db.schema)
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != int(binary.BigEndian.Uint32(data[28:]))*sqlitePageSize {
+		t.Errorf("Size of file should be equal to number of pages in header")
+	}
+	if string(data[:16]) != "SQLite format 3\x00" {
+		t.Errorf("Bad header of database")
+	}
+	rows := 0
+	prevRowid := int64(-1 << 62)
+	readSQLiteTable(t, data, rootPage, func(rowid int64, payload []byte) {
+		if rowid <= prevRowid {
+			t.Errorf("Rowids should be in ascending order")
+		}
+		prevRowid = rowid
+		if !bytes.Equal(payload, payloads[rowid]) {
+			t.Errorf("Payload of row %d differs", rowid)
+		}
+		rows++
+	})
+	if rows != len(payloads) {
+		t.Errorf("Table should contain %d rows, but got %d", len(payloads), rows)
+	}
+	schemaRows := 0
+	readSQLiteTable(t, data, 1, func(rowid int64, payload []byte) {
+		schemaRows++
+	})
+	if schemaRows != schemaSize {
+		t.Errorf("Schema should contain %d rows, but got %d", schemaSize, schemaRows)
+	}
+}
+
+// readSQLiteTable Walks table b-tree and calls handler for every row
+func readSQLiteTable(t *testing.T, data []byte, pageNum uint32, handler func(rowid int64, payload []byte)) {
+	page := data[(pageNum-1)*sqlitePageSize : pageNum*sqlitePageSize]
+	offset := 0
+	if pageNum == 1 {
+		offset = sqliteHeaderSize
+	}
+	cellsNum := int(binary.BigEndian.Uint16(page[offset+3:]))
+	switch page[offset] {
+	case sqlitePageTableInterior:
+		for i := 0; i < cellsNum; i++ {
+			ptr := binary.BigEndian.Uint16(page[offset+12+2*i:])
+			readSQLiteTable(t, data, binary.BigEndian.Uint32(page[ptr:]), handler)
+		}
+		readSQLiteTable(t, data, binary.BigEndian.Uint32(page[offset+8:]), handler)
+	case sqlitePageTableLeaf:
+		for i := 0; i < cellsNum; i++ {
+			cell := page[binary.BigEndian.Uint16(page[offset+8+2*i:]):]
+			size, n := readSQLiteVarint(cell)
+			cell = cell[n:]
+			rowid, n := readSQLiteVarint(cell)
+			cell = cell[n:]
+			local := int(size)
+			if local > sqliteMaxLocal {
+				local = sqliteMinLocal + (int(size)-sqliteMinLocal)%(sqlitePageSize-4)
+				if local > sqliteMaxLocal {
+					local = sqliteMinLocal
+				}
+			}
+			payload := append([]byte{}, cell[:local]...)
+			overflow := uint32(0)
+			if local < int(size) {
+				overflow = binary.BigEndian.Uint32(cell[local:])
+			}
+			for overflow != 0 {
+				overflowPage := data[(overflow-1)*sqlitePageSize : overflow*sqlitePageSize]
+				n := minInt(int(size)-len(payload), sqlitePageSize-4)
+				payload = append(payload, overflowPage[4:4+n]...)
+				overflow = binary.BigEndian.Uint32(overflowPage)
+			}
+			if len(payload) != int(size) {
+				t.Fatalf("Payload of row %d should have %d bytes, but got %d", int64(rowid), size, len(payload))
+			}
+			handler(int64(rowid), payload)
+		}
+	default:
+		t.Fatalf("Unexpected type of page %d: %d", pageNum, page[offset])
+	}
+}
+
+func readSQLiteVarint(b []byte) (uint64, int) {
+	v := uint64(0)
+	for i := 0; i < 8; i++ {
+		v = (v << 7) | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return (v << 8) | uint64(b[8]), 9
+}
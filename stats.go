@@ -0,0 +1,458 @@
+package osm2ch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/paulmach/osm"
+)
+
+// statsSamplesNum Max number of IDs of problematic objects kept in report
+const statsSamplesNum = 20
+
+// GraphStats Statistics and data-quality report of imported graph
+type GraphStats struct {
+	Ways          int     `json:"ways"`
+	OnewayWays    int     `json:"oneway_ways"`
+	OnewayRatio   float64 `json:"oneway_ratio"`
+	Edges         int     `json:"edges"`
+	ExpandedEdges int     `json:"expanded_edges"`
+	// Length of road network: segments of two-way roads are counted once
+	TotalLengthKm float64 `json:"total_length_km"`
+	// Classes are values of tag of configuration's entity (e.g. 'highway'), sorted by name
+	Classes []ClassStats `json:"classes"`
+	// Number of distinct neighbor nodes of nodes which split ways into edges
+	NodeDegrees []DegreeCount `json:"node_degrees"`
+	// Number of outgoing transitions of vertices of expanded graph (edges)
+	OutDegrees      []DegreeCount     `json:"out_degrees"`
+	Components      ComponentsStats   `json:"components"`
+	Restrictions    RestrictionsStats `json:"restrictions"`
+	DegenerateEdges DegenerateStats   `json:"degenerate_edges"`
+	MissingNodes    MissingNodesStats `json:"missing_nodes"`
+}
+
+// ClassStats Statistics of ways having the same class
+type ClassStats struct {
+	Class      string  `json:"class"`
+	Ways       int     `json:"ways"`
+	OnewayWays int     `json:"oneway_ways"`
+	Edges      int     `json:"edges"`
+	LengthKm   float64 `json:"length_km"`
+}
+
+// DegreeCount Number of vertices having given degree
+type DegreeCount struct {
+	Degree int `json:"degree"`
+	Count  int `json:"count"`
+}
+
+// ComponentsStats Strongly connected components of expanded graph
+type ComponentsStats struct {
+	Count   int `json:"count"`
+	Largest int `json:"largest"`
+	// Edges which have no transitions at all (they do not belong to any component)
+	Isolated int `json:"isolated"`
+	// Number of components having given size (Degree is size of component), sorted by size
+	Sizes []DegreeCount `json:"sizes"`
+}
+
+// RestrictionsStats Outcome of restriction relations
+/*
+	Every relation is counted once by the first matching reason in order: unsupported type (only no_* and only_* turns are handled),
+	duplicate (the same type, 'from' and 'to' as restriction with less ID), unsupported members (only way-node-way are handled),
+	unknown ways (ways are filtered out or missing in data), not matched (there are no transitions to prohibit), applied
+*/
+type RestrictionsStats struct {
+	Total              int `json:"total"`
+	Applied            int `json:"applied"`
+	NotMatched         int `json:"not_matched"`
+	UnsupportedType    int `json:"unsupported_type"`
+	UnsupportedMembers int `json:"unsupported_members"`
+	UnknownWays        int `json:"unknown_ways"`
+	Duplicates         int `json:"duplicates"`
+	// Relations which have not exactly 3 members (they are skipped while reading OSM data, so they are not counted in Total)
+	Malformed int `json:"malformed"`
+	// Members with roles other than 'from', 'to' and 'via'
+	UnsupportedRoles int `json:"unsupported_roles"`
+	// Number of expanded edges deleted by restrictions
+	RemovedExpandedEdges int `json:"removed_expanded_edges"`
+}
+
+// DegenerateStats Edges having degenerate geometry
+type DegenerateStats struct {
+	Count int `json:"count"`
+	// Edges having zero length (all points are the same)
+	ZeroLength int `json:"zero_length"`
+	// Edges having the same consecutive points (but non-zero length)
+	RepeatedPoints int `json:"repeated_points"`
+	// IDs of first degenerate edges
+	Samples []EdgeID `json:"samples"`
+}
+
+// MissingNodesStats Nodes which are referenced by ways but are missing in OSM data (usually at boundaries of extracts)
+type MissingNodesStats struct {
+	Count      int `json:"count"`
+	References int `json:"references"`
+	Ways       int `json:"ways"`
+	// Ways having less than 2 nodes after dropping of missing ones
+	DroppedWays int `json:"dropped_ways"`
+	// IDs of first missing nodes (in ascending order)
+	Samples []osm.NodeID `json:"samples"`
+}
+
+// ImportStatsFromOSMFiles Imports graph from files of PBF-format and returns its statistics
+/*
+	Unlike ImportStateFromOSMFiles references to nodes which are missing in data are not errors: they are dropped from ways
+	(ways having less than 2 nodes are dropped entirely) and reported in MissingNodes
+*/
+func ImportStatsFromOSMFiles(fileNames []string, cfg *OsmConfiguration) (GraphStats, error) {
+	data, err := readOSMFiles(fileNames, cfg)
+	if err != nil {
+		return GraphStats{}, err
+	}
+	missingNodes := data.dropMissingNodes()
+	state, err := newGraphState(data, cfg)
+	if err != nil {
+		return GraphStats{}, err
+	}
+	stats := state.Stats()
+	stats.MissingNodes = missingNodes
+	return stats, nil
+}
+
+// dropMissingNodes Deletes references to missing nodes from ways. Ways having less than 2 nodes left are deleted
+func (data *osmData) dropMissingNodes() MissingNodesStats {
+	stats := MissingNodesStats{
+		Samples: []osm.NodeID{},
+	}
+	missing := make(map[osm.NodeID]struct{})
+	for _, wayID := range data.sortedWaysIDs() {
+		way := data.ways[wayID]
+		nodes := make(osm.WayNodes, 0, len(way.Nodes))
+		for _, wayNode := range way.Nodes {
+			if _, ok := data.nodes[wayNode.ID]; !ok {
+				missing[wayNode.ID] = struct{}{}
+				continue
+			}
+			nodes = append(nodes, wayNode)
+		}
+		if len(nodes) == len(way.Nodes) {
+			continue
+		}
+		stats.References += len(way.Nodes) - len(nodes)
+		stats.Ways++
+		if len(nodes) < 2 {
+			stats.DroppedWays++
+			delete(data.ways, wayID)
+			continue
+		}
+		way.Nodes = nodes
+		data.ways[wayID] = way
+	}
+	stats.Count = len(missing)
+	for id := range missing {
+		stats.Samples = append(stats.Samples, id)
+	}
+	sort.Slice(stats.Samples, func(i, j int) bool {
+		return stats.Samples[i] < stats.Samples[j]
+	})
+	if len(stats.Samples) > statsSamplesNum {
+		stats.Samples = stats.Samples[:statsSamplesNum]
+	}
+	return stats
+}
+
+// Stats Returns statistics of graph
+/*
+	Missing nodes are not evaluated, since state could not be built with them (see ImportStatsFromOSMFiles)
+*/
+func (state *GraphState) Stats() GraphStats {
+	data := state.data
+	stats := GraphStats{
+		Ways:          len(data.ways),
+		Edges:         len(state.edges),
+		ExpandedEdges: len(state.expandedEdges),
+		MissingNodes:  MissingNodesStats{Samples: []osm.NodeID{}},
+	}
+
+	classes := make(map[string]*ClassStats)
+	classOf := func(way Way) *ClassStats {
+		class := way.TagMap.Find(state.cfg.EntityName)
+		if _, ok := classes[class]; !ok {
+			classes[class] = &ClassStats{Class: class}
+		}
+		return classes[class]
+	}
+	for _, way := range data.ways {
+		class := classOf(way)
+		class.Ways++
+		if way.Oneway {
+			class.OnewayWays++
+			stats.OnewayWays++
+		}
+	}
+	if stats.Ways > 0 {
+		stats.OnewayRatio = float64(stats.OnewayWays) / float64(stats.Ways)
+	}
+
+	degenerate := DegenerateStats{
+		Samples: []EdgeID{},
+	}
+	neighbors := make(map[osm.NodeID]map[osm.NodeID]struct{})
+	addNeighbor := func(node, neighbor osm.NodeID) {
+		if _, ok := neighbors[node]; !ok {
+			neighbors[node] = make(map[osm.NodeID]struct{})
+		}
+		if node != neighbor {
+			neighbors[node][neighbor] = struct{}{}
+		}
+	}
+	for _, edge := range state.edges {
+		// Both directions of two-way road have the same length
+		length := edge.CostMeters
+		if !edge.WasOneway {
+			length /= 2
+		}
+		class := classOf(data.ways[edge.WayID])
+		class.Edges++
+		class.LengthKm += length
+		stats.TotalLengthKm += length
+
+		addNeighbor(edge.SourceNodeID, edge.TargetNodeID)
+		addNeighbor(edge.TargetNodeID, edge.SourceNodeID)
+
+		isDegenerate := false
+		if len(edge.Geom) < 2 || edge.CostMeters == 0 {
+			degenerate.ZeroLength++
+			isDegenerate = true
+		} else {
+			for i := 1; i < len(edge.Geom); i++ {
+				if edge.Geom[i] == edge.Geom[i-1] {
+					degenerate.RepeatedPoints++
+					isDegenerate = true
+					break
+				}
+			}
+		}
+		if isDegenerate {
+			degenerate.Count++
+			if len(degenerate.Samples) < statsSamplesNum {
+				degenerate.Samples = append(degenerate.Samples, edge.ID)
+			}
+		}
+	}
+	stats.DegenerateEdges = degenerate
+
+	stats.Classes = make([]ClassStats, 0, len(classes))
+	for _, class := range classes {
+		stats.Classes = append(stats.Classes, *class)
+	}
+	sort.Slice(stats.Classes, func(i, j int) bool {
+		return stats.Classes[i].Class < stats.Classes[j].Class
+	})
+
+	nodeDegrees := make(map[int]int)
+	for _, nodeNeighbors := range neighbors {
+		nodeDegrees[len(nodeNeighbors)]++
+	}
+	stats.NodeDegrees = prepareDegreeCounts(nodeDegrees)
+
+	outDegree := make(map[EdgeID]int, len(state.edges))
+	for _, edge := range state.expandedEdges {
+		outDegree[edge.Source]++
+	}
+	outDegrees := make(map[int]int)
+	for _, edge := range state.edges {
+		outDegrees[outDegree[edge.ID]]++
+	}
+	stats.OutDegrees = prepareDegreeCounts(outDegrees)
+
+	components := StronglyConnectedComponents(state.expandedEdges)
+	inComponents := 0
+	componentSizes := make(map[int]int)
+	for _, component := range components {
+		inComponents += len(component)
+		componentSizes[len(component)]++
+	}
+	stats.Components = ComponentsStats{
+		Count:    len(components),
+		Isolated: len(state.edges) - inComponents,
+		Sizes:    prepareDegreeCounts(componentSizes),
+	}
+	if len(components) > 0 {
+		stats.Components.Largest = len(components[0])
+	}
+
+	stats.Restrictions = state.restrictionsStats()
+	return stats
+}
+
+// prepareDegreeCounts Converts map of counts to slice sorted by degree
+func prepareDegreeCounts(counts map[int]int) []DegreeCount {
+	ans := make([]DegreeCount, 0, len(counts))
+	for degree, count := range counts {
+		ans = append(ans, DegreeCount{Degree: degree, Count: count})
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].Degree < ans[j].Degree
+	})
+	return ans
+}
+
+// restrictionsStats Classifies restrictions the same way as applyRestrictions handles them
+func (state *GraphState) restrictionsStats() RestrictionsStats {
+	data := state.data
+	stats := RestrictionsStats{
+		Total:            len(data.restrictions),
+		Malformed:        len(data.skippedRestrictions),
+		UnsupportedRoles: data.unsupportedRolesNum(),
+	}
+	// Transitions before applying restrictions
+	transitions := make(map[[2]osm.WayID]struct{})
+	viaNodes := make(map[osm.WayID]map[osm.NodeID][]osm.WayID)
+	expandedEdgesNum := 0
+	for _, edge := range state.edges {
+		for _, expEdge := range state.expandedBySource[edge.ID] {
+			expandedEdgesNum++
+			from, to := expEdge.SourceOSMWayID, expEdge.TargetOSMWayID
+			transitions[[2]osm.WayID{from, to}] = struct{}{}
+			if _, ok := viaNodes[from]; !ok {
+				viaNodes[from] = make(map[osm.NodeID][]osm.WayID)
+			}
+			via := expEdge.SourceComponent.TargetNodeID
+			viaNodes[from][via] = append(viaNodes[from][via], to)
+		}
+	}
+	stats.RemovedExpandedEdges = expandedEdgesNum - len(state.expandedEdges)
+
+	ids := make([]osm.RelationID, 0, len(data.restrictions))
+	for id := range data.restrictions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	seen := make(map[restriction]struct{})
+	for _, id := range ids {
+		r := data.restrictions[id]
+		onlyType := false
+		switch r.Tag {
+		case "no_left_turn", "no_right_turn", "no_straight_on":
+		case "only_left_turn", "only_right_turn", "only_straight_on":
+			onlyType = true
+		default:
+			stats.UnsupportedType++
+			continue
+		}
+		// Only first restriction is kept for the same type, 'from' and 'to' (see restrictionsMap)
+		key := restriction{Tag: r.Tag, From: r.From, To: r.To}
+		if _, ok := seen[key]; ok {
+			stats.Duplicates++
+			continue
+		}
+		seen[key] = struct{}{}
+		if r.From.Type != "way" || r.To.Type != "way" || r.Via.Type != "node" {
+			stats.UnsupportedMembers++
+			continue
+		}
+		from, to := osm.WayID(r.From.ID), osm.WayID(r.To.ID)
+		_, fromKnown := data.ways[from]
+		_, toKnown := data.ways[to]
+		if !fromKnown || !toKnown {
+			stats.UnknownWays++
+			continue
+		}
+		matched := false
+		if onlyType {
+			for _, target := range viaNodes[from][osm.NodeID(r.Via.ID)] {
+				if target != to {
+					matched = true
+					break
+				}
+			}
+		} else {
+			_, matched = transitions[[2]osm.WayID{from, to}]
+		}
+		if matched {
+			stats.Applied++
+		} else {
+			stats.NotMatched++
+		}
+	}
+	return stats
+}
+
+// WriteJSON Writes statistics as indented JSON
+func (stats GraphStats) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stats)
+}
+
+// WriteMarkdown Writes statistics as Markdown document with tables
+func (stats GraphStats) WriteMarkdown(w io.Writer) error {
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	degreesTable := func(title string, counts []DegreeCount) {
+		printf("| %s | Count |\n|---:|---:|\n", title)
+		for _, count := range counts {
+			printf("| %d | %d |\n", count.Degree, count.Count)
+		}
+		printf("\n")
+	}
+
+	printf("# Graph statistics\n\n")
+	printf("| Metric | Value |\n|---|---:|\n")
+	printf("| Ways | %d |\n", stats.Ways)
+	printf("| Oneway ways | %d (%.2f%%) |\n", stats.OnewayWays, stats.OnewayRatio*100)
+	printf("| Edges | %d |\n", stats.Edges)
+	printf("| Expanded edges | %d |\n", stats.ExpandedEdges)
+	printf("| Total length, km | %.3f |\n\n", stats.TotalLengthKm)
+
+	printf("## Classes\n\n")
+	printf("| Class | Ways | Oneway ways | Edges | Length, km |\n|---|---:|---:|---:|---:|\n")
+	for _, class := range stats.Classes {
+		printf("| %s | %d | %d | %d | %.3f |\n", class.Class, class.Ways, class.OnewayWays, class.Edges, class.LengthKm)
+	}
+	printf("\n")
+
+	printf("## Degrees\n\nNumber of distinct neighbors of nodes which split ways into edges:\n\n")
+	degreesTable("Degree", stats.NodeDegrees)
+	printf("Number of outgoing transitions of vertices of expanded graph:\n\n")
+	degreesTable("Out degree", stats.OutDegrees)
+
+	printf("## Strongly connected components\n\n")
+	printf("Components: %d, largest: %d, isolated vertices: %d\n\n", stats.Components.Count, stats.Components.Largest, stats.Components.Isolated)
+	degreesTable("Size", stats.Components.Sizes)
+
+	restrictions := stats.Restrictions
+	printf("## Restrictions\n\n")
+	printf("| Outcome | Count |\n|---|---:|\n")
+	printf("| Total | %d |\n", restrictions.Total)
+	printf("| Applied | %d |\n", restrictions.Applied)
+	printf("| Not matched | %d |\n", restrictions.NotMatched)
+	printf("| Unsupported type | %d |\n", restrictions.UnsupportedType)
+	printf("| Unsupported members | %d |\n", restrictions.UnsupportedMembers)
+	printf("| Unknown ways | %d |\n", restrictions.UnknownWays)
+	printf("| Duplicates | %d |\n", restrictions.Duplicates)
+	printf("| Malformed (skipped) | %d |\n", restrictions.Malformed)
+	printf("| Unsupported roles | %d |\n", restrictions.UnsupportedRoles)
+	printf("| Removed expanded edges | %d |\n\n", restrictions.RemovedExpandedEdges)
+
+	printf("## Data quality\n\n")
+	printf("| Problem | Count | Samples |\n|---|---:|---|\n")
+	printf("| Degenerate edges | %d | %v |\n", stats.DegenerateEdges.Count, stats.DegenerateEdges.Samples)
+	printf("| Zero length edges | %d | |\n", stats.DegenerateEdges.ZeroLength)
+	printf("| Edges with repeated points | %d | |\n", stats.DegenerateEdges.RepeatedPoints)
+	printf("| Missing nodes | %d | %v |\n", stats.MissingNodes.Count, stats.MissingNodes.Samples)
+	printf("| References to missing nodes | %d | |\n", stats.MissingNodes.References)
+	printf("| Ways with missing nodes | %d | |\n", stats.MissingNodes.Ways)
+	printf("| Dropped ways | %d | |\n", stats.MissingNodes.DroppedWays)
+	return err
+}
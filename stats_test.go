@@ -0,0 +1,121 @@
+package osm2ch
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paulmach/osm"
+)
+
+func TestGraphStats(t *testing.T) {
+	cfg := OsmConfiguration{
+		EntityName: "highway",
+		Tags:       []string{"residential", "primary"},
+		Workers:    1,
+	}
+	nodes, ways, relations := prepareGridOSM(4)
+	// Oneway way with degenerate geometry
+	nodes[5000] = &osm.Node{ID: 5000, Version: 1, Lon: 37.61, Lat: 55.71}
+	nodes[5001] = &osm.Node{ID: 5001, Version: 1, Lon: 37.61, Lat: 55.71}
+	ways[400] = &osm.Way{ID: 400, Version: 1, Tags: osm.Tags{{Key: "highway", Value: "primary"}, {Key: "oneway", Value: "yes"}}, Nodes: osm.WayNodes{{ID: 5000}, {ID: 5001}}}
+	// Way with missing node
+	ways[401] = &osm.Way{ID: 401, Version: 1, Tags: osm.Tags{{Key: "highway", Value: "residential"}}, Nodes: osm.WayNodes{{ID: 1000}, {ID: 9999}}}
+	restrictionRelation := func(id osm.RelationID, tag string, members osm.Members) *osm.Relation {
+		return &osm.Relation{ID: id, Version: 1, Tags: osm.Tags{{Key: "type", Value: "restriction"}, {Key: "restriction", Value: tag}}, Members: members}
+	}
+	relations[3] = restrictionRelation(3, "no_u_turn", relations[1].Members)
+	relations[4] = restrictionRelation(4, "no_right_turn", relations[1].Members)
+	relations[5] = restrictionRelation(5, "no_left_turn", osm.Members{{Type: osm.TypeWay, Ref: 999, Role: "from"}, {Type: osm.TypeNode, Ref: 1000, Role: "via"}, {Type: osm.TypeWay, Ref: 200, Role: "to"}})
+	relations[6] = restrictionRelation(6, "no_left_turn", osm.Members{{Type: osm.TypeWay, Ref: 100, Role: "from"}, {Type: osm.TypeWay, Ref: 101, Role: "via"}, {Type: osm.TypeWay, Ref: 200, Role: "to"}})
+	// Malformed restriction and restriction with unsupported role
+	relations[7] = restrictionRelation(7, "no_left_turn", osm.Members{{Type: osm.TypeWay, Ref: 100, Role: "from"}, {Type: osm.TypeWay, Ref: 200, Role: "to"}})
+	relations[8] = restrictionRelation(8, "no_left_turn", osm.Members{{Type: osm.TypeWay, Ref: 100, Role: "from"}, {Type: osm.TypeNode, Ref: 1000, Role: "via"}, {Type: osm.TypeNode, Ref: 1001, Role: "location_hint"}})
+
+	data := newOSMData()
+	for _, way := range ways {
+		data.addWay(way, &cfg)
+	}
+	for _, node := range nodes {
+		data.addNode(node)
+	}
+	for _, relation := range relations {
+		data.addRelation(relation)
+	}
+	missingNodes := data.dropMissingNodes()
+	state, err := newGraphState(data, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := state.Stats()
+	stats.MissingNodes = missingNodes
+
+	if stats.Ways != 9 || stats.OnewayWays != 1 {
+		t.Errorf("Ways should be 9 (oneway = 1), but got %d (oneway = %d)", stats.Ways, stats.OnewayWays)
+	}
+	if len(stats.Classes) != 2 || stats.Classes[0].Class != "primary" || stats.Classes[1].Ways != 8 {
+		t.Errorf("Unexpected classes: %+v", stats.Classes)
+	}
+	classesLength := 0.0
+	for _, class := range stats.Classes {
+		classesLength += class.LengthKm
+	}
+	if stats.TotalLengthKm <= 0 || math.Abs(classesLength-stats.TotalLengthKm) > 1e-9 {
+		t.Errorf("Total length should be positive sum of lengths of classes %f, but got %f", classesLength, stats.TotalLengthKm)
+	}
+	if stats.DegenerateEdges.Count != 1 || stats.DegenerateEdges.ZeroLength != 1 {
+		t.Errorf("There should be single zero length edge, but got %+v", stats.DegenerateEdges)
+	}
+	if stats.MissingNodes.Count != 1 || stats.MissingNodes.Samples[0] != 9999 || stats.MissingNodes.DroppedWays != 1 {
+		t.Errorf("There should be single missing node 9999 and single dropped way, but got %+v", stats.MissingNodes)
+	}
+	if stats.Components.Isolated != 1 || stats.Components.Largest == 0 {
+		t.Errorf("Degenerate edge should be isolated, but got %+v", stats.Components)
+	}
+	correctRestrictions := RestrictionsStats{
+		Total:                7,
+		Applied:              2,
+		UnsupportedType:      1,
+		UnsupportedMembers:   2,
+		UnknownWays:          1,
+		Duplicates:           1,
+		Malformed:            1,
+		UnsupportedRoles:     1,
+		RemovedExpandedEdges: stats.Restrictions.RemovedExpandedEdges,
+	}
+	if stats.Restrictions != correctRestrictions || stats.Restrictions.RemovedExpandedEdges == 0 {
+		t.Errorf("Restrictions should be %+v, but got %+v", correctRestrictions, stats.Restrictions)
+	}
+
+	// Restrictions stats should survive saving and loading of state
+	dir, err := ioutil.TempDir("", "osm2ch_stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "state.gob.gz")
+	err = state.SaveToFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGraphState(fname, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loadedStats := loaded.Stats(); loadedStats.Restrictions != stats.Restrictions {
+		t.Errorf("Restrictions of loaded state should be %+v, but got %+v", stats.Restrictions, loadedStats.Restrictions)
+	}
+
+	buf := bytes.Buffer{}
+	err = stats.WriteMarkdown(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "| primary | 1 | 1 | 1 |") {
+		t.Errorf("Markdown should contain row of class 'primary', but got:\n%s", buf.String())
+	}
+}
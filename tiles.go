@@ -0,0 +1,142 @@
+package osm2ch
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// MaxTileZoom Maximum supported zoom of tiles
+	MaxTileZoom = 30
+	// Latitude limit of Web Mercator projection
+	maxMercatorLat = 85.05112877980659
+)
+
+// Tile represents tile of slippy map (XYZ scheme, the same as OpenStreetMap tiles: Y goes from north to south)
+type Tile struct {
+	Z int
+	X int
+	Y int
+}
+
+// TileOfPoint Returns tile of given zoom which contains point. Latitude is clamped to bounds of Web Mercator projection
+func TileOfPoint(pt GeoPoint, zoom int) Tile {
+	n := math.Exp2(float64(zoom))
+	lat := math.Max(-maxMercatorLat, math.Min(maxMercatorLat, pt.Lat)) * math.Pi / 180.0
+	x := int(math.Floor((pt.Lon + 180.0) / 360.0 * n))
+	y := int(math.Floor((1.0 - math.Log(math.Tan(lat)+1.0/math.Cos(lat))/math.Pi) / 2.0 * n))
+	maxXY := int(n) - 1
+	return Tile{Z: zoom, X: minInt(maxInt(x, 0), maxXY), Y: minInt(maxInt(y, 0), maxXY)}
+}
+
+// String Returns tile as "z/x/y"
+func (tile Tile) String() string {
+	return fmt.Sprintf("%d/%d/%d", tile.Z, tile.X, tile.Y)
+}
+
+// Quadkey Returns quadkey of tile (see ref. https://docs.microsoft.com/en-us/bingmaps/articles/bing-maps-tile-system)
+func (tile Tile) Quadkey() string {
+	quadkey := make([]byte, tile.Z)
+	for i := tile.Z; i > 0; i-- {
+		digit := byte('0')
+		mask := 1 << uint(i-1)
+		if tile.X&mask != 0 {
+			digit++
+		}
+		if tile.Y&mask != 0 {
+			digit += 2
+		}
+		quadkey[tile.Z-i] = digit
+	}
+	return string(quadkey)
+}
+
+// Bounds Returns south-west and north-east corners of tile
+func (tile Tile) Bounds() (GeoPoint, GeoPoint) {
+	n := math.Exp2(float64(tile.Z))
+	lon := func(x int) float64 {
+		return float64(x)/n*360.0 - 180.0
+	}
+	lat := func(y int) float64 {
+		return math.Atan(math.Sinh(math.Pi*(1-2*float64(y)/n))) * 180.0 / math.Pi
+	}
+	return GeoPoint{Lon: lon(tile.X), Lat: lat(tile.Y + 1)}, GeoPoint{Lon: lon(tile.X + 1), Lat: lat(tile.Y)}
+}
+
+// GraphTile Part of expanded graph which belongs to single tile
+type GraphTile struct {
+	Tile     Tile
+	Vertices []Vertex
+	// Expanded edges which start in vertices of tile (including cross-tile ones)
+	ExpandedEdges []ExpandedEdge
+}
+
+// CrossTileEdge Expanded edge which connects vertices of different tiles
+type CrossTileEdge struct {
+	ExpandedEdge
+	SourceTile Tile
+	TargetTile Tile
+}
+
+// SplitIntoTiles Splits expanded graph into tiles of given zoom
+/*
+	Every vertex belongs to the tile which contains its geometry, every expanded edge belongs to the tile of its source vertex.
+	Expanded edges which target vertex belongs to another tile are returned as cross-tile edges also.
+	Tiles are sorted by quadkey (so neighboring tiles are close), order of vertices and edges is kept within tile
+*/
+func SplitIntoTiles(expandedEdges []ExpandedEdge, vertices []Vertex, zoom int) ([]GraphTile, []CrossTileEdge, error) {
+	if zoom < 0 || zoom > MaxTileZoom {
+		return nil, nil, fmt.Errorf("Zoom of tiles should be in [0; %d], but got %d", MaxTileZoom, zoom)
+	}
+	tilesIdx := make(map[Tile]int)
+	tiles := []GraphTile{}
+	tileIndex := func(tile Tile) int {
+		idx, ok := tilesIdx[tile]
+		if !ok {
+			idx = len(tiles)
+			tilesIdx[tile] = idx
+			tiles = append(tiles, GraphTile{Tile: tile})
+		}
+		return idx
+	}
+	vertexTiles := make(map[int64]Tile, len(vertices))
+	for _, vertex := range vertices {
+		tile := TileOfPoint(vertex.Geom, zoom)
+		vertexTiles[vertex.ID] = tile
+		idx := tileIndex(tile)
+		tiles[idx].Vertices = append(tiles[idx].Vertices, vertex)
+	}
+	// Vertices which are not provided are placed by geometry of edge
+	tileOfVertex := func(vertexID EdgeID, pt GeoPoint) Tile {
+		if tile, ok := vertexTiles[int64(vertexID)]; ok {
+			return tile
+		}
+		return TileOfPoint(pt, zoom)
+	}
+	crossTileEdges := []CrossTileEdge{}
+	for _, edge := range expandedEdges {
+		var first, last GeoPoint
+		if len(edge.Geom) > 0 {
+			first, last = edge.Geom[0], edge.Geom[len(edge.Geom)-1]
+		}
+		sourceTile := tileOfVertex(edge.Source, first)
+		targetTile := tileOfVertex(edge.Target, last)
+		idx := tileIndex(sourceTile)
+		tiles[idx].ExpandedEdges = append(tiles[idx].ExpandedEdges, edge)
+		if sourceTile != targetTile {
+			crossTileEdges = append(crossTileEdges, CrossTileEdge{ExpandedEdge: edge, SourceTile: sourceTile, TargetTile: targetTile})
+		}
+	}
+	sort.Slice(tiles, func(i, j int) bool {
+		return tiles[i].Tile.Quadkey() < tiles[j].Tile.Quadkey()
+	})
+	return tiles, crossTileEdges, nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,83 @@
+package osm2ch
+
+import (
+	"testing"
+)
+
+func TestTileOfPoint(t *testing.T) {
+	cases := []struct {
+		pt       GeoPoint
+		zoom     int
+		expected Tile
+	}{
+		{GeoPoint{Lon: 37.6173, Lat: 55.7558}, 10, Tile{Z: 10, X: 619, Y: 320}},
+		{GeoPoint{Lon: -75.55, Lat: 39.16}, 12, Tile{Z: 12, X: 1188, Y: 1563}},
+		{GeoPoint{Lon: 180, Lat: -90}, 2, Tile{Z: 2, X: 3, Y: 3}},
+		{GeoPoint{Lon: 0, Lat: 0}, 0, Tile{Z: 0, X: 0, Y: 0}},
+	}
+	for _, c := range cases {
+		tile := TileOfPoint(c.pt, c.zoom)
+		if tile != c.expected {
+			t.Errorf("Tile of point %v at zoom %d should be %v, but got %v", c.pt, c.zoom, c.expected, tile)
+		}
+		sw, ne := tile.Bounds()
+		if c.pt.Lat > -maxMercatorLat && c.pt.Lon < 180 && (c.pt.Lon < sw.Lon || c.pt.Lon > ne.Lon || c.pt.Lat < sw.Lat || c.pt.Lat > ne.Lat) {
+			t.Errorf("Bounds of tile %v should contain point %v", tile, c.pt)
+		}
+	}
+	if quadkey := (Tile{Z: 3, X: 3, Y: 5}).Quadkey(); quadkey != "213" {
+		t.Errorf("Quadkey should be 213, but got %s", quadkey)
+	}
+}
+
+func TestSplitIntoTiles(t *testing.T) {
+	expandedEdges, _ := expandEdges(prepareGridEdges(12), expansionOptions{workers: 1})
+	graph, err := PrepareGraph(expandedEdges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vertices := PrepareVertices(graph, expandedEdges)
+	// Grid is about 0.011 x 0.011 degrees, so zoom 16 splits it into several tiles
+	tiles, crossTileEdges, err := SplitIntoTiles(expandedEdges, vertices, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tiles) < 2 || len(crossTileEdges) == 0 {
+		t.Fatalf("Grid should be split into several tiles connected by edges, but got %d tiles and %d cross-tile edges", len(tiles), len(crossTileEdges))
+	}
+	vertexTiles := map[int64]Tile{}
+	verticesNum, edgesNum := 0, 0
+	for i, tile := range tiles {
+		if i > 0 && tiles[i-1].Tile.Quadkey() >= tile.Tile.Quadkey() {
+			t.Errorf("Tiles should be sorted by quadkey")
+		}
+		for _, vertex := range tile.Vertices {
+			if TileOfPoint(vertex.Geom, 16) != tile.Tile {
+				t.Errorf("Vertex %d should not belong to tile %v", vertex.ID, tile.Tile)
+			}
+			vertexTiles[vertex.ID] = tile.Tile
+		}
+		verticesNum += len(tile.Vertices)
+		edgesNum += len(tile.ExpandedEdges)
+	}
+	if verticesNum != len(vertices) || edgesNum != len(expandedEdges) {
+		t.Errorf("Every vertex and every edge should belong to single tile")
+	}
+	crossNum := 0
+	for _, tile := range tiles {
+		for _, edge := range tile.ExpandedEdges {
+			if vertexTiles[int64(edge.Source)] != tile.Tile {
+				t.Errorf("Edge %d should belong to tile of its source vertex", edge.ID)
+			}
+			if vertexTiles[int64(edge.Target)] != tile.Tile {
+				crossNum++
+			}
+		}
+	}
+	if crossNum != len(crossTileEdges) {
+		t.Errorf("Number of cross-tile edges should be %d, but got %d", crossNum, len(crossTileEdges))
+	}
+	if _, _, err = SplitIntoTiles(expandedEdges, vertices, MaxTileZoom+1); err == nil {
+		t.Errorf("Too big zoom should not be accepted")
+	}
+}
@@ -0,0 +1,152 @@
+package osm2ch
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// ValidationMismatch Pair of vertices for which contraction hierarchies give wrong result
+type ValidationMismatch struct {
+	Source int64
+	Target int64
+	// Reason of mismatch
+	Reason string
+	// Shortest paths found by contraction hierarchies and by plain Dijkstra's algorithm (cost is -1 if there is no path)
+	CHRoute       Route
+	DijkstraRoute Route
+}
+
+// ValidationReport Result of validation of contraction hierarchies
+type ValidationReport struct {
+	// Number of checked pairs of vertices
+	Pairs int
+	// Number of pairs having path
+	Reachable  int
+	Mismatches []ValidationMismatch
+}
+
+// ValidateContraction Compares shortest paths found by contraction hierarchies with ones found by plain Dijkstra's algorithm over
+// expanded edges for random pairs of vertices
+/*
+	Pair is mismatched if existence of path or costs differ (relative tolerance) or if path of contraction hierarchies does not consist
+	of expanded edges having the same total cost (e.g. because of bad shortcuts). Pairs are sampled by random generator with given seed,
+	so validation is reproducible. If workers is less or equal to zero then number of logical CPUs is used.
+	Mismatches are sorted by source and target
+*/
+func (router *Router) ValidateContraction(pairs int, seed int64, tolerance float64, workers int) (ValidationReport, error) {
+	if !router.contracted {
+		return ValidationReport{}, fmt.Errorf("Graph has not been contracted")
+	}
+	if len(router.vertices) == 0 {
+		return ValidationReport{}, fmt.Errorf("Graph has no vertices")
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	jobs := make(chan [2]int64, pairs)
+	for i := 0; i < pairs; i++ {
+		jobs <- [2]int64{router.vertices[rnd.Intn(len(router.vertices))].ID, router.vertices[rnd.Intn(len(router.vertices))].ID}
+	}
+	close(jobs)
+
+	report := ValidationReport{
+		Pairs:      pairs,
+		Mismatches: []ValidationMismatch{},
+	}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pair := range jobs {
+				mismatch, reachable := router.validatePair(pair[0], pair[1], tolerance)
+				mu.Lock()
+				if reachable {
+					report.Reachable++
+				}
+				if mismatch != nil {
+					report.Mismatches = append(report.Mismatches, *mismatch)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	sort.Slice(report.Mismatches, func(i, j int) bool {
+		if report.Mismatches[i].Source != report.Mismatches[j].Source {
+			return report.Mismatches[i].Source < report.Mismatches[j].Source
+		}
+		return report.Mismatches[i].Target < report.Mismatches[j].Target
+	})
+	return report, nil
+}
+
+// validatePair Returns mismatch (nil if results are the same) and existence of path for pair of vertices
+func (router *Router) validatePair(source, target int64, tolerance float64) (*ValidationMismatch, bool) {
+	chRoute, chOk := router.ShortestPath(source, target)
+	dijkstraRoute, dijkstraOk := router.DijkstraShortestPath(source, target)
+	if !chOk {
+		chRoute.Cost = -1
+	}
+	if !dijkstraOk {
+		dijkstraRoute.Cost = -1
+	}
+	mismatch := &ValidationMismatch{
+		Source:        source,
+		Target:        target,
+		CHRoute:       chRoute,
+		DijkstraRoute: dijkstraRoute,
+	}
+	switch {
+	case chOk != dijkstraOk:
+		mismatch.Reason = "existence of path differs"
+	case !chOk:
+		return nil, false
+	case math.Abs(chRoute.Cost-dijkstraRoute.Cost) > tolerance*math.Max(1, dijkstraRoute.Cost):
+		mismatch.Reason = fmt.Sprintf("cost differs by %g", chRoute.Cost-dijkstraRoute.Cost)
+	case len(chRoute.ExpandedEdges) != len(chRoute.Vertices)-1:
+		mismatch.Reason = "path is not connected by expanded edges"
+	default:
+		cost := 0.0
+		for _, edge := range chRoute.ExpandedEdges {
+			cost += edge.CostMeters
+		}
+		if math.Abs(cost-chRoute.Cost) > tolerance*math.Max(1, chRoute.Cost) {
+			mismatch.Reason = fmt.Sprintf("cost of expanded edges of path differs by %g", cost-chRoute.Cost)
+		} else {
+			return nil, true
+		}
+	}
+	return mismatch, dijkstraOk
+}
+
+// PrepareValidationGeoJSON Returns routes of mismatched pairs as FeatureCollection of LineString features (see Route.GeoJSONFeature)
+/*
+	Every mismatch gives two features with properties: kind ('ch' or 'dijkstra'), reason, source_vertex_id, target_vertex_id
+*/
+func PrepareValidationGeoJSON(report ValidationReport) *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	for _, mismatch := range report.Mismatches {
+		for _, kind := range []string{"ch", "dijkstra"} {
+			route := mismatch.CHRoute
+			if kind == "dijkstra" {
+				route = mismatch.DijkstraRoute
+			}
+			feature := route.GeoJSONFeature()
+			feature.SetProperty("kind", kind)
+			feature.SetProperty("reason", mismatch.Reason)
+			feature.SetProperty("source_vertex_id", mismatch.Source)
+			feature.SetProperty("target_vertex_id", mismatch.Target)
+			fc.AddFeature(feature)
+		}
+	}
+	return fc
+}
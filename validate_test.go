@@ -0,0 +1,61 @@
+package osm2ch
+
+import (
+	"testing"
+)
+
+func TestValidateContraction(t *testing.T) {
+	router := prepareTestRouter(t, true)
+	report, err := router.ValidateContraction(300, 1, 1e-9, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Pairs != 300 {
+		t.Errorf("Number of pairs should be %d, but got %d", 300, report.Pairs)
+	}
+	if report.Reachable == 0 {
+		t.Errorf("Some pairs should be reachable")
+	}
+	for _, mismatch := range report.Mismatches {
+		t.Errorf("Pair %d -> %d is mismatched: %s", mismatch.Source, mismatch.Target, mismatch.Reason)
+	}
+
+	// Shortcuts cheaper than paths they replace make contraction hierarchies find wrong costs
+	expandedEdges, vertices, shortcuts := prepareTestGraph(t, true)
+	for i := range shortcuts {
+		shortcuts[i].Weight /= 2
+	}
+	corruptedRouter, err := NewRouter(expandedEdges, vertices, shortcuts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = corruptedRouter.ValidateContraction(300, 1, 1e-9, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Mismatches) == 0 {
+		t.Fatalf("Corrupted shortcuts should give mismatches")
+	}
+	for _, mismatch := range report.Mismatches {
+		if mismatch.Reason == "" {
+			t.Errorf("Reason of mismatch %d -> %d should be provided", mismatch.Source, mismatch.Target)
+		}
+		for _, route := range []Route{mismatch.CHRoute, mismatch.DijkstraRoute} {
+			if route.Cost < 0 || len(route.Vertices) == 0 || route.Vertices[0] != mismatch.Source || route.Vertices[len(route.Vertices)-1] != mismatch.Target {
+				t.Errorf("Routes of mismatch %d -> %d should be filled, but got %+v", mismatch.Source, mismatch.Target, route)
+			}
+		}
+		if mismatch.CHRoute.Cost >= mismatch.DijkstraRoute.Cost {
+			t.Errorf("Cost of mismatched path %d -> %d should be less than %f, but got %f", mismatch.Source, mismatch.Target, mismatch.DijkstraRoute.Cost, mismatch.CHRoute.Cost)
+		}
+	}
+	if fc := PrepareValidationGeoJSON(report); len(fc.Features) != 2*len(report.Mismatches) {
+		t.Errorf("Every mismatch should give two features, but got %d features for %d mismatches", len(fc.Features), len(report.Mismatches))
+	}
+
+	// Validation makes no sense without contraction
+	_, err = prepareTestRouter(t, false).ValidateContraction(10, 1, 1e-9, 1)
+	if err == nil {
+		t.Errorf("Validation of uncontracted graph should fail")
+	}
+}
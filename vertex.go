@@ -0,0 +1,81 @@
+package osm2ch
+
+import (
+	"sort"
+
+	"github.com/LdDl/ch"
+	"github.com/pkg/errors"
+)
+
+// Vertex represents vertex of expanded graph (former edge) prepared for output
+type Vertex struct {
+	ID         int64
+	OrderPos   int64
+	Importance int
+	Geom       GeoPoint
+}
+
+// PrepareGraph Prepares graph for contraction hierarchies from expanded edges
+/*
+	Vertices are created in ascending order of their IDs, so output of vertices (and contraction itself) is reproducible.
+	Edges are added in given order
+*/
+func PrepareGraph(expandedEdges []ExpandedEdge) (*ch.Graph, error) {
+	graph := ch.Graph{}
+	verticesSeen := make(map[int64]struct{})
+	verticesIDs := []int64{}
+	for _, edge := range expandedEdges {
+		for _, vertexID := range []int64{int64(edge.Source), int64(edge.Target)} {
+			if _, ok := verticesSeen[vertexID]; !ok {
+				verticesSeen[vertexID] = struct{}{}
+				verticesIDs = append(verticesIDs, vertexID)
+			}
+		}
+	}
+	sort.Slice(verticesIDs, func(i, j int) bool {
+		return verticesIDs[i] < verticesIDs[j]
+	})
+	for _, vertexID := range verticesIDs {
+		err := graph.CreateVertex(vertexID)
+		if err != nil {
+			return nil, errors.Wrap(err, "Can not create vertex")
+		}
+	}
+	for _, edge := range expandedEdges {
+		err := graph.AddEdge(int64(edge.Source), int64(edge.Target), edge.CostMeters)
+		if err != nil {
+			return nil, errors.Wrap(err, "Can not wrap Source and Targed vertices as Edge")
+		}
+	}
+	return &graph, nil
+}
+
+// PrepareVertices Returns vertices of graph (in order of graph.Vertices) with their geometries
+/*
+	Geometry of vertex is the first point of the first expanded edge starting in it (or the last point of the first expanded edge ending in it).
+	Expanded edges having less than two points are ignored, so vertex could have empty (zero) geometry
+*/
+func PrepareVertices(graph *ch.Graph, expandedEdges []ExpandedEdge) []Vertex {
+	verticesGeoms := make(map[int64]GeoPoint)
+	for _, edge := range expandedEdges {
+		if len(edge.Geom) < 2 {
+			continue
+		}
+		if _, ok := verticesGeoms[int64(edge.Source)]; !ok {
+			verticesGeoms[int64(edge.Source)] = edge.Geom[0]
+		}
+		if _, ok := verticesGeoms[int64(edge.Target)]; !ok {
+			verticesGeoms[int64(edge.Target)] = edge.Geom[len(edge.Geom)-1]
+		}
+	}
+	vertices := make([]Vertex, len(graph.Vertices))
+	for i, vertex := range graph.Vertices {
+		vertices[i] = Vertex{
+			ID:         vertex.Label,
+			OrderPos:   vertex.OrderPos(),
+			Importance: vertex.Importance(),
+			Geom:       verticesGeoms[vertex.Label],
+		}
+	}
+	return vertices
+}
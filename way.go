@@ -1,12 +1,70 @@
 package osm2ch
 
 import (
+	"encoding/json"
+	"strings"
+
 	"github.com/paulmach/osm"
 )
 
 type Way struct {
-	ID     osm.WayID
-	Oneway bool
-	Nodes  osm.WayNodes
-	TagMap osm.Tags
+	ID      osm.WayID
+	Version int
+	Oneway  bool
+	Nodes   osm.WayNodes
+	TagMap  osm.Tags
+}
+
+// PrepareTagsJSON returns tags as JSON object (keys are sorted)
+func PrepareTagsJSON(tags osm.Tags) string {
+	// Marshaling of map[string]string can't fail
+	b, _ := json.Marshal(tags.Map())
+	return string(b)
+}
+
+// AllTagsColumn Special tag column which contains all tags of way as JSON object (see PrepareTagsJSON)
+/*
+	Prefix '@' can't clash with real OSM tag in practice, so tag named 'tags' is still available as ordinary column
+*/
+const AllTagsColumn = "@tags"
+
+// ParseTagColumns Returns list of tag columns from string (tags are separated by commas, empty ones are skipped)
+func ParseTagColumns(str string) []string {
+	tagColumns := []string{}
+	for _, tag := range strings.Split(str, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tagColumns = append(tagColumns, tag)
+		}
+	}
+	return tagColumns
+}
+
+// PrepareTagColumnsHeader Returns names of columns for tags of source and target ways of expanded edge: 'osm_way_from_<tag>' and 'osm_way_to_<tag>' for every tag column
+func PrepareTagColumnsHeader(tagColumns []string) []string {
+	header := make([]string, 0, 2*len(tagColumns))
+	for _, tag := range tagColumns {
+		header = append(header, "osm_way_from_"+tag, "osm_way_to_"+tag)
+	}
+	return header
+}
+
+// PrepareTagColumns Returns values of tag columns for source and target ways of expanded edge (in the same order as PrepareTagColumnsHeader)
+/*
+	Value is empty if way has no such tag. Column AllTagsColumn gives all tags of way as JSON object
+*/
+func PrepareTagColumns(sourceTags, targetTags osm.Tags, tagColumns []string) []string {
+	row := make([]string, 0, 2*len(tagColumns))
+	for _, tag := range tagColumns {
+		row = append(row, prepareTagColumn(sourceTags, tag), prepareTagColumn(targetTags, tag))
+	}
+	return row
+}
+
+// prepareTagColumn Returns value of tag column for single way
+func prepareTagColumn(tags osm.Tags, column string) string {
+	if column == AllTagsColumn {
+		return PrepareTagsJSON(tags)
+	}
+	return tags.Find(column)
 }
@@ -0,0 +1,28 @@
+package osm2ch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/osm"
+)
+
+func TestTagColumns(t *testing.T) {
+	tagColumns := ParseTagColumns(" name, ,highway,tags,@tags,")
+	if !reflect.DeepEqual(tagColumns, []string{"name", "highway", "tags", "@tags"}) {
+		t.Fatalf("Bad tag columns: %v", tagColumns)
+	}
+	if got := ParseTagColumns(""); len(got) != 0 {
+		t.Errorf("Empty string should give no tag columns, but got %v", got)
+	}
+	expectedHeader := []string{"osm_way_from_name", "osm_way_to_name", "osm_way_from_highway", "osm_way_to_highway", "osm_way_from_tags", "osm_way_to_tags", "osm_way_from_@tags", "osm_way_to_@tags"}
+	if header := PrepareTagColumnsHeader(tagColumns); !reflect.DeepEqual(header, expectedHeader) {
+		t.Errorf("Header should be %v, but got %v", expectedHeader, header)
+	}
+	sourceTags := osm.Tags{{Key: "highway", Value: "primary"}, {Key: "name", Value: "Main"}, {Key: "tags", Value: "real"}}
+	targetTags := osm.Tags{{Key: "highway", Value: "residential"}}
+	expectedRow := []string{"Main", "", "primary", "residential", "real", "", `{"highway":"primary","name":"Main","tags":"real"}`, `{"highway":"residential"}`}
+	if row := PrepareTagColumns(sourceTags, targetTags, tagColumns); !reflect.DeepEqual(row, expectedRow) {
+		t.Errorf("Row should be %v, but got %v", expectedRow, row)
+	}
+}